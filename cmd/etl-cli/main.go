@@ -24,7 +24,27 @@ func main() {
 	generateCmd.Flags().String("name", "", "Name of the pipeline to generate")
 	generateCmd.MarkFlagRequired("name")
 
+	var validateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Validate a pipeline configuration",
+		Run:   runValidate,
+	}
+
+	validateCmd.Flags().String("config", "", "Path to the pipeline configuration")
+	validateCmd.MarkFlagRequired("config")
+
+	var runCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Run a configuration-driven pipeline",
+		Run:   runPipeline,
+	}
+
+	runCmd.Flags().String("config", "", "Path to the pipeline configuration")
+	runCmd.MarkFlagRequired("config")
+
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(runCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
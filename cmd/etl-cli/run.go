@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aniketwaliyan/etl-framework/internal/extract"
+	"github.com/aniketwaliyan/etl-framework/internal/load"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/transform"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/spf13/cobra"
+)
+
+func runPipeline(cmd *cobra.Command, args []string) {
+	configPath, _ := cmd.Flags().GetString("config")
+	if err := executePipeline(cmd.Context(), configPath); err != nil {
+		fmt.Printf("Pipeline run failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Pipeline run completed successfully")
+}
+
+func executePipeline(ctx context.Context, configPath string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	parser := config.NewParser()
+	cfg, err := parser.Parse(configPath)
+	if err != nil {
+		return err
+	}
+	storage.Configure(cfg.Storage)
+
+	extractor, err := extract.New(cfg.Source.Type)
+	if err != nil {
+		return err
+	}
+	loader, err := load.FromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	orchestrator := pipeline.NewOrchestrator(cfg, extractor, transform.NewChain(), loader)
+	return orchestrator.Execute(ctx)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// buildFixture creates a SQLite database at path from a SQL script, as
+// make fixtures does with the sqlite3 shell.
+func buildFixture(t *testing.T, path, script string) {
+	t.Helper()
+	ddl, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(ddl)); err != nil {
+		t.Fatalf("%s: %v", script, err)
+	}
+}
+
+func TestRunLoginAnalyticsSQLite(t *testing.T) {
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The config names its databases relative to the working directory,
+	// and runs are recorded there too.
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Mkdir("testdata", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	buildFixture(t, "testdata/source.db", filepath.Join(root, "testdata", "source.sql"))
+	buildFixture(t, "testdata/sink.db", filepath.Join(root, "testdata", "sink.sql"))
+
+	configPath := filepath.Join(root, "configs", "login-analytics-sqlite.yaml")
+	// A second run upserts the same rows.
+	for i := 0; i < 2; i++ {
+		if err := executePipeline(context.Background(), configPath); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", "testdata/sink.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM user_connection_history").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("sink has %d rows, want 5", n)
+	}
+
+	var (
+		flag, table string
+		session     sql.NullString
+	)
+	err = db.QueryRow(`SELECT logon_logoff_flag, session_id, source_table FROM user_connection_history
+		WHERE dealer_id = 'D001' AND entry_sequence = 2`).Scan(&flag, &session, &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flag != "O" || session.String != "S-1001" || table != "tbl_UserConnectionHistory" {
+		t.Fatalf("unexpected row: flag=%s session=%v source_table=%s", flag, session, table)
+	}
+	err = db.QueryRow(`SELECT session_id FROM user_connection_history
+		WHERE dealer_id = 'D003'`).Scan(&session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Valid {
+		t.Fatalf("null session loaded as %q", session.String)
+	}
+}
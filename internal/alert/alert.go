@@ -0,0 +1,81 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/httpclient"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// Alert is a problem found during a run. It is posted to the webhook as
+// JSON.
+type Alert struct {
+	Pipeline string      `json:"pipeline"`
+	RunID    string      `json:"run_id,omitempty"`
+	Kind     string      `json:"kind"`
+	Summary  string      `json:"summary"`
+	Details  interface{} `json:"details,omitempty"`
+	Time     time.Time   `json:"time"`
+}
+
+// Alerter sends the alerts of a pipeline as configured under alerts.
+type Alerter struct {
+	pipeline string
+	url      string
+	method   string
+	client   *httpclient.Client
+}
+
+func New(cfg *config.PipelineConfig) (*Alerter, error) {
+	a := &Alerter{pipeline: cfg.Pipeline.Name}
+
+	webhook := cfg.Alerts.Webhook
+	if webhook.URL == "" {
+		return a, nil
+	}
+	client, err := httpclient.New(webhook)
+	if err != nil {
+		return nil, fmt.Errorf("alert webhook: %w", err)
+	}
+	a.url, a.client = webhook.URL, client
+	a.method = webhook.Method
+	if a.method == "" {
+		a.method = http.MethodPost
+	}
+	return a, nil
+}
+
+// Send logs the alert and posts it to the webhook, if there is one. The run
+// is taken from ctx.
+func (a *Alerter) Send(ctx context.Context, kind, summary string, details interface{}) error {
+	log.Printf("ALERT %s: %s", kind, summary)
+	if a.client == nil {
+		return nil
+	}
+
+	alert := Alert{
+		Pipeline: a.pipeline,
+		Kind:     kind,
+		Summary:  summary,
+		Details:  details,
+		Time:     time.Now().UTC(),
+	}
+	if run := pipeline.RunFromContext(ctx); run != nil {
+		alert.RunID = run.ID
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	if _, _, err := a.client.Do(ctx, a.method, a.url, body, header); err != nil {
+		return fmt.Errorf("failed to send alert to webhook: %w", err)
+	}
+	return nil
+}
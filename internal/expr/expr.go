@@ -0,0 +1,362 @@
+// Package expr implements the small expression language used by filter and
+// derive transformations. Expressions are compiled once and evaluated against
+// each record; field names resolve to record values and missing fields are
+// null.
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Program is a compiled expression. It is safe for concurrent use.
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses src. Errors are *SyntaxError values locating the problem
+// within src.
+func Compile(src string) (*Program, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &SyntaxError{1, "empty expression"}
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", describe(t))
+	}
+	return &Program{source: src, root: root}, nil
+}
+
+// Eval evaluates the expression against record. The result is nil, bool,
+// int64, float64, string, time.Time or []interface{}.
+func (p *Program) Eval(record map[string]interface{}) (interface{}, error) {
+	return p.root.eval(record)
+}
+
+// Match evaluates the expression as a condition. Null counts as false, so
+// comparisons against a missing field do not match.
+func (p *Program) Match(record map[string]interface{}) (bool, error) {
+	v, err := p.Eval(record)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v)
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+type node interface {
+	eval(record map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(record map[string]interface{}) (interface{}, error) {
+	return normalize(record[n.name]), nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(record map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(record)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// logicalNode is and/or with SQL three-valued logic: null and false is
+// false, null or true is true, and otherwise a null operand makes the result
+// null.
+type logicalNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicalNode) eval(record map[string]interface{}) (interface{}, error) {
+	l, err := n.operand(n.left, record)
+	if err != nil {
+		return nil, err
+	}
+	// short-circuit on false for and, true for or
+	if l != nil && l.(bool) == n.or {
+		return l, nil
+	}
+	r, err := n.operand(n.right, record)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil && r.(bool) == n.or {
+		return r, nil
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	return !n.or, nil
+}
+
+func (n *logicalNode) operand(x node, record map[string]interface{}) (interface{}, error) {
+	v, err := x.eval(record)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if _, ok := v.(bool); !ok {
+		return nil, fmt.Errorf("expected a boolean, got %s", typeName(v))
+	}
+	return v, nil
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(record map[string]interface{}) (interface{}, error) {
+	v, err := n.x.eval(record)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, err := truthy(v)
+	if err != nil {
+		return nil, err
+	}
+	return !b, nil
+}
+
+type isNullNode struct {
+	x      node
+	negate bool
+}
+
+func (n *isNullNode) eval(record map[string]interface{}) (interface{}, error) {
+	v, err := n.x.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != n.negate, nil
+}
+
+// compareNode compares with SQL-like null semantics, except that == and !=
+// against a null literal behave like is null and is not null.
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(record map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if l == nil || r == nil {
+		switch n.op {
+		case "==":
+			return l == nil && r == nil, nil
+		case "!=":
+			return (l == nil) != (r == nil), nil
+		}
+		return nil, nil
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	}
+	c, err := compare(l, r)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type inNode struct {
+	x      node
+	list   node
+	negate bool
+}
+
+func (n *inNode) eval(record map[string]interface{}) (interface{}, error) {
+	v, err := n.x.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	l, err := n.list.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil || l == nil {
+		return nil, nil
+	}
+	items, ok := l.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("in expects a list, got %s", typeName(l))
+	}
+	for _, item := range items {
+		if item != nil && equal(v, item) {
+			return !n.negate, nil
+		}
+	}
+	return n.negate, nil
+}
+
+type arithNode struct {
+	op          string
+	left, right node
+}
+
+func (n *arithNode) eval(record map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	return arith(n.op, l, r)
+}
+
+type ifNode struct {
+	cond, then, otherwise node
+}
+
+func (n *ifNode) eval(record map[string]interface{}) (interface{}, error) {
+	c, err := n.cond.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	b, err := truthy(c)
+	if err != nil {
+		return nil, err
+	}
+	if b {
+		return n.then.eval(record)
+	}
+	return n.otherwise.eval(record)
+}
+
+type coalesceNode struct {
+	args []node
+}
+
+func (n *coalesceNode) eval(record map[string]interface{}) (interface{}, error) {
+	for _, arg := range n.args {
+		v, err := arg.eval(record)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+type callNode struct {
+	name string
+	fn   *function
+	args []node
+
+	// state is set by the function's prepare hook, e.g. a compiled regexp
+	// for a literal pattern.
+	state interface{}
+}
+
+func (n *callNode) eval(record map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(record)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if n.fn.strict {
+		for _, a := range args {
+			if a == nil {
+				return nil, nil
+			}
+		}
+	}
+	v, err := n.fn.call(n, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return v, nil
+}
+
+func truthy(v interface{}) (bool, error) {
+	switch t := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return t, nil
+	default:
+		return false, fmt.Errorf("expected a boolean, got %s", typeName(v))
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "timestamp"
+	case []interface{}:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
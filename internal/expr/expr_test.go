@@ -0,0 +1,264 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var ts = time.Date(2026, 10, 17, 9, 30, 15, 0, time.UTC)
+
+func testRecord() map[string]interface{} {
+	return map[string]interface{}{
+		"n":           5,
+		"f":           float32(2.5),
+		"s":           "42",
+		"name":        " Alice ",
+		"nul":         nil,
+		"ts":          ts,
+		"num":         json.Number("7"),
+		"big":         uint64(math.MaxUint64),
+		"b":           []byte("xy"),
+		"tags":        []string{"a", "b"},
+		"dotted.name": "d",
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src    string
+		column int
+		msg    string
+	}{
+		{"  ", 1, "empty expression"},
+		{"a ==", 5, "unexpected end of expression"},
+		{"hour(login_ts", 14, `expected ")", got end of expression`},
+		{"a b", 3, `unexpected "b"`},
+		{"'abc", 1, "unterminated string"},
+		{"`abc", 1, "unterminated quoted field name"},
+		{"a # b", 3, "unexpected character '#'"},
+		{"a is 1", 6, `expected "null"`},
+		{"foo(1)", 1, "unknown function foo"},
+		{"lower(a, b)", 1, "lower expects 1 argument, got 2"},
+		{"substr(a)", 1, "substr expects 2 to 3 arguments"},
+		{"if(a, b)", 1, "if expects 3 arguments"},
+		{"coalesce()", 1, "coalesce expects at least 1 argument"},
+		{"x + regex_match(a, '(')", 5, "regex_match: error parsing regexp"},
+		{"9223372036854775808", 1, "integer 9223372036854775808 is out of range"},
+		{"1 - -9223372036854775809", 6, "integer -9223372036854775809 is out of range"},
+		{"1..2", 1, `invalid number "1..2"`},
+	} {
+		_, err := Compile(tc.src)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Compile(%q) = %v, want a syntax error", tc.src, err)
+			continue
+		}
+		if syntaxErr.Column != tc.column || !strings.Contains(syntaxErr.Msg, tc.msg) {
+			t.Errorf("Compile(%q) = %v, want column %d: %s", tc.src, err, tc.column, tc.msg)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want interface{}
+	}{
+		// arithmetic and literals
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"7 / 2", 3.5},
+		{"6 / 3", 2.0},
+		{"7 % 3", int64(1)},
+		{"7.5 % 2", 1.5},
+		{"-2 * 3", int64(-6)},
+		{"1_000 + 0.5e1", 1005.0},
+		{"9223372036854775807", int64(math.MaxInt64)},
+		{"-9223372036854775808", int64(math.MinInt64)},
+		{"9223372036854775806 + 1", int64(math.MaxInt64)},
+		{"-9223372036854775807 - 1", int64(math.MinInt64)},
+		{"3037000499 * 3037000499", int64(9223372030926249001)},
+		{"-1 * 9223372036854775807", int64(-math.MaxInt64)},
+		{"9223372036854775807 + 1.0", 9223372036854775808.0},
+		{"-9223372036854775808 % -1", int64(0)},
+
+		// record values and coercion
+		{"-n", int64(-5)},
+		{"n + f", 7.5},
+		{"s + 1", int64(43)},
+		{"s + 'x'", "42x"},
+		{"num * 2", int64(14)},
+		{"big > 0", true},
+		{"b == 'xy'", true},
+		{"s == 42", true},
+		{"s = 42", true},
+		{"s <> 42", false},
+		{"'10' < '9'", true},
+		{"s < 100", true},
+		{"ts == '2026-10-17T09:30:15Z'", true},
+		{"ts > '2026-10-17'", true},
+		{"ts + 60", ts.Add(time.Minute)},
+		{"ts - to_timestamp('2026-10-17')", 34215.0},
+		{"'a' in tags", true},
+		{"n in [1, 5]", true},
+		{"n not in [1, 5]", false},
+		{"`dotted.name`", "d"},
+		{"dotted.name", "d"},
+
+		// nulls
+		{"nul + 1", nil},
+		{"missing == null", true},
+		{"missing != null", false},
+		{"missing == 1", false},
+		{"missing != 1", true},
+		{"missing < 1", nil},
+		{"nul is null", true},
+		{"n is not null", true},
+		{"nul and false", false},
+		{"nul and true", nil},
+		{"nul or true", true},
+		{"nul or false", nil},
+		{"not nul", nil},
+		{"missing in [1]", nil},
+		{"lower(nul)", nil},
+		{"coalesce(nul, missing, 'x')", "x"},
+		{"concat('a', nul, 1)", "a1"},
+		{"nullif(n, 5)", nil},
+		{"nullif(n, 6)", int64(5)},
+		{"if(nul, 1, 2)", int64(2)},
+		{"least(3, nul, 1)", int64(1)},
+		{"greatest('b', 'a')", "b"},
+
+		// functions
+		{"upper(trim(name))", "ALICE"},
+		{"length('héllo')", int64(5)},
+		{"length(tags)", int64(2)},
+		{"substr('abcdef', 2, 3)", "bcd"},
+		{"substr('abc', 5)", ""},
+		{"replace('a-b-c', '-', '')", "abc"},
+		{"split_part('a,b,c', ',', 2)", "b"},
+		{"split_part('a,b,c', ',', 4)", ""},
+		{`regex_match(s, '^\d+$')`, true},
+		{`regex_replace('a1b22', '\d+', '#')`, "a#b#"},
+		{"contains(name, 'lic') and starts_with('abc', 'ab') and ends_with('abc', 'bc')", true},
+		{"abs(-3)", int64(3)},
+		{"abs(-2.5)", 2.5},
+		{"round(2.5)", 3.0},
+		{"round(1.2345, 2)", 1.23},
+		{"round(7)", int64(7)},
+		{"floor(2.7)", 2.0},
+		{"ceil(2.1)", 3.0},
+		{"int('12')", int64(12)},
+		{"int(2.9)", int64(2)},
+		{"int(true)", int64(1)},
+		{"float(3)", 3.0},
+		{"string(1.5)", "1.5"},
+		{"bool('true')", true},
+		{"bool(0)", false},
+		{"from_unixtime(1760693415)", time.Unix(1760693415, 0).UTC()},
+		{"from_unixtime_ms(1500)", time.Unix(1, 5e8).UTC()},
+		{"unix_timestamp(ts)", ts.Unix()},
+		{"format_time(ts, '2006-01-02 15:04')", "2026-10-17 09:30"},
+		{"date(ts)", "2026-10-17"},
+		{"date_trunc('hour', ts)", time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)},
+		{"date_trunc('week', ts)", time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)},
+		{"year(ts) * 100 + month(ts)", int64(202610)},
+		{"day_of_week(ts)", int64(6)},
+		{"hour('2026-10-17 09:30:00')", int64(9)},
+		{"to_timestamp('17/10/2026', '02/01/2006')", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+	} {
+		p, err := Compile(tc.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tc.src, err)
+			continue
+		}
+		got, err := p.Eval(testRecord())
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s = %#v, want %#v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{"hour('abc')", `hour: expected a timestamp, got string "abc"`},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"'a' - 1", "cannot apply - to string and number"},
+		{"ts * 2", "cannot apply * to timestamp and number"},
+		{"ts - '2026-10-17'", "cannot apply - to timestamp and string"},
+		{"n and true", "expected a boolean, got number"},
+		{"if(n, 1, 2)", "expected a boolean, got number"},
+		{"n < 'x'", "cannot compare number with string"},
+		{"ts < 'yesterday'", `cannot compare timestamp with "yesterday"`},
+		{"1 in 1", "in expects a list"},
+		{"substr('abc', 1, -1)", "substr: negative length -1"},
+		{"split_part('a', ',', 0)", "field number must be at least 1"},
+		{"date_trunc('decade', ts)", `unsupported unit "decade"`},
+		{"bool('maybe')", "expected a boolean"},
+		{"9223372036854775807 + 1", "integer overflow in 9223372036854775807 + 1"},
+		{"-9223372036854775808 - 1", "integer overflow"},
+		{"- -9223372036854775808", "integer overflow"},
+		{"4611686018427387904 * 2", "integer overflow"},
+		{"-1 * -9223372036854775808", "integer overflow"},
+		{"-9223372036854775808 * -1", "integer overflow"},
+		{"s * 9223372036854775807", "integer overflow"},
+		{"abs(-9223372036854775808)", "integer overflow"},
+		{"int(1e19)", "out of the range of an integer"},
+		{"int(-1e19)", "out of the range of an integer"},
+	} {
+		p, err := Compile(tc.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tc.src, err)
+			continue
+		}
+		v, err := p.Eval(testRecord())
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s = %v, %v, want an error containing %q", tc.src, v, err, tc.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want bool
+	}{
+		{"n > 1", true},
+		{"missing > 1", false},
+		{"nul and true", false},
+		{"not (missing > 1)", false},
+	} {
+		p, err := Compile(tc.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.Match(testRecord())
+		if err != nil || got != tc.want {
+			t.Errorf("Match(%q) = %v, %v, want %v", tc.src, got, err, tc.want)
+		}
+	}
+
+	p, err := Compile("n + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Match(testRecord()); err == nil {
+		t.Error("a number matched as a condition")
+	}
+	if p.String() != "n + 1" {
+		t.Errorf("String() = %q", p.String())
+	}
+}
@@ -0,0 +1,457 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type function struct {
+	min, max int // max -1 is variadic
+
+	// strict functions return null when any argument is null without
+	// being called.
+	strict bool
+
+	call    func(n *callNode, args []interface{}) (interface{}, error)
+	prepare func(n *callNode) error
+}
+
+func (f *function) arity() string {
+	switch {
+	case f.min == f.max && f.min == 1:
+		return "1 argument"
+	case f.min == f.max:
+		return fmt.Sprintf("%d arguments", f.min)
+	case f.max < 0:
+		return fmt.Sprintf("at least %d arguments", f.min)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.min, f.max)
+	}
+}
+
+var functions map[string]*function
+
+func init() {
+	functions = map[string]*function{
+		// strings
+		"lower":         strictFn(1, 1, stringFn(strings.ToLower)),
+		"upper":         strictFn(1, 1, stringFn(strings.ToUpper)),
+		"trim":          strictFn(1, 1, stringFn(strings.TrimSpace)),
+		"length":        strictFn(1, 1, fnLength),
+		"substr":        strictFn(2, 3, fnSubstr),
+		"replace":       strictFn(3, 3, fnReplace),
+		"contains":      strictFn(2, 2, fnContains),
+		"starts_with":   strictFn(2, 2, fnStartsWith),
+		"ends_with":     strictFn(2, 2, fnEndsWith),
+		"split_part":    strictFn(3, 3, fnSplitPart),
+		"concat":        {min: 1, max: -1, call: fnConcat},
+		"regex_match":   {min: 2, max: 2, strict: true, call: fnRegexMatch, prepare: prepareRegex},
+		"regex_replace": {min: 3, max: 3, strict: true, call: fnRegexReplace, prepare: prepareRegex},
+
+		// numbers
+		"abs":      strictFn(1, 1, fnAbs),
+		"round":    strictFn(1, 2, fnRound),
+		"floor":    strictFn(1, 1, floatFn(math.Floor)),
+		"ceil":     strictFn(1, 1, floatFn(math.Ceil)),
+		"least":    {min: 1, max: -1, call: extremeFn(-1)},
+		"greatest": {min: 1, max: -1, call: extremeFn(1)},
+
+		// nulls and types
+		"nullif": {min: 2, max: 2, call: fnNullIf},
+		"int":    strictFn(1, 1, fnInt),
+		"float":  strictFn(1, 1, fnFloat),
+		"string": strictFn(1, 1, fnString),
+		"bool":   strictFn(1, 1, fnBool),
+
+		// dates
+		"now":              {min: 0, max: 0, call: fnNow},
+		"from_unixtime":    strictFn(1, 1, epochFn(float64(time.Second))),
+		"from_unixtime_ms": strictFn(1, 1, epochFn(float64(time.Millisecond))),
+		"to_timestamp":     strictFn(1, 2, fnToTimestamp),
+		"unix_timestamp":   strictFn(1, 1, fnUnixTimestamp),
+		"format_time":      strictFn(2, 2, fnFormatTime),
+		"date":             strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return t.Format("2006-01-02") })),
+		"date_trunc":       strictFn(2, 2, fnDateTrunc),
+		"year":             strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Year()) })),
+		"month":            strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Month()) })),
+		"day":              strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Day()) })),
+		"hour":             strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Hour()) })),
+		"minute":           strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Minute()) })),
+		"second":           strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Second()) })),
+		"day_of_week":      strictFn(1, 1, timePartFn(func(t time.Time) interface{} { return int64(t.Weekday()) })),
+	}
+}
+
+func strictFn(min, max int, call func(args []interface{}) (interface{}, error)) *function {
+	return &function{
+		min:    min,
+		max:    max,
+		strict: true,
+		call:   func(_ *callNode, args []interface{}) (interface{}, error) { return call(args) },
+	}
+}
+
+func stringFn(f func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		return f(asString(args[0])), nil
+	}
+}
+
+func fnLength(args []interface{}) (interface{}, error) {
+	if items, ok := args[0].([]interface{}); ok {
+		return int64(len(items)), nil
+	}
+	return int64(utf8.RuneCountInString(asString(args[0]))), nil
+}
+
+// fnSubstr is substr(s, start[, length]) with a 1-based start, as in SQL.
+func fnSubstr(args []interface{}) (interface{}, error) {
+	runes := []rune(asString(args[0]))
+	start, err := asInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if start < 1 {
+		start = 1
+	}
+	if start > int64(len(runes)) {
+		return "", nil
+	}
+	end := int64(len(runes))
+	if len(args) == 3 {
+		n, err := asInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("negative length %d", n)
+		}
+		if start-1+n < end {
+			end = start - 1 + n
+		}
+	}
+	return string(runes[start-1 : end]), nil
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	return strings.ReplaceAll(asString(args[0]), asString(args[1]), asString(args[2])), nil
+}
+
+func fnContains(args []interface{}) (interface{}, error) {
+	return strings.Contains(asString(args[0]), asString(args[1])), nil
+}
+
+func fnStartsWith(args []interface{}) (interface{}, error) {
+	return strings.HasPrefix(asString(args[0]), asString(args[1])), nil
+}
+
+func fnEndsWith(args []interface{}) (interface{}, error) {
+	return strings.HasSuffix(asString(args[0]), asString(args[1])), nil
+}
+
+// fnSplitPart returns the n-th (1-based) field of s split on sep, or an
+// empty string when there are fewer fields.
+func fnSplitPart(args []interface{}) (interface{}, error) {
+	n, err := asInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("field number must be at least 1, got %d", n)
+	}
+	parts := strings.Split(asString(args[0]), asString(args[1]))
+	if n > int64(len(parts)) {
+		return "", nil
+	}
+	return parts[n-1], nil
+}
+
+// fnConcat joins its arguments as strings, skipping nulls.
+func fnConcat(_ *callNode, args []interface{}) (interface{}, error) {
+	var b strings.Builder
+	for _, a := range args {
+		if a != nil {
+			b.WriteString(asString(a))
+		}
+	}
+	return b.String(), nil
+}
+
+var regexCache sync.Map
+
+// prepareRegex compiles a literal pattern once at compile time, so that an
+// invalid pattern is reported before the pipeline runs.
+func prepareRegex(n *callNode) error {
+	lit, ok := n.args[1].(*literalNode)
+	if !ok {
+		return nil
+	}
+	s, ok := lit.value.(string)
+	if !ok {
+		return fmt.Errorf("pattern must be a string")
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	n.state = re
+	return nil
+}
+
+func regexFor(n *callNode, pattern interface{}) (*regexp.Regexp, error) {
+	if re, ok := n.state.(*regexp.Regexp); ok {
+		return re, nil
+	}
+	s := asString(pattern)
+	if re, ok := regexCache.Load(s); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(s, re)
+	return re, nil
+}
+
+func fnRegexMatch(n *callNode, args []interface{}) (interface{}, error) {
+	re, err := regexFor(n, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(asString(args[0])), nil
+}
+
+func fnRegexReplace(n *callNode, args []interface{}) (interface{}, error) {
+	re, err := regexFor(n, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return re.ReplaceAllString(asString(args[0]), asString(args[2])), nil
+}
+
+func fnAbs(args []interface{}) (interface{}, error) {
+	n, err := asNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := n.(int64); ok {
+		if i == math.MinInt64 {
+			return nil, fmt.Errorf("integer overflow in abs(%d)", i)
+		}
+		if i < 0 {
+			return -i, nil
+		}
+		return i, nil
+	}
+	return math.Abs(n.(float64)), nil
+}
+
+func fnRound(args []interface{}) (interface{}, error) {
+	n, err := asNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		if i, ok := n.(int64); ok {
+			return i, nil
+		}
+		return math.Round(n.(float64)), nil
+	}
+	digits, err := asInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	scale := math.Pow(10, float64(digits))
+	return math.Round(toFloat(n)*scale) / scale, nil
+}
+
+func floatFn(f func(float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		n, err := asNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := n.(int64); ok {
+			return i, nil
+		}
+		return f(n.(float64)), nil
+	}
+}
+
+// extremeFn implements least and greatest, which ignore nulls.
+func extremeFn(sign int) func(*callNode, []interface{}) (interface{}, error) {
+	return func(_ *callNode, args []interface{}) (interface{}, error) {
+		var best interface{}
+		for _, a := range args {
+			if a == nil {
+				continue
+			}
+			if best == nil {
+				best = a
+				continue
+			}
+			c, err := compare(a, best)
+			if err != nil {
+				return nil, err
+			}
+			if c*sign > 0 {
+				best = a
+			}
+		}
+		return best, nil
+	}
+}
+
+func fnNullIf(_ *callNode, args []interface{}) (interface{}, error) {
+	if args[0] != nil && args[1] != nil && equal(args[0], args[1]) {
+		return nil, nil
+	}
+	return args[0], nil
+}
+
+func fnInt(args []interface{}) (interface{}, error) {
+	switch t := args[0].(type) {
+	case bool:
+		if t {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case time.Time:
+		return t.Unix(), nil
+	}
+	n, err := asNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := n.(float64); ok {
+		// 2^63 is the first float64 above the range of int64
+		if !(f >= -(1<<63) && f < 1<<63) {
+			return nil, fmt.Errorf("%v is out of the range of an integer", f)
+		}
+		return int64(f), nil
+	}
+	return n, nil
+}
+
+func fnFloat(args []interface{}) (interface{}, error) {
+	n, err := asNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return toFloat(n), nil
+}
+
+func fnString(args []interface{}) (interface{}, error) {
+	return asString(args[0]), nil
+}
+
+func fnBool(args []interface{}) (interface{}, error) {
+	switch t := args[0].(type) {
+	case bool:
+		return t, nil
+	case int64:
+		return t != 0, nil
+	case float64:
+		return t != 0, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(t))
+		if err != nil {
+			return nil, fmt.Errorf("expected a boolean, got %s", describeValue(t))
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("expected a boolean, got %s", typeName(args[0]))
+}
+
+func fnNow(*callNode, []interface{}) (interface{}, error) {
+	return time.Now().UTC(), nil
+}
+
+// epochFn converts a count of units since the Unix epoch to a UTC timestamp.
+func epochFn(unit float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		n, err := asNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := n.(int64); ok {
+			return time.Unix(0, 0).Add(time.Duration(i) * time.Duration(unit)).UTC(), nil
+		}
+		return time.Unix(0, int64(n.(float64)*unit)).UTC(), nil
+	}
+}
+
+// fnToTimestamp parses a string as a timestamp, with an optional Go layout.
+func fnToTimestamp(args []interface{}) (interface{}, error) {
+	if len(args) == 1 {
+		return asTime(args[0])
+	}
+	s := asString(args[0])
+	t, err := time.Parse(asString(args[1]), s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q with layout %q", s, asString(args[1]))
+	}
+	return t, nil
+}
+
+func fnUnixTimestamp(args []interface{}) (interface{}, error) {
+	t, err := asTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return t.Unix(), nil
+}
+
+func fnFormatTime(args []interface{}) (interface{}, error) {
+	t, err := asTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return t.Format(asString(args[1])), nil
+}
+
+// fnDateTrunc is date_trunc(unit, t) for unit second, minute, hour, day,
+// week (starting Monday), month or year.
+func fnDateTrunc(args []interface{}) (interface{}, error) {
+	t, err := asTime(args[1])
+	if err != nil {
+		return nil, err
+	}
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch strings.ToLower(asString(args[0])) {
+	case "second":
+		return t.Truncate(time.Second), nil
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc), nil
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+	return nil, fmt.Errorf("unsupported unit %q", asString(args[0]))
+}
+
+func timePartFn(f func(time.Time) interface{}) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		t, err := asTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(t), nil
+	}
+}
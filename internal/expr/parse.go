@@ -0,0 +1,465 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports where in the expression parsing failed. Column is
+// 1-based.
+type SyntaxError struct {
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokKeyword
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var keywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true,
+	"null": true, "true": true, "false": true,
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' || src[i] == '_') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+					i++
+				}
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case c == '\'' || c == '"':
+			start := i
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(src) {
+					return nil, &SyntaxError{start + 1, "unterminated string"}
+				}
+				if rune(src[i]) == c {
+					// a doubled quote is an escaped quote, as in SQL
+					if i+1 < len(src) && rune(src[i+1]) == c {
+						b.WriteByte(src[i])
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				// other backslashes are kept, so regular expressions
+				// such as '\d+' need no extra escaping
+				if src[i] == '\\' && i+1 < len(src) {
+					switch src[i+1] {
+					case 'n':
+						b.WriteByte('\n')
+						i += 2
+						continue
+					case 't':
+						b.WriteByte('\t')
+						i += 2
+						continue
+					case '\\', '\'', '"':
+						b.WriteByte(src[i+1])
+						i += 2
+						continue
+					}
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			tokens = append(tokens, token{tokString, b.String(), start})
+		case c == '`':
+			start := i
+			end := strings.IndexByte(src[i+1:], '`')
+			if end < 0 {
+				return nil, &SyntaxError{start + 1, "unterminated quoted field name"}
+			}
+			tokens = append(tokens, token{tokIdent, src[i+1 : i+1+end], start})
+			i += end + 2
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] == '.' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			word := src[start:i]
+			if keywords[strings.ToLower(word)] {
+				tokens = append(tokens, token{tokKeyword, strings.ToLower(word), start})
+			} else {
+				tokens = append(tokens, token{tokIdent, word, start})
+			}
+		default:
+			start := i
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<>", "<=", ">=", "&&", "||", "<", ">", "=", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{start + 1, fmt.Sprintf("unexpected character %q", c)}
+			}
+			i += len(op)
+			tokens = append(tokens, token{tokOp, op, start})
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(src)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(kind tokenKind, texts ...string) bool {
+	t := p.peek()
+	if t.kind != kind {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return true
+		}
+	}
+	return len(texts) == 0
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &SyntaxError{t.pos + 1, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if !p.is(kind, text) {
+		return p.errorf(p.peek(), "expected %q, got %s", text, describe(p.peek()))
+	}
+	p.next()
+	return nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokKeyword, "or") || p.is(tokOp, "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokKeyword, "and") || p.is(tokOp, "&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.is(tokKeyword, "not") || p.is(tokOp, "!") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.is(tokOp, "==", "=", "!=", "<>", "<", "<=", ">", ">="):
+		op := p.next().text
+		switch op {
+		case "=":
+			op = "=="
+		case "<>":
+			op = "!="
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: op, left: left, right: right}, nil
+
+	case p.is(tokKeyword, "is"):
+		p.next()
+		negate := false
+		if p.is(tokKeyword, "not") {
+			p.next()
+			negate = true
+		}
+		if err := p.expect(tokKeyword, "null"); err != nil {
+			return nil, err
+		}
+		return &isNullNode{x: left, negate: negate}, nil
+
+	case p.is(tokKeyword, "in"), p.is(tokKeyword, "not") && p.tokens[p.pos+1].kind == tokKeyword && p.tokens[p.pos+1].text == "in":
+		negate := false
+		if p.is(tokKeyword, "not") {
+			p.next()
+			negate = true
+		}
+		p.next()
+		list, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{x: left, list: list, negate: negate}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOp, "+", "-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOp, "*", "/", "%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.is(tokOp, "-") {
+		p.next()
+		// a negative literal is parsed whole, so that the smallest int64
+		// stays an integer
+		if p.is(tokNumber) {
+			return p.parseNumber(p.next(), true)
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{op: "-", left: &literalNode{value: int64(0)}, right: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return p.parseNumber(t, false)
+
+	case tokString:
+		return &literalNode{value: t.text}, nil
+
+	case tokKeyword:
+		switch t.text {
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		}
+
+	case tokIdent:
+		if p.is(tokOp, "(") {
+			return p.parseCall(t)
+		}
+		return &fieldNode{name: t.text}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokOp, ")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			list := &listNode{}
+			for !p.is(tokOp, "]") {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+				if !p.is(tokOp, ",") {
+					break
+				}
+				p.next()
+			}
+			if err := p.expect(tokOp, "]"); err != nil {
+				return nil, err
+			}
+			return list, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", describe(t))
+}
+
+// parseNumber reads a number literal. Integers must fit in an int64;
+// literals with a decimal point or an exponent are floats.
+func (p *parser) parseNumber(t token, negative bool) (node, error) {
+	text := strings.ReplaceAll(t.text, "_", "")
+	if negative {
+		text = "-" + text
+	}
+	if !strings.ContainsAny(text, ".eE") {
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err == nil {
+			return &literalNode{value: i}, nil
+		}
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, p.errorf(t, "integer %s is out of range", text)
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf(t, "invalid number %q", t.text)
+	}
+	return &literalNode{value: f}, nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	p.next() // (
+	var args []node
+	for !p.is(tokOp, ")") {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.is(tokOp, ",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(tokOp, ")"); err != nil {
+		return nil, err
+	}
+
+	lower := strings.ToLower(name.text)
+	switch lower {
+	case "if":
+		if len(args) != 3 {
+			return nil, p.errorf(name, "if expects 3 arguments, got %d", len(args))
+		}
+		return &ifNode{cond: args[0], then: args[1], otherwise: args[2]}, nil
+	case "coalesce":
+		if len(args) == 0 {
+			return nil, p.errorf(name, "coalesce expects at least 1 argument")
+		}
+		return &coalesceNode{args: args}, nil
+	}
+
+	fn, ok := functions[lower]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+	if len(args) < fn.min || fn.max >= 0 && len(args) > fn.max {
+		return nil, p.errorf(name, "%s expects %s, got %d", lower, fn.arity(), len(args))
+	}
+	call := &callNode{name: lower, fn: fn, args: args}
+	if fn.prepare != nil {
+		if err := fn.prepare(call); err != nil {
+			return nil, p.errorf(name, "%s: %v", lower, err)
+		}
+	}
+	return call, nil
+}
@@ -0,0 +1,325 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the string forms accepted wherever a timestamp is
+// expected. Strings without a zone are UTC.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// normalize maps record values onto the types the evaluator works with.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int:
+		return int64(t)
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case uint:
+		return uintValue(uint64(t))
+	case uint8:
+		return int64(t)
+	case uint16:
+		return int64(t)
+	case uint32:
+		return int64(t)
+	case uint64:
+		return uintValue(t)
+	case float32:
+		return float64(t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	case []byte:
+		return string(t)
+	case *time.Time:
+		if t == nil {
+			return nil
+		}
+		return *t
+	case []string:
+		items := make([]interface{}, len(t))
+		for i, s := range t {
+			items[i] = s
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+func uintValue(u uint64) interface{} {
+	if u > math.MaxInt64 {
+		return float64(u)
+	}
+	return int64(u)
+}
+
+// number parses numeric strings so that "42" compares equal to 42 and can
+// take part in arithmetic.
+func number(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case int64, float64:
+		return v, true
+	case string:
+		s := strings.TrimSpace(t)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func parseTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func equal(l, r interface{}) bool {
+	switch lv := l.(type) {
+	case bool:
+		rv, ok := r.(bool)
+		return ok && lv == rv
+	case string:
+		if rv, ok := r.(string); ok {
+			return lv == rv
+		}
+	case time.Time:
+		if rv, ok := r.(time.Time); ok {
+			return lv.Equal(rv)
+		}
+	}
+	c, err := compare(l, r)
+	return err == nil && c == 0
+}
+
+func compare(l, r interface{}) (int, error) {
+	if lt, ok := l.(time.Time); ok {
+		return compareTime(lt, r)
+	}
+	if rt, ok := r.(time.Time); ok {
+		c, err := compareTime(rt, l)
+		return -c, err
+	}
+
+	ls, lstr := l.(string)
+	rs, rstr := r.(string)
+	if lstr && rstr {
+		return strings.Compare(ls, rs), nil
+	}
+
+	ln, lok := number(l)
+	rn, rok := number(r)
+	if lok && rok {
+		li, lint := ln.(int64)
+		ri, rint := rn.(int64)
+		if lint && rint {
+			switch {
+			case li < ri:
+				return -1, nil
+			case li > ri:
+				return 1, nil
+			}
+			return 0, nil
+		}
+		lf, rf := toFloat(ln), toFloat(rn)
+		switch {
+		case lf < rf:
+			return -1, nil
+		case lf > rf:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", typeName(l), typeName(r))
+}
+
+func compareTime(t time.Time, v interface{}) (int, error) {
+	var other time.Time
+	switch o := v.(type) {
+	case time.Time:
+		other = o
+	case string:
+		parsed, ok := parseTime(o)
+		if !ok {
+			return 0, fmt.Errorf("cannot compare timestamp with %q", o)
+		}
+		other = parsed
+	default:
+		return 0, fmt.Errorf("cannot compare timestamp with %s", typeName(v))
+	}
+	return t.Compare(other), nil
+}
+
+func arith(op string, l, r interface{}) (interface{}, error) {
+	if ls, ok := l.(string); ok && op == "+" {
+		if rs, ok := r.(string); ok {
+			return ls + rs, nil
+		}
+	}
+
+	// timestamp +/- seconds, and the difference of two timestamps in seconds
+	if lt, ok := l.(time.Time); ok {
+		if rt, ok := r.(time.Time); ok && op == "-" {
+			return lt.Sub(rt).Seconds(), nil
+		}
+		if n, ok := number(r); ok && (op == "+" || op == "-") {
+			d := time.Duration(toFloat(n) * float64(time.Second))
+			if op == "-" {
+				d = -d
+			}
+			return lt.Add(d), nil
+		}
+		return nil, fmt.Errorf("cannot apply %s to timestamp and %s", op, typeName(r))
+	}
+
+	ln, lok := number(l)
+	rn, rok := number(r)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", op, typeName(l), typeName(r))
+	}
+
+	li, lint := ln.(int64)
+	ri, rint := rn.(int64)
+	if lint && rint {
+		switch op {
+		case "+":
+			if s := li + ri; (s > li) == (ri > 0) {
+				return s, nil
+			}
+			return nil, fmt.Errorf("integer overflow in %d + %d", li, ri)
+		case "-":
+			if d := li - ri; (d < li) == (ri > 0) {
+				return d, nil
+			}
+			return nil, fmt.Errorf("integer overflow in %d - %d", li, ri)
+		case "*":
+			if p := li * ri; li == 0 || p/li == ri && !(li == -1 && ri == math.MinInt64) {
+				return p, nil
+			}
+			return nil, fmt.Errorf("integer overflow in %d * %d", li, ri)
+		case "%":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return li % ri, nil
+		}
+	}
+
+	lf, rf := toFloat(ln), toFloat(rn)
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	default:
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case int64:
+		return float64(t)
+	case float64:
+		return t
+	}
+	return 0
+}
+
+// asString converts a scalar to its string form.
+func asString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func asNumber(v interface{}) (interface{}, error) {
+	if n, ok := number(v); ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("expected a number, got %s", describeValue(v))
+}
+
+func asInt(v interface{}) (int64, error) {
+	n, err := asNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	if i, ok := n.(int64); ok {
+		return i, nil
+	}
+	f := n.(float64)
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("expected an integer, got %v", f)
+	}
+	return int64(f), nil
+}
+
+func asTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		if parsed, ok := parseTime(t); ok {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a timestamp, got %s", describeValue(v))
+}
+
+func describeValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("string %q", s)
+	}
+	return typeName(v)
+}
@@ -0,0 +1,123 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+const loginSchema = `{
+	"type": "record",
+	"name": "login",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "dealer", "type": "string"},
+		{"name": "amount", "type": ["null", "long"]},
+		{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 18, "scale": 2}},
+		{"name": "login_at", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+	]
+}`
+
+// writeAvroLogins writes the rows writeLogins does, without the tags.
+func writeAvroLogins(t *testing.T, path string) {
+	t.Helper()
+	at := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	enc, err := ocf.NewEncoder(loginSchema, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []map[string]interface{}{
+		{"id": int64(1), "dealer": "D1", "amount": int64(10), "price": big.NewRat(1250, 100), "login_at": at},
+		{"id": int64(2), "dealer": "D2", "amount": nil, "price": big.NewRat(-5, 100), "login_at": at.Add(time.Hour)},
+		{"id": int64(3), "dealer": "D1", "amount": nil, "price": big.NewRat(0, 1), "login_at": at},
+		{"id": int64(4), "dealer": "D3", "amount": nil, "price": big.NewRat(0, 1), "login_at": at},
+		{"id": int64(5), "dealer": "D2", "amount": int64(50), "price": big.NewRat(1, 1), "login_at": at},
+		{"id": int64(6), "dealer": "D1", "amount": int64(60), "price": big.NewRat(1, 1), "login_at": at},
+	} {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func avroConfig(path string, sel []string, where ...config.PredicateConfig) *config.PipelineConfig {
+	cfg := parquetConfig(path, sel, where...)
+	cfg.Source.Type = "avro"
+	return cfg
+}
+
+func TestAvroProjection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logins.avro")
+	writeAvroLogins(t, path)
+
+	out, err := extractAll(t, NewAvroExtractor(), avroConfig(path, []string{"id", "price", "login_at"},
+		config.PredicateConfig{Column: "dealer", Op: "=", Value: "D2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("got %v, want ids 2 and 5", out)
+	}
+	for _, r := range out {
+		if _, ok := r["dealer"]; ok || len(r) != 4 {
+			t.Errorf("record %v, want id, price, login_at and the source table", r)
+		}
+	}
+	if out[0]["id"] != int64(2) || out[0]["price"] != json.Number("-0.05") ||
+		!out[0]["login_at"].(time.Time).Equal(time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("first record %v", out[0])
+	}
+	if out[1]["price"] != json.Number("1") || out[1][pipeline.FieldSourceTable] != path {
+		t.Errorf("second record %v", out[1])
+	}
+
+	out, err = extractAll(t, NewAvroExtractor(), avroConfig(path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 6 || out[0]["amount"] != int64(10) || out[1]["amount"] != nil || out[0]["price"] != json.Number("12.5") {
+		t.Fatalf("got %v", out)
+	}
+}
+
+func TestAvroWhereOnNullableColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logins.avro")
+	writeAvroLogins(t, path)
+
+	for _, tc := range []struct {
+		where config.PredicateConfig
+		ids   []int64
+	}{
+		// nulls match no comparison, not even !=
+		{config.PredicateConfig{Column: "amount", Op: "!=", Value: 10}, []int64{5, 6}},
+		{config.PredicateConfig{Column: "amount", Op: "<", Value: "55"}, []int64{1, 5}},
+		{config.PredicateConfig{Column: "amount", Op: ">=", Value: 60.0}, []int64{6}},
+		{config.PredicateConfig{Column: "price", Op: "<", Value: "0.5"}, []int64{2, 3, 4}},
+		{config.PredicateConfig{Column: "login_at", Op: ">", Value: "2026-10-17T10:00:00Z"}, []int64{2}},
+		{config.PredicateConfig{Column: "region", Op: "=", Value: "west"}, []int64{}},
+	} {
+		out, err := extractAll(t, NewAvroExtractor(), avroConfig(path, nil, tc.where))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(out); fmt.Sprint(got) != fmt.Sprint(tc.ids) {
+			t.Errorf("%v gave ids %v, want %v", tc.where, got, tc.ids)
+		}
+	}
+}
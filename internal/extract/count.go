@@ -0,0 +1,37 @@
+package extract
+
+import (
+	"database/sql"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+)
+
+// ChecksumRows counts rows and takes the checksum of their values, in
+// column order. The driver is the one the database was opened with.
+func ChecksumRows(rows *sql.Rows, driver string) (int64, pipeline.Checksum, error) {
+	scanner, err := NewRowScanner(rows, driver)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		n   int64
+		sum pipeline.Checksum
+	)
+	values := make([]interface{}, len(scanner.Columns()))
+	for rows.Next() {
+		record, err := scanner.Scan(rows)
+		if err != nil {
+			return 0, 0, err
+		}
+		for i, col := range scanner.Columns() {
+			values[i] = record[col]
+		}
+		sum.Add(values)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	return n, sum, nil
+}
@@ -0,0 +1,426 @@
+package extract
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const defaultInferRows = 100
+
+// CSVExtractor reads delimited text files. Column types come from the
+// declared source columns, and any column without a declared type is
+// inferred from the first rows of each file.
+type CSVExtractor struct {
+	config    *config.PipelineConfig
+	delimiter rune
+	parser    *csvParser
+	files     []string
+}
+
+func NewCSVExtractor() *CSVExtractor {
+	return &CSVExtractor{delimiter: ','}
+}
+
+func NewTSVExtractor() *CSVExtractor {
+	return &CSVExtractor{delimiter: '\t'}
+}
+
+func (e *CSVExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+
+	parser, err := newCSVParser(cfg, e.delimiter)
+	if err != nil {
+		return err
+	}
+	e.parser = parser
+
+	patterns := cfg.Source.Files()
+	if len(patterns) == 0 {
+		return fmt.Errorf("%s source requires path or paths", cfg.Source.Type)
+	}
+	files, err := storage.Expand(ctx, patterns)
+	if err != nil {
+		return err
+	}
+	e.files = files
+
+	return nil
+}
+
+func (e *CSVExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		for _, file := range e.files {
+			if err := e.extractFile(ctx, file, sendTo(ctx, records)); err != nil {
+				sendError(ctx, errs, err)
+				return
+			}
+		}
+		e.parser.rejects.report()
+	}()
+
+	return records, errs
+}
+
+// ExtractBatches reads the files as Extract does, passing the records on in
+// batches.
+func (e *CSVExtractor) ExtractBatches(ctx context.Context) (<-chan pipeline.Batch, <-chan error) {
+	batches := make(chan pipeline.Batch)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(batches)
+		defer close(errs)
+		sender := pipeline.NewBatchSender(ctx, batches, e.config.Pipeline.Batch)
+		defer sender.Stop()
+
+		for _, file := range e.files {
+			if err := e.extractFile(ctx, file, sender.Send); err != nil {
+				sendError(ctx, errs, err)
+				return
+			}
+		}
+		sender.Flush()
+		e.parser.rejects.report()
+	}()
+
+	return batches, errs
+}
+
+func (e *CSVExtractor) extractFile(ctx context.Context, file string, send func(pipeline.DataRecord) error) error {
+	rc, err := storage.OpenDecompressed(ctx, file, e.config.Source.Compression)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return e.parser.parse(ctx, rc, file, send)
+}
+
+func (e *CSVExtractor) Close() error {
+	if e.parser != nil {
+		return e.parser.rejects.Close()
+	}
+	return nil
+}
+
+// csvParser turns a stream of delimited text into records. It is shared by
+// every extractor that receives CSV files, whatever their transport.
+type csvParser struct {
+	delimiter rune
+	quoting   string
+	header    bool
+	skipRows  int
+	encoding  encoding.Encoding
+	inferRows int
+	nulls     map[string]bool
+	declared  map[string]string
+	columns   []string
+	rejects   *rowPolicy
+}
+
+func newCSVParser(cfg *config.PipelineConfig, delimiter rune) (*csvParser, error) {
+	src := cfg.Source
+	opts := src.CSV
+	p := &csvParser{
+		delimiter: delimiter,
+		quoting:   strings.ToLower(opts.Quoting),
+		header:    opts.Header == nil || *opts.Header,
+		skipRows:  opts.SkipRows,
+		inferRows: opts.InferRows,
+		nulls:     map[string]bool{"": true},
+		declared:  make(map[string]string),
+	}
+
+	if opts.Delimiter != "" {
+		d := opts.Delimiter
+		if d == `\t` {
+			d = "\t"
+		}
+		r, size := utf8.DecodeRuneInString(d)
+		if size != len(d) || r == '\n' || r == '\r' || r == '"' {
+			return nil, fmt.Errorf("invalid csv delimiter %q", opts.Delimiter)
+		}
+		p.delimiter = r
+	}
+
+	switch p.quoting {
+	case "", "standard", "lazy", "none":
+	default:
+		return nil, fmt.Errorf("unsupported csv quoting: %s", opts.Quoting)
+	}
+
+	if p.inferRows == 0 {
+		p.inferRows = defaultInferRows
+	}
+
+	if opts.Encoding != "" && !strings.EqualFold(opts.Encoding, "utf-8") && !strings.EqualFold(opts.Encoding, "utf8") {
+		enc, err := htmlindex.Get(opts.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported csv encoding %s: %w", opts.Encoding, err)
+		}
+		p.encoding = enc
+	}
+
+	for _, v := range opts.NullValues {
+		p.nulls[v] = true
+	}
+
+	for _, col := range src.Columns {
+		kind, err := valueKind(col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		p.declared[col.Name] = kind
+		p.columns = append(p.columns, col.Name)
+	}
+
+	rejects, err := newRowPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.rejects = rejects
+
+	return p, nil
+}
+
+func (p *csvParser) decoder(r io.Reader) io.Reader {
+	if p.encoding == nil {
+		return transform.NewReader(r, unicode.BOMOverride(encoding.Nop.NewDecoder()))
+	}
+	return transform.NewReader(r, unicode.BOMOverride(p.encoding.NewDecoder()))
+}
+
+type csvRow struct {
+	fields []string
+	line   int
+}
+
+// parse passes each row of r to send as a record. Rows that cannot be
+// parsed are handled by the source's on_error policy.
+func (p *csvParser) parse(ctx context.Context, r io.Reader, name string, send func(pipeline.DataRecord) error) error {
+	rows := p.rowReader(p.decoder(r))
+
+	next := func() (csvRow, error) {
+		for {
+			fields, line, err := rows()
+			var perr *csv.ParseError
+			if errors.As(err, &perr) && !errors.Is(perr.Err, csv.ErrFieldCount) {
+				if err := p.rejects.reject(name, perr.Line, nil, perr.Err); err != nil {
+					return csvRow{}, err
+				}
+				continue
+			}
+			if err != nil {
+				return csvRow{}, err
+			}
+			return csvRow{fields: fields, line: line}, nil
+		}
+	}
+
+	for i := 0; i < p.skipRows; i++ {
+		if _, err := next(); err != nil {
+			return ignoreEOF(err, name)
+		}
+	}
+
+	columns := p.columns
+	if p.header {
+		row, err := next()
+		if err != nil {
+			return ignoreEOF(err, name)
+		}
+		columns = make([]string, len(row.fields))
+		for i, f := range row.fields {
+			columns[i] = strings.TrimSpace(f)
+		}
+	}
+
+	var buffered []csvRow
+	for len(buffered) < p.inferRows {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return withName(err, name)
+		}
+		buffered = append(buffered, row)
+	}
+
+	if columns == nil && len(buffered) > 0 {
+		columns = make([]string, len(buffered[0].fields))
+		for i := range columns {
+			columns[i] = fmt.Sprintf("column_%d", i+1)
+		}
+	}
+	kinds := p.columnKinds(columns, buffered)
+
+	emit := func(row csvRow) error {
+		record, err := p.record(columns, kinds, row)
+		if err != nil {
+			return p.rejects.reject(name, row.line, pipeline.DataRecord{"_fields": row.fields}, err)
+		}
+		record[pipeline.FieldSourceTable] = name
+		return send(record)
+	}
+
+	for _, row := range buffered {
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+	for {
+		row, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return withName(err, name)
+		}
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+}
+
+func ignoreEOF(err error, name string) error {
+	if err == io.EOF {
+		return nil
+	}
+	return withName(err, name)
+}
+
+// withName prefixes a read error with the file name. Rejected rows already
+// name their file and line.
+func withName(err error, name string) error {
+	if pipeline.IsPermanent(err) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+func (p *csvParser) columnKinds(columns []string, sample []csvRow) []string {
+	kinds := make([]string, len(columns))
+	for i, col := range columns {
+		if kind, ok := p.declared[col]; ok {
+			kinds[i] = kind
+			continue
+		}
+		if p.inferRows < 0 {
+			kinds[i] = kindString
+			continue
+		}
+
+		values := make([]string, 0, len(sample))
+		for _, row := range sample {
+			if i < len(row.fields) && !p.nulls[row.fields[i]] {
+				values = append(values, row.fields[i])
+			}
+		}
+		kinds[i] = inferKind(values)
+	}
+	return kinds
+}
+
+func (p *csvParser) record(columns, kinds []string, row csvRow) (pipeline.DataRecord, error) {
+	if len(row.fields) != len(columns) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(columns), len(row.fields))
+	}
+
+	record := make(pipeline.DataRecord, len(columns)+1)
+	for i, col := range columns {
+		field := row.fields[i]
+		if p.nulls[field] {
+			record[col] = nil
+			continue
+		}
+		v, err := parseValue(field, kinds[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: cannot parse %q as %s", col, field, kinds[i])
+		}
+		record[col] = v
+	}
+	return record, nil
+}
+
+// rowReader returns a function yielding the fields and starting line of each
+// row until io.EOF.
+func (p *csvParser) rowReader(r io.Reader) func() ([]string, int, error) {
+	if p.quoting == "none" {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		sep := string(p.delimiter)
+		line := 0
+		return func() ([]string, int, error) {
+			for scanner.Scan() {
+				line++
+				text := strings.TrimSuffix(scanner.Text(), "\r")
+				if text == "" {
+					continue
+				}
+				return strings.Split(text, sep), line, nil
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, line, err
+			}
+			return nil, line, io.EOF
+		}
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = p.delimiter
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = p.quoting == "lazy"
+	return func() ([]string, int, error) {
+		fields, err := cr.Read()
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		return fields, line, nil
+	}
+}
+
+// sendError reports an error without blocking past cancellation. It returns
+// false once the context is done.
+func sendError(ctx context.Context, errs chan<- error, err error) bool {
+	select {
+	case errs <- err:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendTo returns a send function that passes each record on records until
+// the context is done.
+func sendTo(ctx context.Context, records chan<- pipeline.DataRecord) func(pipeline.DataRecord) error {
+	return func(record pipeline.DataRecord) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case records <- record:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package extract
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extractAll runs the extractor to the end and returns its records and the
+// first error.
+func extractAll(t *testing.T, e pipeline.Extractor, cfg *config.PipelineConfig) ([]pipeline.DataRecord, error) {
+	t.Helper()
+	ctx := context.Background()
+	if err := e.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	records, errs := e.Extract(ctx)
+	var out []pipeline.DataRecord
+	for r := range records {
+		out = append(out, r)
+	}
+	return out, <-errs
+}
+
+func TestCSVInfersTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "dealers.csv"),
+		"dealer_code,dealer_id,amount,active,opened,note\n"+
+			"0001,17,1.5,true,2026-10-17,\"a, quoted\"\n"+
+			"0420,-3,2,false,2026-10-18 09:30:00,NULL\n"+
+			"9000,,3.25,true,,plain\n")
+
+	cfg := &config.PipelineConfig{}
+	cfg.Source.Type = "csv"
+	cfg.Source.Path = filepath.Join(dir, "*.csv")
+	cfg.Source.CSV.NullValues = []string{"NULL"}
+	out, err := extractAll(t, NewCSVExtractor(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("got %d records, want 3", len(out))
+	}
+
+	first := out[0]
+	if first["dealer_code"] != "0001" || out[1]["dealer_code"] != "0420" {
+		t.Errorf("dealer codes %v, %v: leading zeros must be kept", first["dealer_code"], out[1]["dealer_code"])
+	}
+	if first["dealer_id"] != int64(17) || out[2]["dealer_id"] != nil {
+		t.Errorf("dealer_id %#v, %#v", first["dealer_id"], out[2]["dealer_id"])
+	}
+	if first["amount"] != 1.5 || out[1]["amount"] != float64(2) {
+		t.Errorf("amount %#v, %#v", first["amount"], out[1]["amount"])
+	}
+	if first["active"] != true {
+		t.Errorf("active %#v", first["active"])
+	}
+	if opened, ok := out[1]["opened"].(time.Time); !ok || opened.Hour() != 9 {
+		t.Errorf("opened %#v", out[1]["opened"])
+	}
+	if first["note"] != "a, quoted" || out[1]["note"] != nil {
+		t.Errorf("note %#v, %#v", first["note"], out[1]["note"])
+	}
+	if first[pipeline.FieldSourceTable] != filepath.Join(dir, "dealers.csv") {
+		t.Errorf("source table %v", first[pipeline.FieldSourceTable])
+	}
+}
+
+func TestInferKind(t *testing.T) {
+	for _, tc := range []struct {
+		samples []string
+		want    string
+	}{
+		{[]string{"1", "-2", "+3"}, kindInt},
+		{[]string{"1", "2.5"}, kindFloat},
+		{[]string{"0", "0.5", "10"}, kindFloat},
+		{[]string{"0001", "12"}, kindString},
+		{[]string{"-007"}, kindString},
+		{[]string{"00.5"}, kindString},
+		{[]string{"true", "FALSE"}, kindBool},
+		{[]string{"2026-10-17", "2026-10-17T09:30:00Z"}, kindTimestamp},
+		{[]string{"1", "x"}, kindString},
+		{[]string{"", ""}, kindString},
+	} {
+		if got := inferKind(tc.samples); got != tc.want {
+			t.Errorf("inferKind(%q) = %s, want %s", tc.samples, got, tc.want)
+		}
+	}
+}
+
+func TestTSVDeclaredColumnsGzipAndEncoding(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "dealers.tsv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	// windows-1252: 0xE9 is é
+	zw.Write([]byte("skip me\ndealer_code\tname\n0001\tRen\xe9\n"))
+	zw.Close()
+	f.Close()
+
+	cfg := &config.PipelineConfig{}
+	cfg.Source.Type = "tsv"
+	cfg.Source.Path = filepath.Join(dir, "*.tsv.gz")
+	cfg.Source.Columns = []config.ColumnConfig{{Name: "dealer_code", Type: "INTEGER"}}
+	cfg.Source.CSV.SkipRows = 1
+	cfg.Source.CSV.Encoding = "windows-1252"
+	out, err := extractAll(t, NewTSVExtractor(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0]["dealer_code"] != int64(1) || out[0]["name"] != "René" {
+		t.Fatalf("got %v", out)
+	}
+}
+
+func TestCSVBadRows(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "dealers.csv"),
+		"dealer_id,amount\n"+
+			"1,10\n"+
+			"2,x\n"+ // amount is not a number
+			"3\n"+ // missing field
+			"4,40\n")
+	newConfig := func(onError string) *config.PipelineConfig {
+		cfg := &config.PipelineConfig{}
+		cfg.Pipeline.Name = "dealers"
+		cfg.Pipeline.DeadLetter.Path = filepath.Join(dir, "dead", "dealers.jsonl")
+		cfg.Source.Type = "csv"
+		cfg.Source.Path = filepath.Join(dir, "dealers.csv")
+		cfg.Source.Columns = []config.ColumnConfig{{Name: "amount", Type: "int"}}
+		cfg.Source.OnError = onError
+		return cfg
+	}
+
+	out, err := extractAll(t, NewCSVExtractor(), newConfig(""))
+	if err == nil || !strings.Contains(err.Error(), "dealers.csv:3:") || !pipeline.IsPermanent(err) {
+		t.Fatalf("got %v, want a permanent error naming line 3", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("passed %d records before the bad row, want 1", len(out))
+	}
+
+	out, err = extractAll(t, NewCSVExtractor(), newConfig("skip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[1]["dealer_id"] != int64(4) {
+		t.Fatalf("got %v, want the two good rows", out)
+	}
+
+	out, err = extractAll(t, NewCSVExtractor(), newConfig("dead_letter"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("got %d records, want 2", len(out))
+	}
+	f, err := os.Open(newConfig("").Pipeline.DeadLetter.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry pipeline.DeadLetterEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		line, _ := entry.Record["_line"].(float64)
+		lines = append(lines, int(line))
+	}
+	if len(lines) != 2 || lines[0] != 3 || lines[1] != 4 {
+		t.Fatalf("dead-lettered lines %v, want 3 and 4", lines)
+	}
+
+	if err := NewCSVExtractor().Init(context.Background(), newConfig("ignore")); err == nil {
+		t.Fatal("unsupported on_error accepted")
+	}
+}
+
+func TestCSVQuotingErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "dealers.csv"), "id,note\n1,\"unterminated\n")
+	cfg := &config.PipelineConfig{}
+	cfg.Source.Type = "csv"
+	cfg.Source.Path = filepath.Join(dir, "dealers.csv")
+
+	if _, err := extractAll(t, NewCSVExtractor(), cfg); err == nil || !strings.Contains(err.Error(), "dealers.csv:2:") {
+		t.Fatalf("got %v, want the line of the bad quote", err)
+	}
+
+	cfg.Source.CSV.Quoting = "none"
+	out, err := extractAll(t, NewCSVExtractor(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0]["note"] != "\"unterminated" {
+		t.Fatalf("got %v", out)
+	}
+}
+
+func TestCSVExtractBatches(t *testing.T) {
+	dir := t.TempDir()
+	var b strings.Builder
+	b.WriteString("id\n")
+	for i := 0; i < 25; i++ {
+		b.WriteString("7\n")
+	}
+	writeFile(t, filepath.Join(dir, "ids.csv"), b.String())
+
+	cfg := &config.PipelineConfig{}
+	cfg.Source.Type = "csv"
+	cfg.Source.Path = filepath.Join(dir, "ids.csv")
+	cfg.Pipeline.Batch = config.BatchConfig{Size: 10}
+
+	e := NewCSVExtractor()
+	if err := e.Init(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	batches, errs := e.ExtractBatches(context.Background())
+	var sizes []int
+	for batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 10 || sizes[2] != 5 {
+		t.Fatalf("batch sizes %v, want 10, 10, 5", sizes)
+	}
+}
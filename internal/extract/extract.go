@@ -0,0 +1,35 @@
+package extract
+
+import (
+	"fmt"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+)
+
+// New returns the extractor registered for the given source type.
+func New(sourceType string) (pipeline.Extractor, error) {
+	switch sourceType {
+	case "sqlserver":
+		return NewSQLServerExtractor(), nil
+	case "sqlite":
+		return NewSQLiteExtractor(), nil
+	case "csv":
+		return NewCSVExtractor(), nil
+	case "tsv":
+		return NewTSVExtractor(), nil
+	case "jsonl":
+		return NewJSONLExtractor(), nil
+	case "parquet":
+		return NewParquetExtractor(), nil
+	case "avro":
+		return NewAvroExtractor(), nil
+	case "http":
+		return NewHTTPExtractor(), nil
+	case "kafka":
+		return NewKafkaExtractor(), nil
+	case "sftp":
+		return NewSFTPExtractor(), nil
+	default:
+		return nil, fmt.Errorf("unsupported source type: %s", sourceType)
+	}
+}
@@ -0,0 +1,314 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/aniketwaliyan/etl-framework/internal/httpclient"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// HTTPExtractor pulls JSON records from a REST API, following the
+// configured pagination strategy until the API runs out of records.
+type HTTPExtractor struct {
+	config  *config.PipelineConfig
+	client  *httpclient.Client
+	url     *template.Template
+	records jsonPath
+	cursor  jsonPath
+}
+
+// urlParams are the values available to the url template.
+type urlParams struct {
+	Page   int
+	Offset int
+	Limit  int
+	Cursor string
+}
+
+func NewHTTPExtractor() *HTTPExtractor {
+	return &HTTPExtractor{}
+}
+
+func (e *HTTPExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+	opts := cfg.Source.HTTP
+
+	if opts.URL == "" {
+		return fmt.Errorf("http source requires a url")
+	}
+	tmpl, err := template.New("url").Funcs(httpclient.URLFuncs).Parse(opts.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url template: %w", err)
+	}
+	e.url = tmpl
+
+	recordsPath := opts.RecordsPath
+	if recordsPath == "" {
+		recordsPath = "$"
+	}
+	if e.records, err = compileJSONPath(recordsPath); err != nil {
+		return err
+	}
+
+	p := opts.Pagination
+	switch p.Type {
+	case "", "none", "page", "offset", "link":
+	case "cursor":
+		if p.CursorPath == "" {
+			return fmt.Errorf("cursor pagination requires cursor_path")
+		}
+		if e.cursor, err = compileJSONPath(p.CursorPath); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported pagination type: %s", p.Type)
+	}
+
+	client, err := httpclient.New(opts)
+	if err != nil {
+		return err
+	}
+	e.client = client
+
+	return nil
+}
+
+func (e *HTTPExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		if err := e.extractPages(ctx, records); err != nil {
+			sendError(ctx, errs, err)
+		}
+	}()
+
+	return records, errs
+}
+
+func (e *HTTPExtractor) extractPages(ctx context.Context, records chan<- pipeline.DataRecord) error {
+	p := e.config.Source.HTTP.Pagination
+	params := urlParams{Page: p.StartPage, Limit: p.PageSize}
+	if p.Type == "page" && p.StartPage == 0 {
+		params.Page = 1
+	}
+
+	method := e.config.Source.HTTP.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	next := ""
+	total := 0
+	// A server that keeps returning the same cursor or next link would
+	// otherwise be paged through forever.
+	fetched := make(map[string]bool)
+	for page := 1; p.MaxPages == 0 || page <= p.MaxPages; page++ {
+		reqURL := next
+		if reqURL == "" {
+			var err error
+			if reqURL, err = e.requestURL(params); err != nil {
+				return err
+			}
+		}
+		if fetched[reqURL] {
+			return fmt.Errorf("pagination returned to %s after %d pages", httpclient.Redact(reqURL), page-1)
+		}
+		fetched[reqURL] = true
+
+		resp, body, err := e.client.Do(ctx, method, reqURL, nil, http.Header{"Accept": {"application/json"}})
+		if err != nil {
+			return fmt.Errorf("request %s failed: %w", httpclient.Redact(reqURL), err)
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("invalid json from %s: %w", httpclient.Redact(reqURL), err)
+		}
+
+		n, err := e.emit(ctx, doc, reqURL, records)
+		if err != nil {
+			return err
+		}
+		total += n
+
+		switch p.Type {
+		case "page":
+			if n == 0 || (p.PageSize > 0 && n < p.PageSize) {
+				return e.done(page, total)
+			}
+			params.Page++
+		case "offset":
+			if n == 0 || (p.PageSize > 0 && n < p.PageSize) {
+				return e.done(page, total)
+			}
+			params.Offset += n
+		case "cursor":
+			cursor := e.cursor.first(doc)
+			if cursor == nil || fmt.Sprint(cursor) == "" {
+				return e.done(page, total)
+			}
+			params.Cursor = fmt.Sprint(cursor)
+		case "link":
+			link := nextLink(resp.Header.Values("Link"))
+			if link == "" {
+				return e.done(page, total)
+			}
+			resolved, err := resolveURL(reqURL, link)
+			if err != nil {
+				return err
+			}
+			next = resolved
+		default:
+			return e.done(page, total)
+		}
+	}
+
+	log.Printf("Stopped after max_pages=%d (%d records)", p.MaxPages, total)
+	return nil
+}
+
+func (e *HTTPExtractor) done(pages, total int) error {
+	log.Printf("Fetched %d records in %d pages", total, pages)
+	return nil
+}
+
+func (e *HTTPExtractor) emit(ctx context.Context, doc interface{}, source string, records chan<- pipeline.DataRecord) (int, error) {
+	var items []interface{}
+	for _, v := range e.records.find(doc) {
+		if list, ok := v.([]interface{}); ok {
+			items = append(items, list...)
+		} else {
+			items = append(items, v)
+		}
+	}
+
+	if u, err := url.Parse(source); err == nil {
+		u.RawQuery = ""
+		source = u.String()
+	}
+
+	for i, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return i, fmt.Errorf("record %d from %s is %T, not an object", i, source, item)
+		}
+		record := pipeline.DataRecord(obj)
+		record[pipeline.FieldSourceTable] = source
+
+		select {
+		case <-ctx.Done():
+			return i, ctx.Err()
+		case records <- record:
+		}
+	}
+	return len(items), nil
+}
+
+// requestURL renders the url template and adds the pagination parameters
+// that the template does not already contain.
+func (e *HTTPExtractor) requestURL(params urlParams) (string, error) {
+	var buf bytes.Buffer
+	if err := e.url.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("failed to render url: %w", err)
+	}
+	u, err := url.Parse(buf.String())
+	if err != nil {
+		return "", fmt.Errorf("invalid url %s: %w", httpclient.Redact(buf.String()), err)
+	}
+
+	p := e.config.Source.HTTP.Pagination
+	q := u.Query()
+	set := func(name, value string) {
+		if name != "" && !q.Has(name) {
+			q.Set(name, value)
+		}
+	}
+
+	switch p.Type {
+	case "page":
+		set(defaultString(p.PageParam, "page"), strconv.Itoa(params.Page))
+		if p.PageSize > 0 {
+			set(p.SizeParam, strconv.Itoa(p.PageSize))
+		}
+	case "offset":
+		set(defaultString(p.OffsetParam, "offset"), strconv.Itoa(params.Offset))
+		if p.PageSize > 0 {
+			set(defaultString(p.SizeParam, "limit"), strconv.Itoa(p.PageSize))
+		}
+	case "cursor":
+		if params.Cursor != "" {
+			set(defaultString(p.CursorParam, "cursor"), params.Cursor)
+		}
+		if p.PageSize > 0 {
+			set(p.SizeParam, strconv.Itoa(p.PageSize))
+		}
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (e *HTTPExtractor) Close() error {
+	return nil
+}
+
+func defaultString(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// nextLink returns the target of the rel="next" entry of RFC 8288 Link
+// headers.
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			segments := strings.Split(part, ";")
+			target := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range segments[1:] {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid next link %s: %w", ref, err)
+	}
+	return b.ResolveReference(r).String(), nil
+}
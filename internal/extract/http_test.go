@@ -0,0 +1,177 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func httpSourceConfig(url string, p config.PaginationConfig) *config.PipelineConfig {
+	cfg := &config.PipelineConfig{}
+	cfg.Source.HTTP = config.HTTPConfig{
+		URL:         url,
+		RecordsPath: "$.items[*]",
+		RetryDelay:  time.Millisecond,
+		Pagination:  p,
+	}
+	return cfg
+}
+
+func extractHTTP(cfg *config.PipelineConfig) ([]pipeline.DataRecord, error) {
+	ctx := context.Background()
+	e := NewHTTPExtractor()
+	if err := e.Init(ctx, cfg); err != nil {
+		return nil, err
+	}
+	defer e.Close()
+
+	records, errs := e.Extract(ctx)
+	var out []pipeline.DataRecord
+	for r := range records {
+		out = append(out, r)
+	}
+	return out, <-errs
+}
+
+// items renders ids from..to-1 as a response body, with extra members.
+func items(from, to int, extra string) string {
+	ids := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		ids = append(ids, fmt.Sprintf(`{"id": %d}`, i))
+	}
+	body := `{"items": [` + strings.Join(ids, ",") + `]`
+	if extra != "" {
+		body += "," + extra
+	}
+	return body + "}"
+}
+
+func checkIDs(t *testing.T, records []pipeline.DataRecord, n int) {
+	t.Helper()
+	if len(records) != n {
+		t.Fatalf("got %d records, want %d", len(records), n)
+	}
+	for i, r := range records {
+		if fmt.Sprint(r["id"]) != strconv.Itoa(i) {
+			t.Fatalf("record %d has id %v", i, r["id"])
+		}
+	}
+}
+
+func TestHTTPPagePagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		from := (page - 1) * size
+		to := from + size
+		if to > 7 {
+			to = 7
+		}
+		if from > to {
+			from = to
+		}
+		fmt.Fprint(w, items(from, to, ""))
+	}))
+	defer srv.Close()
+
+	records, err := extractHTTP(httpSourceConfig(srv.URL, config.PaginationConfig{
+		Type: "page", PageSize: 3, SizeParam: "size",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, records, 7)
+}
+
+func TestHTTPCursorPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprint(w, items(0, 2, `"next": "c1"`))
+		case "c1":
+			fmt.Fprint(w, items(2, 4, `"next": "c2"`))
+		case "c2":
+			fmt.Fprint(w, items(4, 5, `"next": ""`))
+		default:
+			http.Error(w, "unknown cursor", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	records, err := extractHTTP(httpSourceConfig(srv.URL, config.PaginationConfig{
+		Type: "cursor", CursorParam: "after", CursorPath: "$.next",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, records, 5)
+}
+
+func TestHTTPLinkPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		if page < 2 {
+			w.Header().Add("Link", fmt.Sprintf(`</items?p=%d>; rel="next", </items?p=0>; rel="first"`, page+1))
+		}
+		fmt.Fprint(w, items(page*2, page*2+2, ""))
+	}))
+	defer srv.Close()
+
+	records, err := extractHTTP(httpSourceConfig(srv.URL+"/items", config.PaginationConfig{Type: "link"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, records, 6)
+}
+
+func TestHTTPRepeatedCursorFails(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, items(0, 1, `"next": "same"`))
+	}))
+	defer srv.Close()
+
+	_, err := extractHTTP(httpSourceConfig(srv.URL, config.PaginationConfig{
+		Type: "cursor", CursorPath: "$.next",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "pagination returned to") {
+		t.Fatalf("expected a pagination loop error, got %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("server got %d requests, want 2", requests)
+	}
+}
+
+func TestHTTPURLSecretIsNotLogged(t *testing.T) {
+	t.Setenv("DEALER_API_KEY", "s3cr3t")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "s3cr3t" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	_, err := extractHTTP(httpSourceConfig(srv.URL+`/dealers?key={{secret "env:DEALER_API_KEY"}}`, config.PaginationConfig{}))
+	if err == nil || !strings.Contains(err.Error(), "unexpected status 400") {
+		t.Fatalf("got %v, want the request to carry the key", err)
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("error %q shows the key", err)
+	}
+
+	_, err = extractHTTP(httpSourceConfig(`http://127.0.0.1:1/?key={{secret "env:MISSING_API_KEY"}}`, config.PaginationConfig{}))
+	if err == nil || !strings.Contains(err.Error(), "MISSING_API_KEY is not set") {
+		t.Errorf("got %v, want the unset variable reported", err)
+	}
+}
@@ -0,0 +1,154 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const maxJSONLineSize = 64 * 1024 * 1024
+
+// stdinPath is accepted in place of a file to read from standard input.
+const stdinPath = "-"
+
+// JSONLExtractor streams newline-delimited JSON objects. Numbers are kept as
+// json.Number so that large integers and decimals round-trip exactly.
+type JSONLExtractor struct {
+	config  *config.PipelineConfig
+	files   []string
+	rejects *rowPolicy
+}
+
+func NewJSONLExtractor() *JSONLExtractor {
+	return &JSONLExtractor{}
+}
+
+func (e *JSONLExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+
+	rejects, err := newRowPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	e.rejects = rejects
+
+	patterns := cfg.Source.Files()
+	if len(patterns) == 0 {
+		return fmt.Errorf("jsonl source requires path or paths")
+	}
+	if len(patterns) == 1 && patterns[0] == stdinPath {
+		e.files = patterns
+		return nil
+	}
+
+	files, err := storage.Expand(ctx, patterns)
+	if err != nil {
+		return err
+	}
+	e.files = files
+
+	return nil
+}
+
+func (e *JSONLExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		for _, file := range e.files {
+			if err := e.extractFile(ctx, file, sendTo(ctx, records)); err != nil {
+				sendError(ctx, errs, err)
+				return
+			}
+		}
+		e.rejects.report()
+	}()
+
+	return records, errs
+}
+
+func (e *JSONLExtractor) extractFile(ctx context.Context, file string, send func(pipeline.DataRecord) error) error {
+	if file == stdinPath {
+		return parseJSONL(os.Stdin, "stdin", e.rejects, send)
+	}
+
+	rc, err := storage.OpenDecompressed(ctx, file, e.config.Source.Compression)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return parseJSONL(rc, file, e.rejects, send)
+}
+
+func (e *JSONLExtractor) Close() error {
+	if e.rejects != nil {
+		return e.rejects.Close()
+	}
+	return nil
+}
+
+// parseJSONL sends one record per non-blank line of r. Lines that are not a
+// JSON object are handled by rejects, which is given their text. A source
+// table already present in the record, as in replayed archives, is kept.
+func parseJSONL(r io.Reader, name string, rejects *rowPolicy, send func(pipeline.DataRecord) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		record, err := decodeJSONRecord(data)
+		if err != nil {
+			if err := rejects.reject(name, line, pipeline.DataRecord{"_text": string(data)}, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := record[pipeline.FieldSourceTable]; !ok {
+			record[pipeline.FieldSourceTable] = name
+		}
+
+		if err := send(record); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s:%d: %w", name, line+1, err)
+	}
+	return nil
+}
+
+func decodeJSONRecord(data []byte) (pipeline.DataRecord, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var record pipeline.DataRecord
+	if err := dec.Decode(&record); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("expected a json object")
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after json object")
+	}
+	return record, nil
+}
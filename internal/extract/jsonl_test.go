@@ -0,0 +1,112 @@
+package extract
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestJSONLKeepsNumbersAndSourceTable(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jsonl"),
+		`{"id": 9007199254740993, "amount": 12.10, "tags": ["x"]}`+"\n"+
+			"\n"+
+			`  {"id": 2, "_source_table": "dealers"}  `+"\n")
+	f, err := os.Create(filepath.Join(dir, "b.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`{"id": 3}` + "\n"))
+	gz.Close()
+	f.Close()
+
+	cfg := &config.PipelineConfig{}
+	cfg.Source.Type = "jsonl"
+	cfg.Source.Path = filepath.Join(dir, "*.jsonl*")
+	out, err := extractAll(t, NewJSONLExtractor(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("got %d records, want 3", len(out))
+	}
+	if out[0]["id"] != json.Number("9007199254740993") || out[0]["amount"] != json.Number("12.10") {
+		t.Errorf("numbers read as %#v and %#v", out[0]["id"], out[0]["amount"])
+	}
+	if out[0][pipeline.FieldSourceTable] != filepath.Join(dir, "a.jsonl") {
+		t.Errorf("source table %v", out[0][pipeline.FieldSourceTable])
+	}
+	// replayed archives keep the table they were read from
+	if out[1][pipeline.FieldSourceTable] != "dealers" {
+		t.Errorf("source table %v, want dealers", out[1][pipeline.FieldSourceTable])
+	}
+	if out[2]["id"] != json.Number("3") {
+		t.Errorf("gzipped file gave %v", out[2])
+	}
+}
+
+func TestJSONLBadLines(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "events.jsonl"),
+		`{"id": 1}`+"\n"+
+			`{"id": 2`+"\n"+ // truncated
+			`[1, 2]`+"\n"+ // not an object
+			`{"id": 4} {"id": 5}`+"\n"+
+			`{"id": 6}`+"\n")
+	newConfig := func(onError string) *config.PipelineConfig {
+		cfg := &config.PipelineConfig{}
+		cfg.Pipeline.Name = "events"
+		cfg.Pipeline.DeadLetter.Path = filepath.Join(dir, "dead", "events.jsonl")
+		cfg.Source.Type = "jsonl"
+		cfg.Source.Path = filepath.Join(dir, "events.jsonl")
+		cfg.Source.OnError = onError
+		return cfg
+	}
+
+	out, err := extractAll(t, NewJSONLExtractor(), newConfig(""))
+	if err == nil || !strings.Contains(err.Error(), "events.jsonl:2: invalid json") || !pipeline.IsPermanent(err) {
+		t.Fatalf("got %v, want a permanent error naming line 2", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("passed %d records before the bad line, want 1", len(out))
+	}
+
+	out, err = extractAll(t, NewJSONLExtractor(), newConfig("skip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[1]["id"] != json.Number("6") {
+		t.Fatalf("got %v, want the two good lines", out)
+	}
+
+	out, err = extractAll(t, NewJSONLExtractor(), newConfig("dead_letter"))
+	if err != nil || len(out) != 2 {
+		t.Fatalf("got %d records, %v", len(out), err)
+	}
+	f, err := os.Open(newConfig("").Pipeline.DeadLetter.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var texts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry pipeline.DeadLetterEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		text, _ := entry.Record["_text"].(string)
+		texts = append(texts, text)
+	}
+	if strings.Join(texts, "|") != `{"id": 2|[1, 2]|{"id": 4} {"id": 5}` {
+		t.Errorf("dead-lettered %q", texts)
+	}
+}
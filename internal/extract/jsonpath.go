@@ -0,0 +1,115 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath-style selector supporting the subset
+// needed to locate records in API responses: $, .name, ['name'], [n] and
+// the [*] / .* wildcards. The leading $ is optional.
+type jsonPath []pathStep
+
+type pathStep struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func compileJSONPath(expr string) (jsonPath, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+
+	var steps jsonPath
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path %q: empty field name", expr)
+			}
+			if name == "*" {
+				steps = append(steps, pathStep{wildcard: true})
+			} else {
+				steps = append(steps, pathStep{name: name})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated [", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, pathStep{name: inner[1 : len(inner)-1]})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: bad index %q", expr, inner)
+				}
+				steps = append(steps, pathStep{index: i, isIndex: true})
+			}
+		default:
+			if len(steps) == 0 {
+				s = "." + s
+				continue
+			}
+			return nil, fmt.Errorf("invalid path %q at %q", expr, s)
+		}
+	}
+	return steps, nil
+}
+
+// find returns every value the path selects from doc.
+func (p jsonPath) find(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, step := range p {
+		var next []interface{}
+		for _, v := range current {
+			switch t := v.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, child := range t {
+						next = append(next, child)
+					}
+				} else if child, ok := t[step.name]; ok && !step.isIndex {
+					next = append(next, child)
+				}
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, t...)
+				case step.isIndex:
+					i := step.index
+					if i < 0 {
+						i += len(t)
+					}
+					if i >= 0 && i < len(t) {
+						next = append(next, t[i])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// first returns the first selected value, or nil when nothing matches.
+func (p jsonPath) first(doc interface{}) interface{} {
+	if found := p.find(doc); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/kafkaclient"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/hamba/avro/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const defaultKafkaIdleTimeout = 10 * time.Second
+
+// KafkaExtractor consumes JSON or Avro messages as a member of a consumer
+// group. A run ends once no message has arrived for idle_timeout, or after
+// max_records. Offsets are not committed automatically: the orchestrator
+// calls Commit after the loader succeeds, so a failed run is consumed again
+// from the last committed offsets.
+type KafkaExtractor struct {
+	config *config.PipelineConfig
+	client *kgo.Client
+	schema avro.Schema
+
+	// last holds the last emitted record of each partition, the offsets
+	// Commit acknowledges.
+	last map[topicPartition]*kgo.Record
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+func NewKafkaExtractor() *KafkaExtractor {
+	return &KafkaExtractor{}
+}
+
+func (e *KafkaExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+	opts := cfg.Source.Kafka
+
+	topics := opts.SourceTopics()
+	if len(topics) == 0 {
+		return fmt.Errorf("kafka source requires topic or topics")
+	}
+	if opts.Group == "" {
+		return fmt.Errorf("kafka source requires a consumer group")
+	}
+	if opts.IdleTimeout < 0 || opts.MaxRecords < 0 {
+		return fmt.Errorf("idle_timeout and max_records must be non-negative")
+	}
+
+	schema, err := kafkaclient.Schema(opts)
+	if err != nil {
+		return err
+	}
+	e.schema = schema
+
+	clientOpts, err := kafkaclient.Options(opts)
+	if err != nil {
+		return err
+	}
+
+	reset := kgo.NewOffset().AtStart()
+	switch strings.ToLower(opts.StartOffset) {
+	case "", "earliest":
+	case "latest":
+		reset = kgo.NewOffset().AtEnd()
+	default:
+		return fmt.Errorf("unsupported start_offset: %s", opts.StartOffset)
+	}
+
+	clientOpts = append(clientOpts,
+		kgo.ConsumeTopics(topics...),
+		kgo.ConsumerGroup(opts.Group),
+		kgo.ConsumeResetOffset(reset),
+		kgo.DisableAutoCommit(),
+	)
+	client, err := kgo.NewClient(clientOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	e.client = client
+	e.last = make(map[topicPartition]*kgo.Record)
+
+	return nil
+}
+
+func (e *KafkaExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		if err := e.consume(ctx, records); err != nil {
+			sendError(ctx, errs, err)
+		}
+	}()
+
+	return records, errs
+}
+
+func (e *KafkaExtractor) consume(ctx context.Context, records chan<- pipeline.DataRecord) error {
+	opts := e.config.Source.Kafka
+	idle := opts.IdleTimeout
+	if idle == 0 {
+		idle = defaultKafkaIdleTimeout
+	}
+
+	count := 0
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, idle)
+		fetches := e.client.PollRecords(pollCtx, e.pollSize(count))
+		cancel()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if fetches.IsClientClosed() {
+			return fmt.Errorf("kafka client closed")
+		}
+		for _, fe := range fetches.Errors() {
+			if errors.Is(fe.Err, context.DeadlineExceeded) || errors.Is(fe.Err, context.Canceled) {
+				continue
+			}
+			return fmt.Errorf("fetch %s[%d] failed: %w", fe.Topic, fe.Partition, fe.Err)
+		}
+		if fetches.NumRecords() == 0 {
+			log.Printf("Consumed %d records, no new messages for %s", count, idle)
+			return nil
+		}
+
+		for _, r := range fetches.Records() {
+			record, err := e.decode(r.Value)
+			if err != nil {
+				return fmt.Errorf("%s[%d]@%d: %w", r.Topic, r.Partition, r.Offset, err)
+			}
+			record[pipeline.FieldSourceTable] = r.Topic
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case records <- record:
+			}
+			e.last[topicPartition{r.Topic, r.Partition}] = r
+			count++
+		}
+
+		if opts.MaxRecords > 0 && count >= opts.MaxRecords {
+			log.Printf("Consumed max_records=%d", count)
+			return nil
+		}
+	}
+}
+
+// pollSize limits the next poll so that max_records is not overshot.
+func (e *KafkaExtractor) pollSize(count int) int {
+	if max := e.config.Source.Kafka.MaxRecords; max > 0 {
+		return max - count
+	}
+	return 0
+}
+
+func (e *KafkaExtractor) decode(value []byte) (pipeline.DataRecord, error) {
+	if e.schema == nil {
+		return decodeJSONRecord(value)
+	}
+
+	var raw map[string]interface{}
+	if err := avro.Unmarshal(e.schema, value, &raw); err != nil {
+		return nil, fmt.Errorf("invalid avro: %w", err)
+	}
+	record := make(pipeline.DataRecord, len(raw)+1)
+	for k, v := range raw {
+		record[k] = avroGoValue(v)
+	}
+	return record, nil
+}
+
+// Commit acknowledges every record emitted in this run.
+func (e *KafkaExtractor) Commit(ctx context.Context) error {
+	if len(e.last) == 0 {
+		return nil
+	}
+
+	records := make([]*kgo.Record, 0, len(e.last))
+	for _, r := range e.last {
+		records = append(records, r)
+	}
+	if err := e.client.CommitRecords(ctx, records...); err != nil {
+		return fmt.Errorf("failed to commit kafka offsets: %w", err)
+	}
+	log.Printf("Committed kafka offsets for %d partitions", len(records))
+	e.last = make(map[topicPartition]*kgo.Record)
+	return nil
+}
+
+func (e *KafkaExtractor) Close() error {
+	if e.client != nil {
+		e.client.Close()
+		e.client = nil
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// ParquetExtractor reads Parquet files column by column, decoding only the
+// selected columns. Row groups whose statistics rule out the where clauses
+// are skipped without being read.
+type ParquetExtractor struct {
+	config *config.PipelineConfig
+	files  []string
+	where  []predicate
+}
+
+type parquetLeaf struct {
+	name  string
+	index int
+	typ   parquet.Type
+}
+
+func NewParquetExtractor() *ParquetExtractor {
+	return &ParquetExtractor{}
+}
+
+func (e *ParquetExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+
+	where, err := compilePredicates(cfg.Source.Where)
+	if err != nil {
+		return err
+	}
+	e.where = where
+
+	patterns := cfg.Source.Files()
+	if len(patterns) == 0 {
+		return fmt.Errorf("parquet source requires path or paths")
+	}
+	files, err := storage.Expand(ctx, patterns)
+	if err != nil {
+		return err
+	}
+	e.files = files
+
+	return nil
+}
+
+func (e *ParquetExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		for _, file := range e.files {
+			if err := e.extractFile(ctx, file, records); err != nil {
+				sendError(ctx, errs, fmt.Errorf("%s: %w", file, err))
+				return
+			}
+		}
+	}()
+
+	return records, errs
+}
+
+func (e *ParquetExtractor) extractFile(ctx context.Context, file string, records chan<- pipeline.DataRecord) error {
+	f, err := storage.OpenFile(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pf, err := parquet.OpenFile(f, f.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return fmt.Errorf("failed to open parquet file: %w", err)
+	}
+
+	leaves, err := e.projection(pf.Schema())
+	if err != nil {
+		return err
+	}
+
+	for i, rg := range pf.RowGroups() {
+		if !e.rowGroupMayMatch(pf.Metadata().RowGroups[i], pf.Schema()) {
+			continue
+		}
+
+		columns := make([][]interface{}, len(leaves))
+		for j, leaf := range leaves {
+			values, err := readParquetColumn(rg.ColumnChunks()[leaf.index], leaf.typ, rg.NumRows())
+			if err != nil {
+				return fmt.Errorf("row group %d, column %s: %w", i, leaf.name, err)
+			}
+			columns[j] = values
+		}
+
+		for row := 0; row < int(rg.NumRows()); row++ {
+			record := make(pipeline.DataRecord, len(leaves)+1)
+			for j, leaf := range leaves {
+				record[leaf.name] = columns[j][row]
+			}
+			if !matchAll(e.where, record) {
+				continue
+			}
+			for _, p := range e.where {
+				if !e.selected(p.column) {
+					delete(record, p.column)
+				}
+			}
+			record[pipeline.FieldSourceTable] = file
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case records <- record:
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *ParquetExtractor) Close() error {
+	return nil
+}
+
+func (e *ParquetExtractor) selected(column string) bool {
+	if len(e.config.Source.Select) == 0 {
+		return true
+	}
+	for _, c := range e.config.Source.Select {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// projection returns the leaf columns to read: the selected columns plus
+// any column referenced by a where clause. Nested leaves are named by their
+// dotted path.
+func (e *ParquetExtractor) projection(schema *parquet.Schema) ([]parquetLeaf, error) {
+	wanted := make(map[string]bool)
+	for _, c := range e.config.Source.Select {
+		wanted[c] = true
+	}
+	for _, p := range e.where {
+		wanted[p.column] = true
+	}
+
+	var leaves []parquetLeaf
+	for _, path := range schema.Columns() {
+		name := strings.Join(path, ".")
+		if len(e.config.Source.Select) > 0 && !wanted[name] {
+			continue
+		}
+		delete(wanted, name)
+
+		leaf, _ := schema.Lookup(path...)
+		if leaf.MaxRepetitionLevel > 0 {
+			if len(e.config.Source.Select) > 0 {
+				return nil, fmt.Errorf("repeated column %s is not supported", name)
+			}
+			continue
+		}
+		leaves = append(leaves, parquetLeaf{name: name, index: leaf.ColumnIndex, typ: leaf.Node.Type()})
+	}
+
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for name := range wanted {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("columns not found in schema: %s", strings.Join(missing, ", "))
+	}
+	return leaves, nil
+}
+
+func (e *ParquetExtractor) rowGroupMayMatch(rg format.RowGroup, schema *parquet.Schema) bool {
+	for _, p := range e.where {
+		leaf, ok := schema.Lookup(strings.Split(p.column, ".")...)
+		if !ok || leaf.ColumnIndex >= len(rg.Columns) {
+			continue
+		}
+		stats := rg.Columns[leaf.ColumnIndex].MetaData.Statistics
+		if rg.NumRows > 0 && stats.NullCount == rg.NumRows {
+			// Only nulls, which no comparison matches.
+			return false
+		}
+		if len(stats.MinValue) == 0 || len(stats.MaxValue) == 0 {
+			continue
+		}
+
+		typ := leaf.Node.Type()
+		min := parquetGoValue(typ, typ.Kind().Value(stats.MinValue))
+		max := parquetGoValue(typ, typ.Kind().Value(stats.MaxValue))
+		if !p.mayMatch(min, max) {
+			return false
+		}
+	}
+	return true
+}
+
+func readParquetColumn(chunk parquet.ColumnChunk, typ parquet.Type, numRows int64) ([]interface{}, error) {
+	pages := chunk.Pages()
+	defer pages.Close()
+
+	out := make([]interface{}, 0, numRows)
+	buf := make([]parquet.Value, 1024)
+	for {
+		page, err := pages.ReadPage()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := page.Values()
+		for {
+			n, err := values.ReadValues(buf)
+			for _, v := range buf[:n] {
+				out = append(out, parquetGoValue(typ, v))
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				parquet.Release(page)
+				return nil, err
+			}
+		}
+		parquet.Release(page)
+	}
+
+	if int64(len(out)) != numRows {
+		return nil, fmt.Errorf("read %d values for %d rows", len(out), numRows)
+	}
+	return out, nil
+}
+
+// parquetGoValue converts a Parquet value to the Go type used in records,
+// honouring the column's logical type: strings, times for dates and
+// timestamps, and json.Number for decimals.
+func parquetGoValue(typ parquet.Type, v parquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+
+	lt := typ.LogicalType()
+	ct := typ.ConvertedType()
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32, parquet.Int64:
+		n := v.Int64()
+		if v.Kind() == parquet.Int32 {
+			n = int64(v.Int32())
+		}
+		switch {
+		case lt != nil && lt.Decimal != nil:
+			return decimalNumber(big.NewInt(n), int(lt.Decimal.Scale))
+		case lt != nil && lt.Date != nil, ct != nil && *ct == deprecated.Date:
+			return time.Unix(n*86400, 0).UTC()
+		case lt != nil && lt.Timestamp != nil:
+			return timestampFromUnit(n, lt.Timestamp.Unit)
+		case ct != nil && *ct == deprecated.TimestampMillis:
+			return time.UnixMilli(n).UTC()
+		case ct != nil && *ct == deprecated.TimestampMicros:
+			return time.UnixMicro(n).UTC()
+		}
+		return n
+	case parquet.Int96:
+		return int96Time(v.Int96())
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		b := v.ByteArray()
+		switch {
+		case lt != nil && lt.Decimal != nil:
+			return decimalNumber(twosComplement(b), int(lt.Decimal.Scale))
+		case lt != nil && (lt.UTF8 != nil || lt.Enum != nil || lt.Json != nil),
+			ct != nil && (*ct == deprecated.UTF8 || *ct == deprecated.Enum || *ct == deprecated.Json):
+			return string(b)
+		case lt != nil && lt.UUID != nil && len(b) == 16:
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+		}
+		return append([]byte(nil), b...)
+	}
+	return nil
+}
+
+func timestampFromUnit(n int64, unit format.TimeUnit) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(n).UTC()
+	case unit.Nanos != nil:
+		return time.Unix(0, n).UTC()
+	default:
+		return time.UnixMicro(n).UTC()
+	}
+}
+
+// int96Time decodes the legacy Impala timestamp: nanoseconds within the day
+// followed by the Julian day number.
+func int96Time(v deprecated.Int96) time.Time {
+	const julianUnixEpoch = 2440588
+	nanos := int64(v[1])<<32 | int64(v[0])
+	days := int64(v[2]) - julianUnixEpoch
+	return time.Unix(days*86400, nanos).UTC()
+}
+
+func twosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return n
+}
+
+// decimalNumber renders unscaled / 10^scale exactly.
+func decimalNumber(unscaled *big.Int, scale int) json.Number {
+	if scale <= 0 {
+		return json.Number(unscaled.String())
+	}
+	r := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	return json.Number(r.FloatString(scale))
+}
@@ -0,0 +1,184 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/parquet-go/parquet-go"
+)
+
+type loginRow struct {
+	ID      int64     `parquet:"id"`
+	Dealer  string    `parquet:"dealer"`
+	Amount  *int64    `parquet:"amount,optional"`
+	Price   int64     `parquet:"price,decimal(2:18)"`
+	LoginAt time.Time `parquet:"login_at,timestamp(millisecond)"`
+	Tags    []string  `parquet:"tags,list"`
+}
+
+func amount(n int64) *int64 { return &n }
+
+// writeLogins writes rows to path in row groups of two: ids 1-2, 3-4 and
+// 5-6, with only nulls in amount in the second.
+func writeLogins(t *testing.T, path string) {
+	t.Helper()
+	at := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	rows := []loginRow{
+		{ID: 1, Dealer: "D1", Amount: amount(10), Price: 1250, LoginAt: at},
+		{ID: 2, Dealer: "D2", Amount: nil, Price: -5, LoginAt: at.Add(time.Hour)},
+		{ID: 3, Dealer: "D1", Amount: nil, Price: 0, LoginAt: at},
+		{ID: 4, Dealer: "D3", Amount: nil, Price: 0, LoginAt: at},
+		{ID: 5, Dealer: "D2", Amount: amount(50), Price: 100, LoginAt: at, Tags: []string{"a"}},
+		{ID: 6, Dealer: "D1", Amount: amount(60), Price: 100, LoginAt: at},
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := parquet.NewGenericWriter[loginRow](f, parquet.MaxRowsPerRowGroup(2))
+	if _, err := w.Write(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func parquetConfig(path string, sel []string, where ...config.PredicateConfig) *config.PipelineConfig {
+	cfg := &config.PipelineConfig{}
+	cfg.Source.Type = "parquet"
+	cfg.Source.Path = path
+	cfg.Source.Select = sel
+	cfg.Source.Where = where
+	return cfg
+}
+
+func ids(records []pipeline.DataRecord) []int64 {
+	out := make([]int64, len(records))
+	for i, r := range records {
+		out[i], _ = r["id"].(int64)
+	}
+	return out
+}
+
+func TestParquetProjection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logins.parquet")
+	writeLogins(t, path)
+
+	out, err := extractAll(t, NewParquetExtractor(), parquetConfig(path, []string{"id", "price", "login_at"},
+		config.PredicateConfig{Column: "dealer", Op: "=", Value: "D2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("got %v, want ids 2 and 5", out)
+	}
+	// the where column is read but not passed on
+	for _, r := range out {
+		if _, ok := r["dealer"]; ok || len(r) != 4 {
+			t.Errorf("record %v, want id, price, login_at and the source table", r)
+		}
+	}
+	if out[0]["id"] != int64(2) || out[0]["price"] != json.Number("-0.05") ||
+		!out[0]["login_at"].(time.Time).Equal(time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("first record %v", out[0])
+	}
+	if out[1]["price"] != json.Number("1.00") || out[1][pipeline.FieldSourceTable] != path {
+		t.Errorf("second record %v", out[1])
+	}
+
+	// without select every column but the repeated one is read
+	out, err = extractAll(t, NewParquetExtractor(), parquetConfig(path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 6 || out[0]["amount"] != int64(10) || out[1]["amount"] != nil || out[0]["dealer"] != "D1" {
+		t.Fatalf("got %v", out)
+	}
+	if _, ok := out[4]["tags.list.element"]; ok {
+		t.Error("a repeated column was read")
+	}
+
+	for _, tc := range []struct {
+		sel   []string
+		where []config.PredicateConfig
+		err   string
+	}{
+		{[]string{"id", "region"}, nil, "columns not found in schema: region"},
+		{[]string{"id"}, []config.PredicateConfig{{Column: "zone", Op: "=", Value: 1}}, "columns not found in schema: zone"},
+		{[]string{"tags.list.element"}, nil, "repeated column tags.list.element is not supported"},
+	} {
+		if _, err := extractAll(t, NewParquetExtractor(), parquetConfig(path, tc.sel, tc.where...)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("select %v where %v gave %v, want %q", tc.sel, tc.where, err, tc.err)
+		}
+	}
+}
+
+func TestParquetSkipsPrunedRowGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logins.parquet")
+	writeLogins(t, path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		where config.PredicateConfig
+		read  string
+		ids   []int64
+	}{
+		{config.PredicateConfig{Column: "id", Op: ">", Value: 4}, "--x", []int64{5, 6}},
+		{config.PredicateConfig{Column: "id", Op: "<=", Value: "3"}, "xx-", []int64{1, 2, 3}},
+		{config.PredicateConfig{Column: "id", Op: "=", Value: 7}, "---", nil},
+		{config.PredicateConfig{Column: "dealer", Op: "=", Value: "D3"}, "-x-", []int64{4}},
+		{config.PredicateConfig{Column: "price", Op: "!=", Value: 0}, "x-x", []int64{1, 2, 5, 6}},
+		{config.PredicateConfig{Column: "price", Op: "<", Value: "0.5"}, "xx-", []int64{2, 3, 4}},
+		{config.PredicateConfig{Column: "login_at", Op: ">", Value: "2026-10-17T10:00:00Z"}, "x--", []int64{2}},
+		// the second group holds only nulls in amount, the first 10 and a null
+		{config.PredicateConfig{Column: "amount", Op: "!=", Value: 10}, "--x", []int64{5, 6}},
+		{config.PredicateConfig{Column: "amount", Op: "<", Value: 100}, "x-x", []int64{1, 5, 6}},
+	} {
+		cfg := parquetConfig(path, nil, tc.where)
+		e := NewParquetExtractor()
+		if err := e.Init(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+		read := ""
+		for _, rg := range pf.Metadata().RowGroups {
+			if e.rowGroupMayMatch(rg, pf.Schema()) {
+				read += "x"
+			} else {
+				read += "-"
+			}
+		}
+		if read != tc.read {
+			t.Errorf("%v reads row groups %s, want %s", tc.where, read, tc.read)
+		}
+
+		out, err := extractAll(t, NewParquetExtractor(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(out); fmt.Sprint(got) != fmt.Sprint(tc.ids) {
+			t.Errorf("%v gave ids %v, want %v", tc.where, got, tc.ids)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// predicate is a single source.where comparison. A record matches when all
+// predicates match; comparisons against null never match.
+type predicate struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+func compilePredicates(cfgs []config.PredicateConfig) ([]predicate, error) {
+	preds := make([]predicate, len(cfgs))
+	for i, c := range cfgs {
+		if c.Column == "" {
+			return nil, fmt.Errorf("where clause %d has no column", i)
+		}
+		switch c.Op {
+		case "=", "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return nil, fmt.Errorf("where clause on %s has unsupported op %q", c.Column, c.Op)
+		}
+		if c.Value == nil {
+			return nil, fmt.Errorf("where clause on %s has no value", c.Column)
+		}
+		preds[i] = predicate{column: c.Column, op: c.Op, value: c.Value}
+	}
+	return preds, nil
+}
+
+func matchAll(preds []predicate, record pipeline.DataRecord) bool {
+	for _, p := range preds {
+		if !p.match(record[p.column]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p predicate) match(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	c, ok := compareValues(v, p.value)
+	if !ok {
+		return false
+	}
+
+	switch p.op {
+	case "=", "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// mayMatch reports whether any value within [min, max] could satisfy the
+// predicate. It is used to skip blocks of rows using their statistics, and
+// answers true whenever it cannot tell.
+func (p predicate) mayMatch(min, max interface{}) bool {
+	if min == nil || max == nil {
+		return true
+	}
+	lo, ok1 := compareValues(min, p.value)
+	hi, ok2 := compareValues(max, p.value)
+	if !ok1 || !ok2 {
+		return true
+	}
+
+	switch p.op {
+	case "=", "==":
+		return lo <= 0 && hi >= 0
+	case "!=":
+		return !(lo == 0 && hi == 0)
+	case "<":
+		return lo < 0
+	case "<=":
+		return lo <= 0
+	case ">":
+		return hi > 0
+	case ">=":
+		return hi >= 0
+	}
+	return true
+}
+
+// compareValues orders two loosely typed values. Numbers compare as numbers
+// whatever their Go type, times compare with timestamps written as strings,
+// and everything else falls back to string comparison. ok is false when the
+// values cannot be ordered against each other.
+func compareValues(a, b interface{}) (c int, ok bool) {
+	if ta, isTime := a.(time.Time); isTime {
+		tb, err := asTime(b)
+		if err != nil {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+	if _, isTime := b.(time.Time); isTime {
+		c, ok := compareValues(b, a)
+		return -c, ok
+	}
+
+	if ra, isNum := asNumber(a); isNum {
+		if rb, isNum := asNumber(b); isNum {
+			return ra.Cmp(rb), true
+		}
+		if s, isString := b.(string); isString {
+			if rb, valid := new(big.Rat).SetString(strings.TrimSpace(s)); valid {
+				return ra.Cmp(rb), true
+			}
+		}
+		return 0, false
+	}
+	if _, isNum := asNumber(b); isNum {
+		c, ok := compareValues(b, a)
+		return -c, ok
+	}
+
+	if ba, isBool := a.(bool); isBool {
+		bb, isBool := b.(bool)
+		if !isBool {
+			return 0, false
+		}
+		switch {
+		case ba == bb:
+			return 0, true
+		case !ba:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
+
+func asTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		return parseTimestamp(strings.TrimSpace(t))
+	default:
+		return time.Time{}, fmt.Errorf("not a time: %v", v)
+	}
+}
+
+func asNumber(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case uint8:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint16:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint32:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case float32:
+		r, ok := new(big.Rat).SetString(fmt.Sprint(n))
+		return r, ok
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(n) == nil {
+			return nil, false
+		}
+		return r, true
+	case json.Number:
+		return new(big.Rat).SetString(string(n))
+	case *big.Rat:
+		return n, n != nil
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,103 @@
+package extract
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestCompilePredicates(t *testing.T) {
+	for _, c := range []config.PredicateConfig{
+		{Op: "=", Value: 1},
+		{Column: "id", Op: "~", Value: 1},
+		{Column: "id", Op: "in", Value: 1},
+		{Column: "id", Op: "="},
+	} {
+		if _, err := compilePredicates([]config.PredicateConfig{c}); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+	preds, err := compilePredicates([]config.PredicateConfig{{Column: "id", Op: "==", Value: 1}})
+	if err != nil || len(preds) != 1 || !preds[0].match(int64(1)) {
+		t.Errorf("got %v, %v", preds, err)
+	}
+}
+
+func TestPredicateMatch(t *testing.T) {
+	at := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		op    string
+		value interface{}
+		v     interface{}
+		want  bool
+	}{
+		// comparisons against null never match
+		{"=", 1, nil, false},
+		{"!=", 1, nil, false},
+		{"<", 1, nil, false},
+
+		{"=", 5, int64(5), true},
+		{"=", 5, 5.0, true},
+		{"=", "5", int32(5), true},
+		{"=", 5, json.Number("5.00"), true},
+		{"<", 0.5, big.NewRat(1, 3), true},
+		{">", json.Number("9007199254740992"), int64(9007199254740993), true},
+		{"!=", 5, uint8(5), false},
+		{"<=", 5, int64(6), false},
+		{">=", "x", int64(6), false},
+
+		{"=", "2026-10-17T09:30:00Z", at, true},
+		{">", "2026-10-17 09:00:00", at, true},
+		{"<", "2026-10-17T15:00:00+05:30", at, false},
+		{"=", "yesterday", at, false},
+		{"=", 1760693400, at, false},
+
+		{"=", true, true, true},
+		{">", false, true, true},
+		{"=", "true", true, false},
+
+		{"<", "D2", "D1", true},
+		{">=", "D2", "D10", false},
+	} {
+		p := predicate{column: "c", op: tc.op, value: tc.value}
+		if got := p.match(tc.v); got != tc.want {
+			t.Errorf("%v %s %v: got %v, want %v", tc.v, tc.op, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestPredicateMayMatch(t *testing.T) {
+	for _, tc := range []struct {
+		op       string
+		value    interface{}
+		min, max interface{}
+		want     bool
+	}{
+		// unknown bounds never skip a block
+		{"=", 5, nil, int64(9), true},
+		{"=", 5, int64(1), nil, true},
+		{"=", 5, "a", "z", true},
+
+		{"=", 5, int64(1), int64(9), true},
+		{"=", 5, int64(6), int64(9), false},
+		{"=", 5, int64(1), int64(4), false},
+		{"!=", 5, int64(5), int64(5), false},
+		{"!=", 5, int64(5), int64(6), true},
+		{"<", 5, int64(5), int64(9), false},
+		{"<", 5, int64(4), int64(9), true},
+		{"<=", 5, int64(5), int64(9), true},
+		{">", 5, int64(1), int64(5), false},
+		{">", 5, int64(1), int64(6), true},
+		{">=", 5, int64(1), int64(5), true},
+		{">=", 5, int64(1), int64(4), false},
+		{">", "2026-10-17T10:00:00Z", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), false},
+	} {
+		p := predicate{column: "c", op: tc.op, value: tc.value}
+		if got := p.mayMatch(tc.min, tc.max); got != tc.want {
+			t.Errorf("[%v, %v] %s %v: got %v, want %v", tc.min, tc.max, tc.op, tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package extract
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// rowPolicy decides what happens to a row of a file that cannot be parsed:
+// fail the run (the default), skip it, or write it to the dead letter file.
+// The same row fails every retry, so failing is permanent.
+type rowPolicy struct {
+	mode       string
+	deadLetter *pipeline.DeadLetter
+	count      atomic.Int64
+}
+
+func newRowPolicy(cfg *config.PipelineConfig) (*rowPolicy, error) {
+	p := &rowPolicy{mode: cfg.Source.OnError}
+	switch p.mode {
+	case "", "fail", "skip":
+	case "dead_letter":
+		if cfg.Pipeline.DeadLetter.Path == "" {
+			return nil, fmt.Errorf("on_error: dead_letter requires pipeline.dead_letter.path")
+		}
+		p.deadLetter = pipeline.NewDeadLetter(cfg.Pipeline.DeadLetter.Path, cfg.Pipeline.Name)
+	default:
+		return nil, fmt.Errorf("unsupported on_error: %s", p.mode)
+	}
+	return p, nil
+}
+
+// reject applies the policy to the row at line of name, which failed with
+// err. row holds what could be read of it.
+func (p *rowPolicy) reject(name string, line int, row pipeline.DataRecord, err error) error {
+	err = fmt.Errorf("%s:%d: %w", name, line, err)
+	switch p.mode {
+	case "skip":
+		p.count.Add(1)
+		return nil
+	case "dead_letter":
+		p.count.Add(1)
+		if row == nil {
+			row = make(pipeline.DataRecord)
+		}
+		row[pipeline.FieldSourceTable] = name
+		row["_line"] = line
+		return p.deadLetter.Write("extract", row, err)
+	default:
+		return pipeline.Permanent(err)
+	}
+}
+
+func (p *rowPolicy) report() {
+	count := p.count.Load()
+	if count == 0 {
+		return
+	}
+	if p.deadLetter != nil {
+		log.Printf("Wrote %d unparsable rows to %s", count, p.deadLetter.Path())
+		return
+	}
+	log.Printf("Skipped %d unparsable rows", count)
+}
+
+func (p *rowPolicy) Close() error {
+	if p.deadLetter != nil {
+		return p.deadLetter.Close()
+	}
+	return nil
+}
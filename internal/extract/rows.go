@@ -0,0 +1,157 @@
+package extract
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/schema"
+)
+
+// RowScanner reads the rows of a query into records. Each column is
+// scanned into the sql.Null* type matching its database type, so a NULL
+// becomes nil rather than a scan error, text arrives as a string rather
+// than bytes, decimals as exact text, and uniqueidentifiers as uuid text.
+// Columns of other types are passed on as the driver returns them.
+type RowScanner struct {
+	columns   []string
+	types     []string
+	sqlServer bool
+	sqlite    bool
+
+	dests   []interface{}
+	generic []interface{}
+	ptrs    []interface{}
+}
+
+// NewRowScanner prepares a scanner for the columns of rows. The driver is
+// the name the database was opened with.
+func NewRowScanner(rows *sql.Rows, driver string) (*RowScanner, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+
+	s := &RowScanner{
+		columns:   make([]string, len(types)),
+		types:     make([]string, len(types)),
+		sqlServer: driver == "sqlserver",
+		sqlite:    driver == "sqlite",
+		dests:     make([]interface{}, len(types)),
+		generic:   make([]interface{}, len(types)),
+		ptrs:      make([]interface{}, len(types)),
+	}
+	for i, ct := range types {
+		s.columns[i] = ct.Name()
+		if f, err := schema.New(ct.Name(), ct.DatabaseTypeName()); err == nil {
+			s.types[i] = f.Type
+		}
+		s.dests[i] = scanDest(s.types[i])
+		s.ptrs[i] = &s.generic[i]
+	}
+	return s, nil
+}
+
+// Columns returns the names of the columns, in query order.
+func (s *RowScanner) Columns() []string {
+	return s.columns
+}
+
+func scanDest(kind string) interface{} {
+	switch kind {
+	case schema.String, schema.Decimal:
+		return new(sql.NullString)
+	case schema.Int:
+		return new(sql.NullInt64)
+	case schema.Float:
+		return new(sql.NullFloat64)
+	case schema.Bool:
+		return new(sql.NullBool)
+	case schema.Timestamp:
+		return new(sql.NullTime)
+	case schema.UUID, schema.Bytes:
+		return new([]byte)
+	default:
+		return new(interface{})
+	}
+}
+
+// Scan reads the current row. SQLite lets a column hold values of another
+// type than the one it declares; with SQLite, a row that does not scan into
+// the declared types is read as the driver returns it instead. Other
+// databases return the scan error.
+func (s *RowScanner) Scan(rows *sql.Rows) (pipeline.DataRecord, error) {
+	record := make(pipeline.DataRecord, len(s.columns)+1)
+
+	err := rows.Scan(s.dests...)
+	if err == nil {
+		for i, col := range s.columns {
+			record[col] = s.value(i)
+		}
+		return record, nil
+	}
+	if !s.sqlite {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	if err := rows.Scan(s.ptrs...); err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+	for i, col := range s.columns {
+		v := s.generic[i]
+		if b, ok := v.([]byte); ok && s.types[i] != schema.Bytes {
+			v = string(b)
+		}
+		record[col] = v
+	}
+	return record, nil
+}
+
+func (s *RowScanner) value(i int) interface{} {
+	switch d := s.dests[i].(type) {
+	case *sql.NullString:
+		if !d.Valid {
+			return nil
+		}
+		return d.String
+	case *sql.NullInt64:
+		if !d.Valid {
+			return nil
+		}
+		return d.Int64
+	case *sql.NullFloat64:
+		if !d.Valid {
+			return nil
+		}
+		return d.Float64
+	case *sql.NullBool:
+		if !d.Valid {
+			return nil
+		}
+		return d.Bool
+	case *sql.NullTime:
+		if !d.Valid {
+			return nil
+		}
+		return d.Time
+	case *[]byte:
+		b := *d
+		if b == nil {
+			return nil
+		}
+		if s.types[i] == schema.UUID {
+			if len(b) == 16 {
+				return schema.FormatUUID(b, s.sqlServer)
+			}
+			return string(b)
+		}
+		return b
+	case *interface{}:
+		if b, ok := (*d).([]byte); ok {
+			return string(b)
+		}
+		return *d
+	default:
+		return nil
+	}
+}
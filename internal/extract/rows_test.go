@@ -0,0 +1,61 @@
+package extract
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestRowScanner(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "rows.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE dealers (id INTEGER, name TEXT, amount DECIMAL(10,2));
+		INSERT INTO dealers VALUES (1, 'a', '1.50'), (NULL, NULL, NULL), ('x', 'b', 2)`); err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(driver string) ([]map[string]interface{}, error) {
+		rows, err := db.Query("SELECT id, name, amount FROM dealers ORDER BY rowid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		s, err := NewRowScanner(rows, driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []map[string]interface{}
+		for rows.Next() {
+			record, err := s.Scan(rows)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, record)
+		}
+		return out, rows.Err()
+	}
+
+	out, err := scan("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0]["id"] != int64(1) || out[0]["name"] != "a" || out[0]["amount"] != "1.5" {
+		t.Errorf("first row %v", out[0])
+	}
+	if out[1]["id"] != nil || out[1]["name"] != nil {
+		t.Errorf("nulls read as %v", out[1])
+	}
+	// SQLite stores text in an INTEGER column; it is read as it is
+	if out[2]["id"] != "x" {
+		t.Errorf("mistyped SQLite value read as %#v", out[2]["id"])
+	}
+
+	// other databases do not hide a scan error
+	out, err = scan("postgres")
+	if err == nil || len(out) != 2 {
+		t.Fatalf("got %v after %d rows, want a scan error on the third", err, len(out))
+	}
+}
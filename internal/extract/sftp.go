@@ -0,0 +1,369 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/state"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultSFTPTimeout = 30 * time.Second
+
+// SFTPExtractor reads files dropped on an SFTP server through the CSV or
+// JSON Lines parser. Files already recorded in the state store with the same
+// size and modification time are skipped. Once the run has been loaded,
+// Commit records the files as processed and moves them to archive_dir.
+type SFTPExtractor struct {
+	config  *config.PipelineConfig
+	conn    *ssh.Client
+	client  *sftp.Client
+	state   *state.Store
+	csv     *csvParser
+	rejects *rowPolicy
+
+	files     []remoteFile
+	processed map[string]processedFile
+}
+
+type remoteFile struct {
+	path string
+	info os.FileInfo
+}
+
+// processedFile is what the state store remembers about a loaded file.
+type processedFile struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+func NewSFTPExtractor() *SFTPExtractor {
+	return &SFTPExtractor{}
+}
+
+func (e *SFTPExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+	opts := cfg.Source.SFTP
+
+	switch opts.Format {
+	case "csv":
+		parser, err := newCSVParser(cfg, ',')
+		if err != nil {
+			return err
+		}
+		e.csv, e.rejects = parser, parser.rejects
+	case "tsv":
+		parser, err := newCSVParser(cfg, '\t')
+		if err != nil {
+			return err
+		}
+		e.csv, e.rejects = parser, parser.rejects
+	case "jsonl":
+		rejects, err := newRowPolicy(cfg)
+		if err != nil {
+			return err
+		}
+		e.rejects = rejects
+	default:
+		return fmt.Errorf("sftp source requires format csv, tsv or jsonl, got %q", opts.Format)
+	}
+
+	patterns := cfg.Source.Files()
+	if len(patterns) == 0 {
+		return fmt.Errorf("sftp source requires path or paths")
+	}
+
+	store, err := state.Open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	e.state = store
+	e.processed = make(map[string]processedFile)
+	if _, err := store.Get(e.stateKey(), &e.processed); err != nil {
+		return err
+	}
+
+	// A client already set, as by tests, is used as it is.
+	if e.client == nil {
+		if err := e.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	files, err := e.list(patterns)
+	if err != nil {
+		return err
+	}
+	e.files = files
+
+	return nil
+}
+
+func (e *SFTPExtractor) connect(ctx context.Context) error {
+	opts := e.config.Source.SFTP
+	if opts.Host == "" || opts.User == "" {
+		return fmt.Errorf("sftp source requires host and user")
+	}
+
+	var auth []ssh.AuthMethod
+	if opts.PrivateKey != "" {
+		key, err := secrets.Resolve(opts.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("sftp private key: %w", err)
+		}
+		passphrase, err := secrets.Resolve(opts.Passphrase)
+		if err != nil {
+			return fmt.Errorf("sftp passphrase: %w", err)
+		}
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid sftp private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if opts.Password != "" {
+		password, err := secrets.Resolve(opts.Password)
+		if err != nil {
+			return fmt.Errorf("sftp password: %w", err)
+		}
+		auth = append(auth, ssh.Password(password))
+	}
+	if len(auth) == 0 {
+		return fmt.Errorf("sftp source requires a password or private_key")
+	}
+
+	hostKey, err := hostKeyCallback(opts)
+	if err != nil {
+		return err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultSFTPTimeout
+	}
+	port := opts.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(raw, addr, &ssh.ClientConfig{
+		User:            opts.User,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		raw.Close()
+		return fmt.Errorf("ssh handshake with %s failed: %w", addr, err)
+	}
+	e.conn = ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(e.conn)
+	if err != nil {
+		e.conn.Close()
+		e.conn = nil
+		return fmt.Errorf("failed to start sftp session: %w", err)
+	}
+	e.client = client
+	return nil
+}
+
+func hostKeyCallback(opts config.SFTPConfig) (ssh.HostKeyCallback, error) {
+	if opts.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := opts.KnownHosts
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("sftp source requires known_hosts: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// list expands the patterns on the server and drops files that were already
+// processed. A directory selects the files directly inside it.
+func (e *SFTPExtractor) list(patterns []string) ([]remoteFile, error) {
+	seen := make(map[string]bool)
+	var files []remoteFile
+	skipped := 0
+
+	add := func(p string, info os.FileInfo) {
+		if seen[p] || !info.Mode().IsRegular() || storage.IsHidden(info.Name()) {
+			return
+		}
+		seen[p] = true
+		if done, ok := e.processed[p]; ok && done.Size == info.Size() && done.ModTime.Equal(info.ModTime()) {
+			skipped++
+			return
+		}
+		files = append(files, remoteFile{path: p, info: info})
+	}
+
+	for _, pattern := range patterns {
+		matches, err := e.client.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		for _, m := range matches {
+			info, err := e.client.Stat(m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat %s: %w", m, err)
+			}
+			if !info.IsDir() {
+				add(m, info)
+				continue
+			}
+			entries, err := e.client.ReadDir(m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", m, err)
+			}
+			for _, entry := range entries {
+				add(path.Join(m, entry.Name()), entry)
+			}
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	log.Printf("Found %d new files on %s (%d already processed)", len(files), e.config.Source.SFTP.Host, skipped)
+	return files, nil
+}
+
+func (e *SFTPExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		for _, file := range e.files {
+			if err := e.extractFile(ctx, file, sendTo(ctx, records)); err != nil {
+				sendError(ctx, errs, fmt.Errorf("%s: %w", file.path, err))
+				return
+			}
+		}
+		e.rejects.report()
+	}()
+
+	return records, errs
+}
+
+func (e *SFTPExtractor) extractFile(ctx context.Context, file remoteFile, send func(pipeline.DataRecord) error) error {
+	f, err := e.client.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("failed to open: %w", err)
+	}
+	rc, err := storage.Decompress(f, file.path, e.config.Source.Compression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	defer rc.Close()
+
+	name := "sftp://" + e.config.Source.SFTP.Host + file.path
+	if e.csv != nil {
+		return e.csv.parse(ctx, rc, name, send)
+	}
+	return parseJSONL(rc, name, e.rejects, send)
+}
+
+// Commit records the files of this run as processed and then archives them.
+// A file that cannot be archived is still recorded, so it is not loaded
+// again.
+func (e *SFTPExtractor) Commit(ctx context.Context) error {
+	if len(e.files) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, f := range e.files {
+		e.processed[f.path] = processedFile{Size: f.info.Size(), ModTime: f.info.ModTime(), ProcessedAt: now}
+	}
+	if err := e.state.Put(e.stateKey(), e.processed); err != nil {
+		return err
+	}
+	if err := e.state.Save(); err != nil {
+		return err
+	}
+
+	if dir := e.config.Source.SFTP.ArchiveDir; dir != "" {
+		archived := 0
+		for _, f := range e.files {
+			// Files keep their path under the archive, so that files of
+			// the same name from different directories do not replace
+			// each other.
+			target := path.Join(dir, strings.TrimPrefix(path.Clean(f.path), "/"))
+			if err := e.client.MkdirAll(path.Dir(target)); err != nil {
+				log.Printf("Failed to archive %s: %v", f.path, err)
+				continue
+			}
+			if err := e.client.PosixRename(f.path, target); err != nil {
+				if err := e.client.Rename(f.path, target); err != nil {
+					log.Printf("Failed to archive %s: %v", f.path, err)
+					continue
+				}
+			}
+			archived++
+		}
+		log.Printf("Archived %d of %d files to %s", archived, len(e.files), dir)
+	}
+
+	e.files = nil
+	return nil
+}
+
+func (e *SFTPExtractor) stateKey() string {
+	return "sftp:" + e.config.Source.SFTP.Host
+}
+
+func (e *SFTPExtractor) Close() error {
+	var err error
+	if e.rejects != nil {
+		err = e.rejects.Close()
+	}
+	if e.client != nil {
+		if cerr := e.client.Close(); err == nil {
+			err = cerr
+		}
+		e.client = nil
+	}
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+	return err
+}
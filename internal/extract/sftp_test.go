@@ -0,0 +1,145 @@
+package extract
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/pkg/sftp"
+)
+
+// pipeSFTP returns an extractor whose client talks to an in-process SFTP
+// server over the local file system.
+func pipeSFTP(t *testing.T) *SFTPExtractor {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	server, err := sftp.NewServer(serverConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewSFTPExtractor()
+	e.client = client
+	return e
+}
+
+func runSFTP(t *testing.T, cfg *config.PipelineConfig, commit bool) []pipeline.DataRecord {
+	t.Helper()
+	ctx := context.Background()
+
+	e := pipeSFTP(t)
+	if err := e.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	records, errs := e.Extract(ctx)
+	var out []pipeline.DataRecord
+	for r := range records {
+		out = append(out, r)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if commit {
+		if err := e.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func TestSFTPExtractCommitAndArchive(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	writeFile(t, filepath.Join(in, "east", "logins.jsonl"), `{"id": "e1"}`+"\n"+`{"id": "e2"}`+"\n")
+	writeFile(t, filepath.Join(in, "west", "logins.jsonl"), `{"id": "w1"}`+"\n")
+	writeFile(t, filepath.Join(in, "east", ".logins.jsonl.part"), `{"id": "partial"}`+"\n")
+	writeFile(t, filepath.Join(in, "west", "_SUCCESS"), "")
+
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.StatePath = filepath.Join(dir, "state.json")
+	cfg.Source.Paths = []string{filepath.Join(in, "east"), filepath.Join(in, "west")}
+	cfg.Source.SFTP = config.SFTPConfig{
+		Host:       "exchange",
+		Format:     "jsonl",
+		ArchiveDir: filepath.Join(dir, "archive"),
+	}
+
+	ids := func(records []pipeline.DataRecord) []string {
+		var out []string
+		for _, r := range records {
+			out = append(out, r["id"].(string))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	// A run that is not committed leaves the files for the next one.
+	if got := ids(runSFTP(t, cfg, false)); len(got) != 3 {
+		t.Fatalf("first run read %v, want e1 e2 w1", got)
+	}
+	got := ids(runSFTP(t, cfg, true))
+	if len(got) != 3 || got[0] != "e1" || got[2] != "w1" {
+		t.Fatalf("second run read %v, want e1 e2 w1", got)
+	}
+
+	// Both files are archived at their own path, and the hidden ones stay.
+	for _, name := range []string{
+		filepath.Join(dir, "archive", in, "east", "logins.jsonl"),
+		filepath.Join(dir, "archive", in, "west", "logins.jsonl"),
+		filepath.Join(in, "east", ".logins.jsonl.part"),
+		filepath.Join(in, "west", "_SUCCESS"),
+	} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A file put back with the same size and time is recorded as processed.
+	archived := filepath.Join(dir, "archive", in, "west", "logins.jsonl")
+	if err := os.Rename(archived, filepath.Join(in, "west", "logins.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	if got := runSFTP(t, cfg, true); len(got) != 0 {
+		t.Fatalf("processed file read again: %v", got)
+	}
+}
+
+func TestSFTPBadGzipFailsTheRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "in", "logins.jsonl.gz"), `{"id": "e1"}`+"\n")
+
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.StatePath = filepath.Join(dir, "state.json")
+	cfg.Source.Path = filepath.Join(dir, "in")
+	cfg.Source.SFTP = config.SFTPConfig{Host: "exchange", Format: "jsonl"}
+
+	ctx := context.Background()
+	e := pipeSFTP(t)
+	if err := e.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	records, errs := e.Extract(ctx)
+	for r := range records {
+		t.Errorf("read %v from a file that is not gzipped", r)
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "failed to read gzip header") {
+		t.Fatalf("got %v, want a gzip error", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,192 @@
+package extract
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/schema"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// sourceQueries returns the table name and query pairs configured for a SQL
+// source. A bare source.table is read in full.
+func sourceQueries(src config.SourceConfig) ([]config.TableConfig, error) {
+	if len(src.Tables) == 0 {
+		if src.Table == "" {
+			return nil, fmt.Errorf("source requires either table or tables")
+		}
+		return []config.TableConfig{{Name: src.Table}}, nil
+	}
+
+	tables := make([]config.TableConfig, len(src.Tables))
+	for i, t := range src.Tables {
+		if t.Name == "" {
+			return nil, fmt.Errorf("source table %d has no name", i)
+		}
+		tables[i] = t
+	}
+	return tables, nil
+}
+
+// OpenSQL connects to the database of a SQL source, for components that run
+// their own queries against it. A sqlserver source uses its first server.
+func OpenSQL(ctx context.Context, src config.SourceConfig) (*sql.DB, error) {
+	shards, err := Shards(src)
+	if err != nil {
+		return nil, err
+	}
+	return openShard(ctx, src, shards[0])
+}
+
+// Shards returns the names of the databases a SQL source reads: the servers
+// of a sqlserver source, or the path of a sqlite one.
+func Shards(src config.SourceConfig) ([]string, error) {
+	switch src.Type {
+	case "sqlite":
+		if src.Path == "" {
+			return nil, fmt.Errorf("sqlite source requires a path")
+		}
+		return []string{src.Path}, nil
+	case "sqlserver":
+		if len(src.Servers) == 0 {
+			return nil, fmt.Errorf("sqlserver source requires servers")
+		}
+		return src.Servers, nil
+	default:
+		return nil, fmt.Errorf("%s is not a SQL source", src.Type)
+	}
+}
+
+func openShard(ctx context.Context, src config.SourceConfig, shard string) (*sql.DB, error) {
+	var (
+		db  *sql.DB
+		err error
+	)
+	switch src.Type {
+	case "sqlite":
+		if db, err = sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro", shard)); err != nil {
+			return nil, fmt.Errorf("failed to open sqlite database %s: %w", shard, err)
+		}
+	default:
+		connStr := fmt.Sprintf("server=%s;database=%s;", shard, src.Database)
+		if db, err = sql.Open("sqlserver", connStr); err != nil {
+			return nil, fmt.Errorf("failed to connect to server %s: %w", shard, err)
+		}
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s source: %w", src.Type, err)
+	}
+	return db, nil
+}
+
+// SourceSchema returns the fields of each table of a SQL source, read from
+// the column types of its query without fetching any rows. Columns of a
+// type the schema does not know are left out.
+func SourceSchema(ctx context.Context, src config.SourceConfig) (map[string][]schema.Field, error) {
+	db, err := OpenSQL(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	tables, err := sourceQueries(src)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]schema.Field, len(tables))
+	for _, table := range tables {
+		query := fmt.Sprintf("SELECT * FROM (%s) AS q WHERE 1 = 0", tableQuery(table))
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: query failed: %w", table.Name, err)
+		}
+		types, err := rows.ColumnTypes()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("table %s: failed to get column types: %w", table.Name, err)
+		}
+
+		fields := make([]schema.Field, 0, len(types))
+		for _, ct := range types {
+			declared := ct.DatabaseTypeName()
+			if src.Type == "sqlserver" && strings.EqualFold(declared, "int") {
+				declared = "int32"
+			}
+			f, err := schema.New(ct.Name(), declared)
+			if err != nil {
+				log.Printf("Schema of %s: leaving out column %s: %v", table.Name, ct.Name(), err)
+				continue
+			}
+			if nullable, ok := ct.Nullable(); ok {
+				f.Nullable = nullable
+			}
+			if precision, scale, ok := ct.DecimalSize(); ok && f.Type == schema.Decimal {
+				f.Precision, f.Scale = int(precision), int(scale)
+			}
+			if length, ok := ct.Length(); ok && f.Type == schema.String && length < 1<<30 {
+				f.Length = int(length)
+			}
+			f.MixedEndian = src.Type == "sqlserver"
+			fields = append(fields, f)
+		}
+		result[table.Name] = fields
+	}
+	return result, nil
+}
+
+// shardTag returns the shard to tag records with, which is none unless the
+// run is reconciled.
+func shardTag(cfg *config.PipelineConfig, shard string) string {
+	if !cfg.Reconcile.Enabled() {
+		return ""
+	}
+	return shard
+}
+
+func tableQuery(t config.TableConfig) string {
+	if t.Query != "" {
+		return t.Query
+	}
+	return fmt.Sprintf("SELECT * FROM %s", t.Name)
+}
+
+// queryRecords runs query against db and passes one record per row to send,
+// tagged with the given source table name and, unless it is empty, shard.
+// Records with a shard are added to the source counts of the run. The
+// driver is the one db was opened with.
+func queryRecords(ctx context.Context, db *sql.DB, driver, query, sourceTable, shard string, send func(pipeline.DataRecord) error) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	scanner, err := NewRowScanner(rows, driver)
+	if err != nil {
+		return err
+	}
+	counts := pipeline.SourceCountsFromContext(ctx)
+
+	for rows.Next() {
+		record, err := scanner.Scan(rows)
+		if err != nil {
+			return err
+		}
+		record[pipeline.FieldSourceTable] = sourceTable
+		if shard != "" {
+			record[pipeline.FieldSourceShard] = shard
+			counts.Add(shard, sourceTable, record)
+		}
+
+		if err := send(record); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,96 @@
+package extract
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteExtractor reads the configured tables or queries from a SQLite
+// database file. It is meant for local development and fixture-driven runs.
+type SQLiteExtractor struct {
+	config *config.PipelineConfig
+	db     *sql.DB
+	tables []config.TableConfig
+}
+
+func NewSQLiteExtractor() *SQLiteExtractor {
+	return &SQLiteExtractor{}
+}
+
+func (e *SQLiteExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.config = cfg
+
+	tables, err := sourceQueries(cfg.Source)
+	if err != nil {
+		return err
+	}
+	e.tables = tables
+
+	db, err := OpenSQL(ctx, cfg.Source)
+	if err != nil {
+		return err
+	}
+	e.db = db
+
+	return nil
+}
+
+func (e *SQLiteExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	records := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		if err := e.extract(ctx, sendTo(ctx, records)); err != nil {
+			errs <- err
+		}
+	}()
+
+	return records, errs
+}
+
+// ExtractBatches reads the tables as Extract does, passing the records on
+// in batches.
+func (e *SQLiteExtractor) ExtractBatches(ctx context.Context) (<-chan pipeline.Batch, <-chan error) {
+	batches := make(chan pipeline.Batch)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(batches)
+		defer close(errs)
+		sender := pipeline.NewBatchSender(ctx, batches, e.config.Pipeline.Batch)
+		defer sender.Stop()
+
+		if err := e.extract(ctx, sender.Send); err != nil {
+			errs <- err
+			return
+		}
+		sender.Flush()
+	}()
+
+	return batches, errs
+}
+
+func (e *SQLiteExtractor) extract(ctx context.Context, send func(pipeline.DataRecord) error) error {
+	shard := shardTag(e.config, e.config.Source.Path)
+	for _, table := range e.tables {
+		if err := queryRecords(ctx, e.db, "sqlite", tableQuery(table), table.Name, shard, send); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *SQLiteExtractor) Close() error {
+	if e.db != nil {
+		return e.db.Close()
+	}
+	return nil
+}
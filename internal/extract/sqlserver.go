@@ -14,6 +14,7 @@ import (
 type SQLServerExtractor struct {
 	config *config.PipelineConfig
 	dbs    []*sql.DB
+	tables []config.TableConfig
 }
 
 func NewSQLServerExtractor() *SQLServerExtractor {
@@ -22,6 +23,13 @@ func NewSQLServerExtractor() *SQLServerExtractor {
 
 func (e *SQLServerExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
 	e.config = cfg
+
+	tables, err := sourceQueries(cfg.Source)
+	if err != nil {
+		return err
+	}
+	e.tables = tables
+
 	e.dbs = make([]*sql.DB, len(cfg.Source.Servers))
 
 	for i, server := range cfg.Source.Servers {
@@ -39,64 +47,74 @@ func (e *SQLServerExtractor) Init(ctx context.Context, cfg *config.PipelineConfi
 func (e *SQLServerExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
 	records := make(chan pipeline.DataRecord)
 	errs := make(chan error, 1)
-	var wg sync.WaitGroup
-
-	for _, db := range e.dbs {
-		wg.Add(1)
-		go func(db *sql.DB) {
-			defer wg.Done()
-			if err := e.extractFromDB(ctx, db, records); err != nil {
-				errs <- err
-			}
-		}(db)
-	}
 
 	go func() {
-		wg.Wait()
-		close(records)
-		close(errs)
+		defer close(records)
+		defer close(errs)
+		if err := e.extract(ctx, sendTo(ctx, records)); err != nil {
+			errs <- err
+		}
 	}()
 
 	return records, errs
 }
 
-func (e *SQLServerExtractor) extractFromDB(ctx context.Context, db *sql.DB, records chan<- pipeline.DataRecord) error {
-	query := fmt.Sprintf("SELECT * FROM %s", e.config.Source.Table)
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return fmt.Errorf("query failed: %w", err)
-	}
-	defer rows.Close()
-
-	cols, err := rows.Columns()
-	if err != nil {
-		return fmt.Errorf("failed to get columns: %w", err)
-	}
+// ExtractBatches reads the servers as Extract does, passing the records on
+// in batches that may mix rows from different servers.
+func (e *SQLServerExtractor) ExtractBatches(ctx context.Context) (<-chan pipeline.Batch, <-chan error) {
+	batches := make(chan pipeline.Batch)
+	errs := make(chan error, 1)
 
-	for rows.Next() {
-		values := make([]interface{}, len(cols))
-		valuePtrs := make([]interface{}, len(cols))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+	go func() {
+		defer close(batches)
+		defer close(errs)
+		sender := pipeline.NewBatchSender(ctx, batches, e.config.Pipeline.Batch)
+		defer sender.Stop()
+
+		if err := e.extract(ctx, sender.Send); err != nil {
+			errs <- err
+			return
 		}
+		sender.Flush()
+	}()
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return fmt.Errorf("scan failed: %w", err)
-		}
+	return batches, errs
+}
 
-		record := make(pipeline.DataRecord)
-		for i, col := range cols {
-			record[col] = values[i]
-		}
+// extract reads every server at once. The first error stops the others and
+// is returned.
+func (e *SQLServerExtractor) extract(ctx context.Context, send func(pipeline.DataRecord) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for i, db := range e.dbs {
+		wg.Add(1)
+		go func(db *sql.DB, shard string) {
+			defer wg.Done()
+			if err := e.extractFromDB(ctx, db, shard, send); err != nil {
+				once.Do(func() {
+					first = err
+					cancel()
+				})
+			}
+		}(db, shardTag(e.config, e.config.Source.Servers[i]))
+	}
+	wg.Wait()
+	return first
+}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case records <- record:
+func (e *SQLServerExtractor) extractFromDB(ctx context.Context, db *sql.DB, shard string, send func(pipeline.DataRecord) error) error {
+	for _, table := range e.tables {
+		if err := queryRecords(ctx, db, "sqlserver", tableQuery(table), table.Name, shard, send); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
 		}
 	}
-
-	return rows.Err()
+	return nil
 }
 
 func (e *SQLServerExtractor) Close() error {
@@ -0,0 +1,113 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value kinds that text-based extractors convert fields into.
+const (
+	kindString    = "string"
+	kindInt       = "int"
+	kindFloat     = "float"
+	kindBool      = "bool"
+	kindTimestamp = "timestamp"
+)
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// valueKind maps a declared column type, including SQL-style names such as
+// VARCHAR(50) or BIGINT, onto the kind used to parse it.
+func valueKind(declared string) (string, error) {
+	t := strings.ToLower(strings.TrimSpace(declared))
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		t = strings.TrimSpace(t[:i])
+	}
+
+	switch t {
+	case "", "string", "text", "varchar", "nvarchar", "char", "nchar":
+		return kindString, nil
+	case "int", "integer", "bigint", "smallint", "tinyint", "int64", "long":
+		return kindInt, nil
+	case "float", "double", "real", "decimal", "numeric", "float64":
+		return kindFloat, nil
+	case "bool", "boolean", "bit":
+		return kindBool, nil
+	case "timestamp", "datetime", "date":
+		return kindTimestamp, nil
+	default:
+		return "", fmt.Errorf("unsupported column type: %s", declared)
+	}
+}
+
+func parseValue(s, kind string) (interface{}, error) {
+	switch kind {
+	case kindInt:
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case kindFloat:
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case kindBool:
+		return strconv.ParseBool(strings.TrimSpace(s))
+	case kindTimestamp:
+		return parseTimestamp(strings.TrimSpace(s))
+	default:
+		return s, nil
+	}
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised timestamp %q", s)
+}
+
+// inferKind returns the narrowest kind that every sample parses as. Empty
+// samples are treated as nulls and do not constrain the result. Numbers with
+// leading zeros, such as the dealer code 0001, are codes rather than
+// numbers and keep the column a string.
+func inferKind(samples []string) string {
+	candidates := []string{kindInt, kindFloat, kindBool, kindTimestamp}
+	seen := false
+
+	for _, s := range samples {
+		if s == "" {
+			continue
+		}
+		seen = true
+		remaining := candidates[:0]
+		for _, kind := range candidates {
+			if (kind == kindInt || kind == kindFloat) && leadingZero(s) {
+				continue
+			}
+			if _, err := parseValue(s, kind); err == nil {
+				remaining = append(remaining, kind)
+			}
+		}
+		candidates = remaining
+		if len(candidates) == 0 {
+			return kindString
+		}
+	}
+
+	if !seen {
+		return kindString
+	}
+	return candidates[0]
+}
+
+// leadingZero reports whether s is written with a zero before another digit,
+// as in 0001 or -007, which reading it as a number would drop.
+func leadingZero(s string) bool {
+	s = strings.TrimLeft(strings.TrimSpace(s), "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
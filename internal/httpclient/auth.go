@@ -0,0 +1,173 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+type authenticator interface {
+	apply(ctx context.Context, req *http.Request) error
+	// invalidate drops any cached credentials after a 401 and reports
+	// whether retrying could succeed with fresh ones.
+	invalidate() bool
+}
+
+func newAuthenticator(cfg config.AuthConfig, client *http.Client) (authenticator, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "", "none":
+		return noAuth{}, nil
+	case "bearer":
+		token, err := secrets.Resolve(cfg.Token)
+		if err != nil {
+			return nil, fmt.Errorf("bearer token: %w", err)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("bearer auth requires a token")
+		}
+		return bearerAuth{token: token}, nil
+	case "basic":
+		password, err := secrets.Resolve(cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth password: %w", err)
+		}
+		return basicAuth{username: cfg.Username, password: password}, nil
+	case "oauth2":
+		secret, err := secrets.Resolve(cfg.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client secret: %w", err)
+		}
+		if cfg.TokenURL == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("oauth2 auth requires token_url and client_id")
+		}
+		return &clientCredentials{
+			client:   client,
+			tokenURL: cfg.TokenURL,
+			id:       cfg.ClientID,
+			secret:   secret,
+			scopes:   cfg.Scopes,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", cfg.Type)
+	}
+}
+
+type noAuth struct{}
+
+func (noAuth) apply(ctx context.Context, req *http.Request) error { return nil }
+func (noAuth) invalidate() bool                                   { return false }
+
+type bearerAuth struct {
+	token string
+}
+
+func (a bearerAuth) apply(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (bearerAuth) invalidate() bool { return false }
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a basicAuth) apply(ctx context.Context, req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (basicAuth) invalidate() bool { return false }
+
+// clientCredentials implements the OAuth2 client credentials grant, caching
+// the access token until shortly before it expires.
+type clientCredentials struct {
+	client   *http.Client
+	tokenURL string
+	id       string
+	secret   string
+	scopes   []string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (a *clientCredentials) apply(ctx context.Context, req *http.Request) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *clientCredentials) invalidate() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+	return true
+}
+
+func (a *clientCredentials) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.expires) {
+		return a.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("invalid oauth2 token url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.id), url.QueryEscape(a.secret))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read oauth2 token response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("oauth2 token request failed: %w", &StatusError{StatusCode: resp.StatusCode, Body: truncate(string(body), 512)})
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", fmt.Errorf("invalid oauth2 token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token response has no access_token")
+	}
+
+	a.token = tok.AccessToken
+	a.expires = time.Now().Add(time.Hour)
+	if tok.ExpiresIn > 0 {
+		a.expires = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - 30*time.Second)
+	}
+	return a.token, nil
+}
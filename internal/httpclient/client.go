@@ -0,0 +1,253 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 5 * time.Minute
+	maxBodySize       = 256 * 1024 * 1024
+)
+
+// Client sends requests with the configured headers and credentials,
+// spaces them out according to the rate limit, and retries 429 and 5xx
+// responses and transport errors with exponential backoff. Retry-After is
+// honoured when the server sends it.
+type Client struct {
+	http       *http.Client
+	headers    map[string]string
+	auth       authenticator
+	retries    int
+	retryDelay time.Duration
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// StatusError is returned for responses that are not 2xx once retries are
+// exhausted or when the status is not retryable.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// URLFuncs are the functions available to url templates. secret resolves
+// env:NAME and file:/path references like the other credentials.
+var URLFuncs = map[string]interface{}{"secret": secrets.Resolve}
+
+func New(cfg config.HTTPConfig) (*Client, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	retryDelay := cfg.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = defaultRetryDelay
+	}
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("retries must be non-negative")
+	}
+	if cfg.RateLimit < 0 {
+		return nil, fmt.Errorf("rate_limit must be non-negative")
+	}
+
+	c := &Client{
+		http:       &http.Client{Timeout: timeout},
+		headers:    cfg.Headers,
+		retries:    cfg.Retries,
+		retryDelay: retryDelay,
+	}
+	if cfg.RateLimit > 0 {
+		c.interval = time.Duration(float64(time.Second) / cfg.RateLimit)
+	}
+
+	auth, err := newAuthenticator(cfg.Auth, c.http)
+	if err != nil {
+		return nil, err
+	}
+	c.auth = auth
+
+	return c, nil
+}
+
+// Do sends the request and returns the response with its body already read.
+// header is added after the configured headers, so it takes precedence.
+func (c *Client) Do(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	var lastErr error
+
+	refreshed := false
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		resp, data, err := c.once(ctx, method, url, body, header)
+		if err == nil && resp.StatusCode/100 == 2 {
+			return resp, data, nil
+		}
+
+		var wait time.Duration
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			lastErr = err
+		} else {
+			lastErr = &StatusError{StatusCode: resp.StatusCode, Body: truncate(string(data), 512)}
+			if resp.StatusCode == http.StatusUnauthorized && !refreshed && c.auth.invalidate() {
+				// Fresh credentials get one immediate attempt of their
+				// own, outside the retries.
+				refreshed = true
+				attempt--
+				continue
+			}
+			if !retryable(resp.StatusCode) {
+				return resp, data, lastErr
+			}
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		if attempt == c.retries {
+			break
+		}
+		if wait == 0 {
+			wait = c.retryDelay << attempt
+		}
+		if wait > maxRetryDelay {
+			wait = maxRetryDelay
+		}
+		log.Printf("HTTP %s %s failed (attempt %d/%d), retrying in %s: %v",
+			method, Redact(url), attempt+1, c.retries+1, wait, lastErr)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	return nil, nil, lastErr
+}
+
+func (c *Client) once(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if err := c.auth.apply(ctx, req); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*neturl.Error); ok {
+			urlErr.URL = Redact(urlErr.URL)
+		}
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp, data, nil
+}
+
+// wait blocks until the rate limit allows another request.
+func (c *Client) wait(ctx context.Context) error {
+	if c.interval == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.next
+	if slot.Before(now) {
+		slot = now
+	}
+	c.next = slot.Add(c.interval)
+	c.mu.Unlock()
+
+	if d := time.Until(slot); d > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	return nil
+}
+
+// Redact drops the query string and user info from a url, where templates
+// usually put API keys, so that it can be logged.
+func Redact(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "(invalid url)"
+	}
+	if u.User != nil {
+		u.User = neturl.User("xxxxx")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "xxxxx"
+	}
+	return u.String()
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,83 @@
+package kafkaclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/hamba/avro/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+const defaultClientID = "etl-framework"
+
+// Options returns the connection options shared by the Kafka source and
+// sink: brokers, client id, TLS and SASL.
+func Options(cfg config.KafkaConfig) ([]kgo.Opt, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka requires at least one broker")
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = defaultClientID
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(clientID),
+	}
+
+	if cfg.TLS {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+
+	if cfg.SASL.Mechanism != "" {
+		password, err := secrets.Resolve(cfg.SASL.Password)
+		if err != nil {
+			return nil, fmt.Errorf("kafka sasl password: %w", err)
+		}
+		user, pass := cfg.SASL.Username, password
+
+		switch strings.ToLower(cfg.SASL.Mechanism) {
+		case "plain":
+			opts = append(opts, kgo.SASL(plain.Auth{User: user, Pass: pass}.AsMechanism()))
+		case "scram-sha-256":
+			opts = append(opts, kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha256Mechanism()))
+		case "scram-sha-512":
+			opts = append(opts, kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha512Mechanism()))
+		default:
+			return nil, fmt.Errorf("unsupported sasl mechanism: %s", cfg.SASL.Mechanism)
+		}
+	}
+
+	return opts, nil
+}
+
+// Schema validates the encoding and returns the Avro schema for it, or nil
+// for JSON.
+func Schema(cfg config.KafkaConfig) (avro.Schema, error) {
+	switch strings.ToLower(cfg.Encoding) {
+	case "", "json":
+		return nil, nil
+	case "avro":
+		if cfg.SchemaFile == "" {
+			return nil, fmt.Errorf("avro encoding requires schema_file")
+		}
+		data, err := os.ReadFile(cfg.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		schema, err := avro.Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid avro schema %s: %w", cfg.SchemaFile, err)
+		}
+		return schema, nil
+	default:
+		return nil, fmt.Errorf("unsupported kafka encoding: %s", cfg.Encoding)
+	}
+}
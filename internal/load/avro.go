@@ -0,0 +1,137 @@
+package load
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/hamba/avro/v2"
+)
+
+// avroValue converts a record value to the Go type the Avro encoder expects
+// for schema, which unlike JSON does not accept e.g. an int64 for an int
+// field or a json.Number for a long.
+func avroValue(schema avro.Schema, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return avroValue(s.Schema(), v)
+	case *avro.PrimitiveSchema:
+		return avroPrimitive(s, v)
+	case *avro.RecordSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %T to record %s", v, s.FullName())
+		}
+		out := make(map[string]interface{}, len(s.Fields()))
+		for _, f := range s.Fields() {
+			fv, ok := m[f.Name()]
+			if !ok {
+				if f.HasDefault() {
+					continue
+				}
+				return nil, fmt.Errorf("missing field %s", f.Name())
+			}
+			converted, err := avroValue(f.Type(), fv)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name(), err)
+			}
+			out[f.Name()] = converted
+		}
+		return out, nil
+	case *avro.UnionSchema:
+		if s.Nullable() {
+			for _, t := range s.Types() {
+				if t.Type() != avro.Null {
+					return avroValue(t, v)
+				}
+			}
+		}
+		for _, t := range s.Types() {
+			if converted, err := avroValue(t, v); err == nil {
+				return converted, nil
+			}
+		}
+		return nil, fmt.Errorf("value %v matches no type in union", v)
+	case *avro.ArraySchema:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %T to an array", v)
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			converted, err := avroValue(s.Items(), item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			out[i] = converted
+		}
+		return out, nil
+	case *avro.MapSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %T to a map", v)
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, mv := range m {
+			converted, err := avroValue(s.Values(), mv)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", k, err)
+			}
+			out[k] = converted
+		}
+		return out, nil
+	case *avro.EnumSchema:
+		return toString(v), nil
+	default:
+		return v, nil
+	}
+}
+
+func avroPrimitive(s *avro.PrimitiveSchema, v interface{}) (interface{}, error) {
+	if logical := s.Logical(); logical != nil {
+		switch logical.Type() {
+		case avro.Date, avro.TimestampMillis, avro.TimestampMicros, avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+			return toTime(v)
+		case avro.Decimal:
+			r, ok := new(big.Rat).SetString(toString(v))
+			if !ok {
+				return nil, fmt.Errorf("invalid decimal %v", v)
+			}
+			return r, nil
+		}
+	}
+
+	switch s.Type() {
+	case avro.Int:
+		i, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		if i > math.MaxInt32 || i < math.MinInt32 {
+			return nil, fmt.Errorf("value %d overflows int", i)
+		}
+		return int(i), nil
+	case avro.Long:
+		return toInt64(v)
+	case avro.Float:
+		f, err := toFloat64(v)
+		return float32(f), err
+	case avro.Double:
+		return toFloat64(v)
+	case avro.Boolean:
+		return toBool(v)
+	case avro.String:
+		return toString(v), nil
+	case avro.Bytes:
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+		return []byte(toString(v)), nil
+	default:
+		return v, nil
+	}
+}
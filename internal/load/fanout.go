@@ -0,0 +1,164 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const defaultSinkBuffer = 1000
+
+// FanOutLoader writes every record to several sinks concurrently. Each sink
+// reads from its own bounded buffer, so a slow sink holds back the pipeline
+// instead of accumulating records in memory. A failing required sink fails
+// the run; a failing best_effort sink is logged and dropped while the other
+// sinks carry on.
+type FanOutLoader struct {
+	sinks   []config.SinkConfig
+	loaders []pipeline.Loader
+	active  []bool
+}
+
+type branch struct {
+	name       string
+	bestEffort bool
+	records    chan pipeline.DataRecord
+	done       chan struct{}
+	err        error
+}
+
+func NewFanOutLoader(sinks []config.SinkConfig) (*FanOutLoader, error) {
+	f := &FanOutLoader{sinks: sinks}
+	for i, sink := range sinks {
+		loader, err := New(sink.Type)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", sink.DisplayName(i), err)
+		}
+		f.loaders = append(f.loaders, loader)
+	}
+	return f, nil
+}
+
+// Init initialises each loader with a copy of the config whose sink is the
+// loader's own entry of sinks.
+func (f *FanOutLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	f.active = make([]bool, len(f.loaders))
+
+	for i, loader := range f.loaders {
+		sink := f.sinks[i]
+		child := *cfg
+		child.Sink = sink
+		child.Sinks = nil
+
+		if err := loader.Init(ctx, &child); err != nil {
+			if !sink.BestEffort() {
+				return fmt.Errorf("sink %s: %w", sink.DisplayName(i), err)
+			}
+			log.Printf("Skipping best-effort sink %s: %v", sink.DisplayName(i), err)
+			continue
+		}
+		f.active[i] = true
+	}
+	return nil
+}
+
+func (f *FanOutLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		branches []*branch
+		wg       sync.WaitGroup
+	)
+	for i, loader := range f.loaders {
+		if !f.active[i] {
+			continue
+		}
+		sink := f.sinks[i]
+		size := sink.Buffer
+		if size <= 0 {
+			size = defaultSinkBuffer
+		}
+		b := &branch{
+			name:       sink.DisplayName(i),
+			bestEffort: sink.BestEffort(),
+			records:    make(chan pipeline.DataRecord, size),
+			done:       make(chan struct{}),
+		}
+		branches = append(branches, b)
+
+		wg.Add(1)
+		go func(loader pipeline.Loader) {
+			defer wg.Done()
+			defer close(b.done)
+			b.err = loader.Load(ctx, b.records)
+			if b.err != nil && !b.bestEffort {
+				cancel()
+			}
+		}(loader)
+	}
+
+	err := f.broadcast(ctx, input, branches)
+	for _, b := range branches {
+		close(b.records)
+	}
+	wg.Wait()
+
+	for _, b := range branches {
+		if b.err != nil && !b.bestEffort {
+			return fmt.Errorf("sink %s: %w", b.name, b.err)
+		}
+	}
+	for _, b := range branches {
+		if b.err != nil {
+			log.Printf("Best-effort sink %s failed: %v", b.name, b.err)
+		}
+	}
+	return err
+}
+
+// broadcast hands each record to every sink that is still running. Every
+// sink but the last gets a shallow copy, so that a loader adding fields does
+// not affect the others; the last gets the record itself, once the copies
+// have been made.
+func (f *FanOutLoader) broadcast(ctx context.Context, input <-chan pipeline.DataRecord, branches []*branch) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case record, ok := <-input:
+			if !ok {
+				return nil
+			}
+			for i, b := range branches {
+				r := record
+				if i < len(branches)-1 {
+					r = make(pipeline.DataRecord, len(record))
+					for k, v := range record {
+						r[k] = v
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-b.done:
+				case b.records <- r:
+				}
+			}
+		}
+	}
+}
+
+func (f *FanOutLoader) Close() error {
+	var first error
+	for i, loader := range f.loaders {
+		if err := loader.Close(); err != nil && first == nil {
+			first = fmt.Errorf("sink %s: %w", f.sinks[i].DisplayName(i), err)
+		}
+	}
+	return first
+}
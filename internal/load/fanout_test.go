@@ -0,0 +1,57 @@
+package load
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// taggingLoader marks every record it loads with its name, as a loader
+// adding fields does.
+type taggingLoader struct {
+	name string
+	seen []string
+}
+
+func (l *taggingLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error { return nil }
+
+func (l *taggingLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	for r := range input {
+		if by, ok := r["loaded_by"]; ok {
+			l.seen = append(l.seen, by.(string))
+		}
+		r["loaded_by"] = l.name
+	}
+	return nil
+}
+
+func (l *taggingLoader) Close() error { return nil }
+
+func TestFanOutGivesEachSinkItsOwnRecord(t *testing.T) {
+	loaders := []*taggingLoader{{name: "a"}, {name: "b"}, {name: "c"}}
+	f := &FanOutLoader{
+		sinks:  []config.SinkConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+		active: []bool{true, true, true},
+	}
+	for _, l := range loaders {
+		f.loaders = append(f.loaders, l)
+	}
+
+	input := make(chan pipeline.DataRecord)
+	go func() {
+		defer close(input)
+		for i := 0; i < 100; i++ {
+			input <- pipeline.DataRecord{"id": i}
+		}
+	}()
+	if err := f.Load(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range loaders {
+		if len(l.seen) > 0 {
+			t.Fatalf("sink %s saw records changed by sink %s", l.name, l.seen[0])
+		}
+	}
+}
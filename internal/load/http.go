@@ -0,0 +1,345 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/httpclient"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	defaultFlushInterval = time.Second
+)
+
+// HTTPLoader pushes records to a webhook as JSON. With a batch_size above
+// one, records rendering to the same url are sent together as a JSON array;
+// a batch that is not full is sent once it has waited for the flush interval.
+// Requests are retried by the HTTP client; deliveries that still fail either
+// fail the run or, with on_failure: dead_letter, are written to the
+// pipeline's dead letter file.
+type HTTPLoader struct {
+	config      *config.PipelineConfig
+	client      *httpclient.Client
+	url         *template.Template
+	method      string
+	batchSize   int
+	interval    time.Duration
+	concurrency int
+	deadLetter  *pipeline.DeadLetter
+
+	delivered int64
+	requests  int64
+}
+
+// delivery is one request: a single record, or a batch sharing a url.
+type delivery struct {
+	url     string
+	records []pipeline.DataRecord
+}
+
+func NewHTTPLoader() *HTTPLoader {
+	return &HTTPLoader{}
+}
+
+func (l *HTTPLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.config = cfg
+	opts := cfg.Sink.HTTP
+
+	if opts.URL == "" {
+		return fmt.Errorf("http sink requires a url")
+	}
+	tmpl, err := template.New("url").
+		Funcs(httpclient.URLFuncs).
+		Option("missingkey=error").
+		Parse(opts.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url template: %w", err)
+	}
+	l.url = tmpl
+
+	l.method = strings.ToUpper(opts.Method)
+	if l.method == "" {
+		l.method = http.MethodPost
+	}
+
+	if cfg.Sink.BatchSize < 0 || cfg.Sink.Concurrency < 0 {
+		return fmt.Errorf("batch_size and concurrency must be non-negative")
+	}
+	l.batchSize = cfg.Sink.BatchSize
+	if l.batchSize == 0 {
+		l.batchSize = 1
+	}
+	l.interval = cfg.Sink.FlushInterval
+	if l.interval == 0 {
+		l.interval = defaultFlushInterval
+	}
+	l.concurrency = cfg.Sink.Concurrency
+	if l.concurrency == 0 {
+		l.concurrency = 1
+	}
+
+	switch cfg.Sink.OnFailure {
+	case "", "fail":
+	case "dead_letter":
+		if cfg.Pipeline.DeadLetter.Path == "" {
+			return fmt.Errorf("on_failure: dead_letter requires pipeline.dead_letter.path")
+		}
+		l.deadLetter = pipeline.NewDeadLetter(cfg.Pipeline.DeadLetter.Path, cfg.Pipeline.Name)
+	default:
+		return fmt.Errorf("unsupported on_failure: %s", cfg.Sink.OnFailure)
+	}
+
+	client, err := httpclient.New(opts)
+	if err != nil {
+		return err
+	}
+	l.client = client
+
+	atomic.StoreInt64(&l.delivered, 0)
+	atomic.StoreInt64(&l.requests, 0)
+
+	return nil
+}
+
+func (l *HTTPLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan delivery, l.concurrency)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < l.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				if err := l.deliver(ctx, d); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	err := l.dispatch(ctx, input, jobs)
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Delivered %d records in %d requests", atomic.LoadInt64(&l.delivered), atomic.LoadInt64(&l.requests))
+	if l.deadLetter != nil && l.deadLetter.Count() > 0 {
+		msg += fmt.Sprintf(", %d dead-lettered to %s", l.deadLetter.Count(), l.deadLetter.Path())
+	}
+	log.Print(msg)
+	return nil
+}
+
+// dispatch groups records into deliveries and hands them to the workers. A
+// batch is sent once it is full, once it has waited for the flush interval,
+// or at the end of the input.
+func (l *HTTPLoader) dispatch(ctx context.Context, input <-chan pipeline.DataRecord, jobs chan<- delivery) error {
+	pending := make(map[string][]pipeline.DataRecord)
+	started := make(map[string]time.Time)
+
+	// the timer fires when the oldest pending batch is due; it is only
+	// running while a batch is pending
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	var due <-chan time.Time
+	arm := func() {
+		timer.Stop()
+		due = nil
+		if l.interval <= 0 || len(started) == 0 {
+			return
+		}
+		var oldest time.Time
+		for _, at := range started {
+			if oldest.IsZero() || at.Before(oldest) {
+				oldest = at
+			}
+		}
+		timer.Reset(time.Until(oldest.Add(l.interval)))
+		due = timer.C
+	}
+
+	send := func(u string) error {
+		d := delivery{url: u, records: pending[u]}
+		delete(pending, u)
+		delete(started, u)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case jobs <- d:
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-due:
+			urls := make([]string, 0, len(started))
+			for u, at := range started {
+				if !now.Before(at.Add(l.interval)) {
+					urls = append(urls, u)
+				}
+			}
+			sort.Strings(urls)
+			for _, u := range urls {
+				if err := send(u); err != nil {
+					return err
+				}
+			}
+			arm()
+		case record, ok := <-input:
+			if !ok {
+				urls := make([]string, 0, len(pending))
+				for u := range pending {
+					urls = append(urls, u)
+				}
+				sort.Strings(urls)
+				for _, u := range urls {
+					if err := send(u); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			u, err := l.renderURL(record)
+			if err != nil {
+				if err := l.failed(delivery{records: []pipeline.DataRecord{record}}, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, ok := pending[u]; !ok && l.batchSize > 1 {
+				started[u] = time.Now()
+				if due == nil {
+					arm()
+				}
+			}
+			pending[u] = append(pending[u], record)
+			if len(pending[u]) < l.batchSize {
+				continue
+			}
+			if err := send(u); err != nil {
+				return err
+			}
+			arm()
+		}
+	}
+}
+
+func (l *HTTPLoader) deliver(ctx context.Context, d delivery) error {
+	var payload interface{} = d.records
+	if l.batchSize == 1 {
+		payload = d.records[0]
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return l.failed(d, fmt.Errorf("failed to encode records: %w", err))
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if key := l.idempotencyKey(d.records); key != "" {
+		header.Set(idempotencyHeader, key)
+	}
+
+	atomic.AddInt64(&l.requests, 1)
+	if _, _, err := l.client.Do(ctx, l.method, d.url, body, header); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return l.failed(d, fmt.Errorf("delivery to %s failed: %w", httpclient.Redact(d.url), err))
+	}
+	atomic.AddInt64(&l.delivered, int64(len(d.records)))
+	return nil
+}
+
+// failed dead-letters the records of a delivery that could not be made, or
+// returns the error when no dead letter file is configured.
+func (l *HTTPLoader) failed(d delivery, cause error) error {
+	if l.deadLetter == nil {
+		return cause
+	}
+	for _, record := range d.records {
+		if err := l.deadLetter.Write("load", record, cause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *HTTPLoader) renderURL(record pipeline.DataRecord) (string, error) {
+	var buf bytes.Buffer
+	if err := l.url.Execute(&buf, map[string]interface{}(record)); err != nil {
+		return "", fmt.Errorf("failed to render url: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// idempotencyKey takes the key from the configured record field. Batches
+// are keyed by a hash of their records' keys, so a retried batch carries the
+// same key as the original.
+func (l *HTTPLoader) idempotencyKey(records []pipeline.DataRecord) string {
+	field := l.config.Sink.HTTP.IdempotencyKey
+	if field == "" {
+		return ""
+	}
+	if l.batchSize == 1 {
+		if v, ok := records[0][field]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	h := sha256.New()
+	for _, record := range records {
+		if v, ok := record[field]; ok && v != nil {
+			fmt.Fprint(h, v)
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (l *HTTPLoader) Close() error {
+	if l.deadLetter != nil {
+		return l.deadLetter.Close()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestHTTPFlushesPartialBatch(t *testing.T) {
+	batches := make(chan int, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var records []pipeline.DataRecord
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+			t.Error(err)
+		}
+		batches <- len(records)
+	}))
+	defer srv.Close()
+
+	cfg := &config.PipelineConfig{}
+	cfg.Sink.HTTP.URL = srv.URL
+	cfg.Sink.BatchSize = 10
+	cfg.Sink.FlushInterval = 50 * time.Millisecond
+
+	l := NewHTTPLoader()
+	if err := l.Init(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	input := make(chan pipeline.DataRecord)
+	done := make(chan error, 1)
+	go func() { done <- l.Load(context.Background(), input) }()
+
+	for i := 0; i < 3; i++ {
+		input <- pipeline.DataRecord{"id": i}
+	}
+	// the input stays open, so only the flush interval sends the batch
+	select {
+	case n := <-batches:
+		if n != 3 {
+			t.Fatalf("sent a batch of %d records, want 3", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial batch not sent while the input was open")
+	}
+
+	input <- pipeline.DataRecord{"id": 3}
+	close(input)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := <-batches; n != 1 {
+		t.Fatalf("sent a final batch of %d records, want 1", n)
+	}
+}
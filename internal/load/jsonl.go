@@ -0,0 +1,214 @@
+package load
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// JSONLLoader writes records as newline-delimited JSON into sink.path,
+// starting a new file whenever a rotation limit is reached. Each file is
+// written under a temporary name and renamed once complete. Files are named
+// after the run, so that they can be found from its metadata.
+type JSONLLoader struct {
+	config   *config.PipelineConfig
+	dir      string
+	gzip     bool
+	prefix   string
+	files    []string
+	current  *jsonlFile
+	sequence int
+	count    int
+}
+
+type jsonlFile struct {
+	path    string
+	out     storage.AtomicWriter
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	records int
+	bytes   int64
+}
+
+func NewJSONLLoader() *JSONLLoader {
+	return &JSONLLoader{}
+}
+
+func (l *JSONLLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.config = cfg
+
+	if cfg.Sink.Path == "" {
+		return fmt.Errorf("jsonl sink requires a path")
+	}
+	l.dir = cfg.Sink.Path
+
+	switch strings.ToLower(cfg.Sink.Compression) {
+	case "", "none":
+	case "gzip", "gz":
+		l.gzip = true
+	default:
+		return fmt.Errorf("unsupported compression: %s", cfg.Sink.Compression)
+	}
+
+	if cfg.Sink.Rotate.MaxRecords < 0 || cfg.Sink.Rotate.MaxBytes < 0 {
+		return fmt.Errorf("rotation limits must be non-negative")
+	}
+
+	// Outside the orchestrator there is no run, and the time stands in.
+	runID := time.Now().UTC().Format("20060102T150405Z")
+	if run := pipeline.RunFromContext(ctx); run != nil {
+		runID = run.ID
+	}
+	l.prefix = fmt.Sprintf("%s-%s", cfg.Pipeline.Name, runID)
+	l.files = nil
+	l.sequence = 0
+	l.count = 0
+
+	return nil
+}
+
+func (l *JSONLLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	return l.LoadBatches(ctx, pipeline.Batches(ctx, input, l.config.Pipeline.Batch))
+}
+
+func (l *JSONLLoader) LoadBatches(ctx context.Context, input <-chan pipeline.Batch) error {
+	for {
+		select {
+		case <-ctx.Done():
+			l.abort()
+			return ctx.Err()
+		case batch, ok := <-input:
+			if !ok {
+				// Batches also ends its output when the run is
+				// cancelled, which must not complete the last file.
+				if err := ctx.Err(); err != nil {
+					l.abort()
+					return err
+				}
+				if err := l.finish(); err != nil {
+					return err
+				}
+				log.Printf("Wrote %d records to %d jsonl files in %s", l.count, len(l.files), l.dir)
+				return nil
+			}
+
+			for _, record := range batch {
+				if err := l.write(ctx, record); err != nil {
+					l.abort()
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (l *JSONLLoader) write(ctx context.Context, record pipeline.DataRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.current != nil && l.full(int64(len(line))) {
+		if err := l.finish(); err != nil {
+			return err
+		}
+	}
+	if l.current == nil {
+		if err := l.open(ctx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := l.current.buf.Write(line); err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.current.path, err)
+	}
+	l.current.records++
+	l.current.bytes += int64(len(line))
+	l.count++
+	return nil
+}
+
+func (l *JSONLLoader) full(next int64) bool {
+	limits := l.config.Sink.Rotate
+	if limits.MaxRecords > 0 && l.current.records >= limits.MaxRecords {
+		return true
+	}
+	return limits.MaxBytes > 0 && l.current.bytes+next > limits.MaxBytes
+}
+
+func (l *JSONLLoader) open(ctx context.Context) error {
+	l.sequence++
+	name := fmt.Sprintf("%s-%05d.jsonl", l.prefix, l.sequence)
+	if l.gzip {
+		name += ".gz"
+	}
+	path := storage.Join(l.dir, name)
+
+	out, err := storage.Create(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	f := &jsonlFile{path: path, out: out}
+	var w io.Writer = out
+	if l.gzip {
+		f.gz = gzip.NewWriter(out)
+		w = f.gz
+	}
+	f.buf = bufio.NewWriterSize(w, 256*1024)
+	l.current = f
+	return nil
+}
+
+func (l *JSONLLoader) finish() error {
+	f := l.current
+	if f == nil {
+		return nil
+	}
+	l.current = nil
+
+	if err := f.buf.Flush(); err != nil {
+		f.out.Abort()
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.out.Abort()
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+	}
+	if err := f.out.Close(); err != nil {
+		return err
+	}
+
+	l.files = append(l.files, f.path)
+	return nil
+}
+
+func (l *JSONLLoader) abort() {
+	if l.current != nil {
+		l.current.out.Abort()
+		l.current = nil
+	}
+}
+
+// Files returns the paths of the files completed so far.
+func (l *JSONLLoader) Files() []string {
+	return l.files
+}
+
+func (l *JSONLLoader) Close() error {
+	l.abort()
+	return nil
+}
@@ -0,0 +1,135 @@
+package load
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func jsonlSinkConfig(t *testing.T) *config.PipelineConfig {
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.Name = "logins"
+	cfg.Sink.Type = "jsonl"
+	cfg.Sink.Path = t.TempDir()
+	return cfg
+}
+
+func TestJSONLRotatesFilesNamedAfterTheRun(t *testing.T) {
+	cfg := jsonlSinkConfig(t)
+	cfg.Sink.Compression = "gzip"
+	cfg.Sink.Rotate.MaxRecords = 4
+	cfg.Pipeline.Batch.Size = 3
+
+	run := pipeline.NewRun(cfg.Pipeline.Name)
+	ctx := pipeline.WithRun(context.Background(), run)
+	l := NewJSONLLoader()
+	if err := l.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	input := make(chan pipeline.DataRecord)
+	go func() {
+		defer close(input)
+		for i := 0; i < 10; i++ {
+			input <- pipeline.DataRecord{"id": i}
+		}
+	}()
+	if err := l.Load(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for i := 1; i <= 3; i++ {
+		want = append(want, filepath.Join(cfg.Sink.Path, fmt.Sprintf("logins-%s-%05d.jsonl.gz", run.ID, i)))
+	}
+	if fmt.Sprint(l.Files()) != fmt.Sprint(want) {
+		t.Fatalf("files %v, want %v", l.Files(), want)
+	}
+	entries, err := os.ReadDir(cfg.Sink.Path)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("%d entries in the sink, %v", len(entries), err)
+	}
+
+	id := 0
+	for i, path := range l.Files() {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := 0
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var record map[string]int
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			if record["id"] != id {
+				t.Fatalf("%s has id %d, want %d", path, record["id"], id)
+			}
+			id++
+			lines++
+		}
+		f.Close()
+		if want := []int{4, 4, 2}[i]; lines != want {
+			t.Errorf("%s has %d records, want %d", path, lines, want)
+		}
+	}
+}
+
+func TestJSONLCancelledRunLeavesNoFiles(t *testing.T) {
+	cfg := jsonlSinkConfig(t)
+	cfg.Pipeline.Batch.Size = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewJSONLLoader()
+	if err := l.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The run is cancelled with the input still open, as the orchestrator
+	// does when another stage fails.
+	input := make(chan pipeline.DataRecord)
+	go func() {
+		for i := 0; i < 3; i++ {
+			input <- pipeline.DataRecord{"id": i}
+		}
+		cancel()
+	}()
+	if err := l.Load(ctx, input); err != context.Canceled {
+		t.Fatalf("got %v, want the run cancelled", err)
+	}
+	if len(l.Files()) != 0 {
+		t.Errorf("completed %v", l.Files())
+	}
+	if entries, _ := os.ReadDir(cfg.Sink.Path); len(entries) != 0 {
+		t.Errorf("%d files left in the sink", len(entries))
+	}
+}
+
+func TestJSONLRejectsInvalidSinks(t *testing.T) {
+	for _, change := range []func(*config.PipelineConfig){
+		func(cfg *config.PipelineConfig) { cfg.Sink.Path = "" },
+		func(cfg *config.PipelineConfig) { cfg.Sink.Compression = "zstd" },
+		func(cfg *config.PipelineConfig) { cfg.Sink.Rotate.MaxBytes = -1 },
+	} {
+		cfg := jsonlSinkConfig(t)
+		change(cfg)
+		if err := NewJSONLLoader().Init(context.Background(), cfg); err == nil {
+			t.Errorf("sink %+v accepted", cfg.Sink)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/aniketwaliyan/etl-framework/internal/kafkaclient"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/hamba/avro/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// KafkaLoader produces each record as a JSON or Avro message to sink.kafka.topic,
+// keyed by the configured record field so that records with the same key
+// land on the same partition. Messages are batched per partition and the
+// producer is idempotent with acks from all in-sync replicas, so broker
+// retries do not create duplicates.
+type KafkaLoader struct {
+	config *config.PipelineConfig
+	client *kgo.Client
+	schema avro.Schema
+}
+
+func NewKafkaLoader() *KafkaLoader {
+	return &KafkaLoader{}
+}
+
+func (l *KafkaLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.config = cfg
+	opts := cfg.Sink.Kafka
+
+	if opts.Topic == "" {
+		return fmt.Errorf("kafka sink requires a topic")
+	}
+	if opts.Linger < 0 || opts.BatchBytes < 0 {
+		return fmt.Errorf("linger and batch_bytes must be non-negative")
+	}
+
+	schema, err := kafkaclient.Schema(opts)
+	if err != nil {
+		return err
+	}
+	l.schema = schema
+
+	clientOpts, err := kafkaclient.Options(opts)
+	if err != nil {
+		return err
+	}
+
+	var codec kgo.CompressionCodec
+	switch strings.ToLower(cfg.Sink.Compression) {
+	case "", "none":
+		codec = kgo.NoCompression()
+	case "gzip":
+		codec = kgo.GzipCompression()
+	case "snappy":
+		codec = kgo.SnappyCompression()
+	case "lz4":
+		codec = kgo.Lz4Compression()
+	case "zstd":
+		codec = kgo.ZstdCompression()
+	default:
+		return fmt.Errorf("unsupported compression: %s", cfg.Sink.Compression)
+	}
+
+	clientOpts = append(clientOpts,
+		kgo.DefaultProduceTopic(opts.Topic),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.ProducerBatchCompression(codec),
+	)
+	if opts.Linger > 0 {
+		clientOpts = append(clientOpts, kgo.ProducerLinger(opts.Linger))
+	}
+	if opts.BatchBytes > 0 {
+		clientOpts = append(clientOpts, kgo.ProducerBatchMaxBytes(opts.BatchBytes))
+	}
+
+	client, err := kgo.NewClient(clientOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	l.client = client
+
+	return nil
+}
+
+func (l *KafkaLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		count    int
+	)
+	failed := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr
+	}
+	promise := func(r *kgo.Record, err error) {
+		if err == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to produce to %s: %w", r.Topic, err)
+			cancel()
+		}
+	}
+
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			if err := failed(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case record, ok := <-input:
+			if !ok {
+				done = true
+				break
+			}
+			msg, err := l.message(record)
+			if err != nil {
+				return err
+			}
+			l.client.Produce(ctx, msg, promise)
+			count++
+		}
+	}
+
+	if err := l.client.Flush(ctx); err != nil {
+		if ferr := failed(); ferr != nil {
+			return ferr
+		}
+		return fmt.Errorf("failed to flush kafka producer: %w", err)
+	}
+	if err := failed(); err != nil {
+		return err
+	}
+
+	log.Printf("Produced %d records to %s", count, l.config.Sink.Kafka.Topic)
+	return nil
+}
+
+func (l *KafkaLoader) message(record pipeline.DataRecord) (*kgo.Record, error) {
+	msg := &kgo.Record{}
+
+	if field := l.config.Sink.Kafka.Key; field != "" {
+		v, ok := record[field]
+		if !ok || v == nil {
+			return nil, fmt.Errorf("record has no value for key field %s", field)
+		}
+		msg.Key = []byte(toString(v))
+	}
+
+	if l.schema == nil {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode record: %w", err)
+		}
+		msg.Value = value
+		return msg, nil
+	}
+
+	converted, err := avroValue(l.schema, map[string]interface{}(record))
+	if err != nil {
+		return nil, fmt.Errorf("record does not match avro schema: %w", err)
+	}
+	value, err := avro.Marshal(l.schema, converted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	msg.Value = value
+	return msg, nil
+}
+
+func (l *KafkaLoader) Close() error {
+	if l.client != nil {
+		l.client.Close()
+		l.client = nil
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestKafkaProducesKeyedIdempotently(t *testing.T) {
+	cluster := kfake.MustCluster(kfake.NumBrokers(1), kfake.SeedTopics(4, "sessions"))
+	defer cluster.Close()
+
+	// Every produce request must come from an idempotent producer that
+	// waits for all in-sync replicas.
+	var (
+		mu       sync.Mutex
+		produces int
+		bad      []string
+	)
+	cluster.ControlKey(int16(kmsg.Produce), func(req kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		produce := req.(*kmsg.ProduceRequest)
+		mu.Lock()
+		defer mu.Unlock()
+		produces++
+		if produce.Acks != -1 {
+			bad = append(bad, fmt.Sprintf("acks=%d", produce.Acks))
+		}
+		for _, topic := range produce.Topics {
+			for _, p := range topic.Partitions {
+				var batch kmsg.RecordBatch
+				if err := batch.ReadFrom(p.Records); err != nil {
+					bad = append(bad, err.Error())
+				} else if batch.ProducerID < 0 {
+					bad = append(bad, fmt.Sprintf("partition %d: no producer id", p.Partition))
+				}
+			}
+		}
+		return nil, nil, false
+	})
+
+	cfg := &config.PipelineConfig{}
+	cfg.Sink.Kafka = config.KafkaConfig{
+		Brokers: cluster.ListenAddrs(),
+		Topic:   "sessions",
+		Key:     "device",
+	}
+	ctx := context.Background()
+
+	l := NewKafkaLoader()
+	if err := l.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	input := make(chan pipeline.DataRecord)
+	go func() {
+		defer close(input)
+		for i := 0; i < 40; i++ {
+			input <- pipeline.DataRecord{"device": fmt.Sprintf("d%d", i%5), "seq": i}
+		}
+	}()
+	if err := l.Load(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if produces == 0 || len(bad) > 0 {
+		t.Fatalf("produce requests=%d, problems: %v", produces, bad)
+	}
+	mu.Unlock()
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumeTopics("sessions"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	partitions := make(map[string]int32)
+	n := 0
+	pollCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for n < 40 {
+		fetches := consumer.PollFetches(pollCtx)
+		if err := fetches.Err0(); err != nil {
+			t.Fatalf("consumed %d of 40 records: %v", n, err)
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			n++
+			key := string(r.Key)
+			if p, ok := partitions[key]; ok && p != r.Partition {
+				t.Errorf("key %s produced to partitions %d and %d", key, p, r.Partition)
+			}
+			partitions[key] = r.Partition
+		})
+	}
+	if n != 40 || len(partitions) != 5 {
+		t.Fatalf("consumed %d records with %d keys, want 40 with 5", n, len(partitions))
+	}
+}
+
+func TestKafkaRequiresKeyValue(t *testing.T) {
+	l := &KafkaLoader{config: &config.PipelineConfig{}}
+	l.config.Sink.Kafka.Key = "device"
+	if _, err := l.message(pipeline.DataRecord{"device": nil}); err == nil {
+		t.Fatal("expected an error for a record with a null key")
+	}
+}
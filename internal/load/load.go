@@ -0,0 +1,39 @@
+package load
+
+import (
+	"fmt"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// FromConfig returns the loader for sink, or a FanOutLoader when the
+// pipeline lists several sinks.
+func FromConfig(cfg *config.PipelineConfig) (pipeline.Loader, error) {
+	if len(cfg.Sinks) > 0 {
+		return NewFanOutLoader(cfg.Sinks)
+	}
+	return New(cfg.Sink.Type)
+}
+
+// New returns the loader registered for the given sink type.
+func New(sinkType string) (pipeline.Loader, error) {
+	switch sinkType {
+	case "postgres":
+		return NewPostgresLoader(), nil
+	case "sqlite":
+		return NewSQLiteLoader(), nil
+	case "jsonl":
+		return NewJSONLLoader(), nil
+	case "parquet":
+		return NewParquetLoader(), nil
+	case "http":
+		return NewHTTPLoader(), nil
+	case "kafka":
+		return NewKafkaLoader(), nil
+	case "noop":
+		return &pipeline.NoopLoader{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sink type: %s", sinkType)
+	}
+}
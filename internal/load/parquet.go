@@ -0,0 +1,517 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/storage"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+const defaultRowGroupSize = 128 * 1024
+
+// ParquetLoader writes records into Parquet files under sink.path, split
+// into Hive-style partition directories by the sink's partition_by fields.
+// Files are renamed into place once complete and listed in a per-run
+// manifest under _manifests.
+type ParquetLoader struct {
+	config    *config.PipelineConfig
+	table     config.TableConfig
+	schema    *parquet.Schema
+	columns   []parquetColumn
+	options   []parquet.WriterOption
+	runID     string
+	startedAt time.Time
+	open      map[string]*parquetFile
+	written   []ManifestFile
+	finished  bool
+	sequence  int
+	count     int
+}
+
+type parquetColumn struct {
+	config config.ColumnConfig
+	index  int
+	kind   string
+	scale  int
+}
+
+type parquetFile struct {
+	partition string
+	path      string
+	out       storage.AtomicWriter
+	writer    *parquet.Writer
+	records   int
+}
+
+// ManifestFile describes one file written during a run.
+type ManifestFile struct {
+	Path      string `json:"path"`
+	Partition string `json:"partition,omitempty"`
+	Records   int    `json:"records"`
+}
+
+type manifest struct {
+	Pipeline   string         `json:"pipeline"`
+	RunID      string         `json:"run_id"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Records    int            `json:"records"`
+	Files      []ManifestFile `json:"files"`
+}
+
+func NewParquetLoader() *ParquetLoader {
+	return &ParquetLoader{}
+}
+
+func (l *ParquetLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.config = cfg
+
+	if cfg.Sink.Path == "" {
+		return fmt.Errorf("parquet sink requires a path")
+	}
+
+	table, err := sinkTable(cfg.Sink)
+	if err != nil {
+		return err
+	}
+	l.table = table
+
+	group := make(parquet.Group, len(table.Columns))
+	kinds := make(map[string]parquetColumn, len(table.Columns))
+	for _, col := range table.Columns {
+		node, pc, err := parquetNode(col)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		group[col.Name] = parquet.Optional(node)
+		kinds[col.Name] = pc
+	}
+	l.schema = parquet.NewSchema(table.Name, group)
+
+	// Group orders its fields by name, so leaf indexes follow that order
+	// rather than the order of the configured columns.
+	l.columns = l.columns[:0]
+	for i, p := range l.schema.Columns() {
+		pc := kinds[p[0]]
+		pc.index = i
+		l.columns = append(l.columns, pc)
+	}
+
+	codec, err := parquetCodec(cfg.Sink.Compression)
+	if err != nil {
+		return err
+	}
+	rowGroupSize := cfg.Sink.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = defaultRowGroupSize
+	}
+	l.options = []parquet.WriterOption{
+		l.schema,
+		parquet.Compression(codec),
+		parquet.MaxRowsPerRowGroup(rowGroupSize),
+		parquet.CreatedBy("etl-framework", "", ""),
+	}
+
+	// Files and the manifest are named after the run, so that they can be
+	// found from its metadata; outside the orchestrator, after the time.
+	l.startedAt = time.Now().UTC()
+	l.runID = l.startedAt.Format("20060102T150405Z")
+	if run := pipeline.RunFromContext(ctx); run != nil {
+		l.runID = run.ID
+	}
+	l.open = make(map[string]*parquetFile)
+	l.written = nil
+	l.finished = false
+	l.sequence = 0
+	l.count = 0
+
+	return nil
+}
+
+func parquetCodec(name string) (compress.Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "snappy":
+		return &parquet.Snappy, nil
+	case "none", "uncompressed":
+		return &parquet.Uncompressed, nil
+	case "gzip":
+		return &parquet.Gzip, nil
+	case "zstd":
+		return &parquet.Zstd, nil
+	case "lz4":
+		return &parquet.Lz4Raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression: %s", name)
+	}
+}
+
+// parquetNode maps a configured column type, using the same SQL-style names
+// as the database sinks, onto a Parquet leaf.
+func parquetNode(col config.ColumnConfig) (parquet.Node, parquetColumn, error) {
+	base, args := splitColumnType(col.Type)
+	pc := parquetColumn{config: col, kind: base}
+
+	switch base {
+	case "", "string", "text", "varchar", "nvarchar", "char", "nchar", "uuid":
+		pc.kind = "string"
+		return parquet.String(), pc, nil
+	case "smallint", "integer", "int", "int32":
+		pc.kind = "int32"
+		return parquet.Int(32), pc, nil
+	case "bigint", "int64", "long":
+		pc.kind = "int64"
+		return parquet.Int(64), pc, nil
+	case "boolean", "bool":
+		pc.kind = "bool"
+		return parquet.Leaf(parquet.BooleanType), pc, nil
+	case "real", "float":
+		pc.kind = "float"
+		return parquet.Leaf(parquet.FloatType), pc, nil
+	case "double", "double precision", "float64":
+		pc.kind = "double"
+		return parquet.Leaf(parquet.DoubleType), pc, nil
+	case "decimal", "numeric":
+		precision, scale := 18, 0
+		if len(args) > 0 {
+			precision = args[0]
+		}
+		if len(args) > 1 {
+			scale = args[1]
+		}
+		if precision > 18 {
+			return nil, pc, fmt.Errorf("decimal precision %d exceeds 18", precision)
+		}
+		pc.kind = "decimal"
+		pc.scale = scale
+		return parquet.Decimal(scale, precision, parquet.Int64Type), pc, nil
+	case "timestamp", "datetime", "timestamptz":
+		pc.kind = "timestamp"
+		return parquet.Timestamp(parquet.Microsecond), pc, nil
+	case "date":
+		pc.kind = "date"
+		return parquet.Date(), pc, nil
+	default:
+		return nil, pc, fmt.Errorf("unsupported parquet column type: %s", col.Type)
+	}
+}
+
+// splitColumnType splits "DECIMAL(10, 2)" into "decimal" and [10 2].
+func splitColumnType(t string) (string, []int) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	open := strings.IndexByte(t, '(')
+	if open < 0 || !strings.HasSuffix(t, ")") {
+		return t, nil
+	}
+
+	var args []int
+	for _, a := range strings.Split(t[open+1:len(t)-1], ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(a)); err == nil {
+			args = append(args, n)
+		}
+	}
+	return strings.TrimSpace(t[:open]), args
+}
+
+func (l *ParquetLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	for {
+		select {
+		case <-ctx.Done():
+			l.abort()
+			return ctx.Err()
+		case record, ok := <-input:
+			if !ok {
+				if err := l.finish(ctx); err != nil {
+					l.abort()
+					return err
+				}
+				log.Printf("Wrote %d records to %d parquet files in %s", l.count, len(l.written), l.config.Sink.Path)
+				return nil
+			}
+
+			if err := l.write(ctx, record); err != nil {
+				l.abort()
+				return err
+			}
+		}
+	}
+}
+
+func (l *ParquetLoader) write(ctx context.Context, record pipeline.DataRecord) error {
+	partition, err := l.partition(record)
+	if err != nil {
+		return err
+	}
+
+	row := make(parquet.Row, len(l.columns))
+	for _, col := range l.columns {
+		v, err := col.value(record[col.config.Field()])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.config.Name, err)
+		}
+		if v.IsNull() {
+			row[col.index] = v.Level(0, 0, col.index)
+		} else {
+			row[col.index] = v.Level(0, 1, col.index)
+		}
+	}
+
+	f := l.open[partition]
+	if f != nil && l.config.Sink.Rotate.MaxRecords > 0 && f.records >= l.config.Sink.Rotate.MaxRecords {
+		if err := l.closeFile(f); err != nil {
+			return err
+		}
+		f = nil
+	}
+	if f == nil {
+		if f, err = l.create(ctx, partition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.writer.WriteRows([]parquet.Row{row}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	f.records++
+	l.count++
+	return nil
+}
+
+// partition returns the relative directory for a record, e.g.
+// "dt=2026-10-17/shard=1". Times are rendered as dates.
+func (l *ParquetLoader) partition(record pipeline.DataRecord) (string, error) {
+	parts := make([]string, len(l.config.Sink.PartitionBy))
+	for i, field := range l.config.Sink.PartitionBy {
+		v, ok := record[field]
+		if !ok || v == nil {
+			parts[i] = field + "=__HIVE_DEFAULT_PARTITION__"
+			continue
+		}
+
+		var s string
+		if t, ok := v.(time.Time); ok {
+			s = t.Format("2006-01-02")
+		} else {
+			s = toString(v)
+		}
+		if s == "" {
+			return "", fmt.Errorf("partition field %s is empty", field)
+		}
+		parts[i] = field + "=" + url.PathEscape(s)
+	}
+	return path.Join(parts...), nil
+}
+
+func (l *ParquetLoader) create(ctx context.Context, partition string) (*parquetFile, error) {
+	l.sequence++
+	name := fmt.Sprintf("%s-%s-%05d.parquet", l.config.Pipeline.Name, l.runID, l.sequence)
+	rel := path.Join(partition, name)
+	full := storage.Join(l.config.Sink.Path, rel)
+
+	out, err := storage.Create(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &parquetFile{
+		partition: partition,
+		path:      rel,
+		out:       out,
+		writer:    parquet.NewWriter(out, l.options...),
+	}
+	l.open[partition] = f
+	return f, nil
+}
+
+func (l *ParquetLoader) closeFile(f *parquetFile) error {
+	delete(l.open, f.partition)
+
+	if err := f.writer.Close(); err != nil {
+		f.out.Abort()
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if err := f.out.Close(); err != nil {
+		return err
+	}
+
+	l.written = append(l.written, ManifestFile{Path: f.path, Partition: f.partition, Records: f.records})
+	return nil
+}
+
+// finish closes the open files in partition order, so that the manifest
+// lists them in the same order for the same records.
+func (l *ParquetLoader) finish(ctx context.Context) error {
+	partitions := make([]string, 0, len(l.open))
+	for partition := range l.open {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+	for _, partition := range partitions {
+		if err := l.closeFile(l.open[partition]); err != nil {
+			return err
+		}
+	}
+	if err := l.writeManifest(ctx); err != nil {
+		return err
+	}
+	l.finished = true
+	return nil
+}
+
+func (l *ParquetLoader) writeManifest(ctx context.Context) error {
+	m := manifest{
+		Pipeline:   l.config.Pipeline.Name,
+		RunID:      l.runID,
+		StartedAt:  l.startedAt,
+		FinishedAt: time.Now().UTC(),
+		Records:    l.count,
+		Files:      l.written,
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.json", l.config.Pipeline.Name, l.runID)
+	out, err := storage.Create(ctx, storage.Join(l.config.Sink.Path, "_manifests", name))
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return out.Close()
+}
+
+// abort discards the open files, and the files already rotated out, unless
+// the manifest listing them has been written. A retried run reuses the same
+// names, and would otherwise leave files behind that no manifest lists.
+func (l *ParquetLoader) abort() {
+	for partition, f := range l.open {
+		f.out.Abort()
+		delete(l.open, partition)
+	}
+	if l.finished {
+		return
+	}
+	for _, f := range l.written {
+		full := storage.Join(l.config.Sink.Path, f.Path)
+		// the run's context is usually cancelled by now
+		if err := storage.Remove(context.Background(), full); err != nil {
+			log.Printf("Error removing %s: %v", full, err)
+		}
+	}
+	l.written = nil
+}
+
+// Files returns the files completed so far, relative to sink.path.
+func (l *ParquetLoader) Files() []ManifestFile {
+	return l.written
+}
+
+func (l *ParquetLoader) Close() error {
+	l.abort()
+	return nil
+}
+
+func (c parquetColumn) value(v interface{}) (parquet.Value, error) {
+	if v == nil {
+		return parquet.NullValue(), nil
+	}
+
+	switch c.kind {
+	case "string":
+		return parquet.ByteArrayValue([]byte(toString(v))), nil
+	case "int32":
+		n, err := toInt64(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		if n < -1<<31 || n > 1<<31-1 {
+			return parquet.Value{}, fmt.Errorf("value %d overflows int32", n)
+		}
+		return parquet.Int32Value(int32(n)), nil
+	case "int64":
+		n, err := toInt64(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.Int64Value(n), nil
+	case "bool":
+		b, err := toBool(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.BooleanValue(b), nil
+	case "float":
+		f, err := toFloat64(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.FloatValue(float32(f)), nil
+	case "double":
+		f, err := toFloat64(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.DoubleValue(f), nil
+	case "decimal":
+		n, err := scaledDecimal(v, c.scale)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.Int64Value(n), nil
+	case "timestamp":
+		t, err := toTime(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.Int64Value(t.UnixMicro()), nil
+	case "date":
+		t, err := toTime(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		days := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+		return parquet.Int32Value(int32(days)), nil
+	default:
+		return parquet.Value{}, fmt.Errorf("unsupported kind %s", c.kind)
+	}
+}
+
+// scaledDecimal returns v multiplied by 10^scale as an integer, rounding
+// half away from zero.
+func scaledDecimal(v interface{}, scale int) (int64, error) {
+	r, ok := new(big.Rat).SetString(toString(v))
+	if !ok {
+		return 0, fmt.Errorf("cannot convert %v to a decimal", v)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+
+	num, den := r.Num(), r.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("decimal %v overflows int64", v)
+	}
+	return q.Int64(), nil
+}
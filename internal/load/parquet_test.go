@@ -0,0 +1,137 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestParquetManifest(t *testing.T) {
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.Name = "logins"
+	cfg.Sink.Path = t.TempDir()
+	cfg.Sink.PartitionBy = []string{"shard"}
+	cfg.Sink.Tables = []config.TableConfig{{
+		Name:    "logins",
+		Columns: []config.ColumnConfig{{Name: "id", Type: "bigint"}, {Name: "shard", Type: "text"}},
+	}}
+
+	run := pipeline.NewRun(cfg.Pipeline.Name)
+	ctx := pipeline.WithRun(context.Background(), run)
+
+	l := NewParquetLoader()
+	if err := l.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	input := make(chan pipeline.DataRecord)
+	go func() {
+		defer close(input)
+		for i := 0; i < 20; i++ {
+			input <- pipeline.DataRecord{"id": int64(i), "shard": fmt.Sprintf("s%d", i%5)}
+		}
+	}()
+	if err := l.Load(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.Sink.Path, "_manifests", "logins-"+run.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.RunID != run.ID || m.Records != 20 || len(m.Files) != 5 {
+		t.Fatalf("manifest of run %s has run_id %s, %d records in %d files", run.ID, m.RunID, m.Records, len(m.Files))
+	}
+	for i, f := range m.Files {
+		if _, err := os.Stat(filepath.Join(cfg.Sink.Path, f.Path)); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("shard=s%d", i); f.Partition != want {
+			t.Fatalf("manifest file %d is in partition %s, want %s", i, f.Partition, want)
+		}
+	}
+}
+
+func TestParquetAbortRemovesRotatedFiles(t *testing.T) {
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.Name = "logins"
+	cfg.Sink.Path = t.TempDir()
+	cfg.Sink.Rotate.MaxRecords = 2
+	cfg.Sink.Tables = []config.TableConfig{{
+		Name:    "logins",
+		Columns: []config.ColumnConfig{{Name: "id", Type: "bigint"}},
+	}}
+	run := pipeline.NewRun(cfg.Pipeline.Name)
+	ctx := pipeline.WithRun(context.Background(), run)
+
+	files := func() []string {
+		var names []string
+		entries, err := os.ReadDir(cfg.Sink.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+		return names
+	}
+	load := func(ctx context.Context, records int, fail bool) error {
+		l := NewParquetLoader()
+		if err := l.Init(ctx, cfg); err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		input := make(chan pipeline.DataRecord)
+		go func() {
+			for i := 0; i < records; i++ {
+				input <- pipeline.DataRecord{"id": int64(i)}
+			}
+			if fail {
+				cancel()
+				return
+			}
+			close(input)
+		}()
+		return l.Load(ctx, input)
+	}
+
+	// the first attempt rotates out three files, then fails
+	if err := load(ctx, 7, true); err == nil {
+		t.Fatal("expected the cancelled load to fail")
+	}
+	if left := files(); len(left) != 0 {
+		t.Fatalf("failed attempt left %v behind", left)
+	}
+
+	// the retry writes fewer files under the same names
+	if err := load(ctx, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.Sink.Path, "_manifests", "logins-"+run.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if left := files(); len(left) != len(m.Files) || len(m.Files) != 2 {
+		t.Fatalf("sink holds %v, manifest lists %d files", left, len(m.Files))
+	}
+}
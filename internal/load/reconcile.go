@@ -0,0 +1,402 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aniketwaliyan/etl-framework/internal/alert"
+	"github.com/aniketwaliyan/etl-framework/internal/extract"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// reconcileParams bounds the placeholders of one sink query.
+const reconcileParams = 900
+
+// reconciler checks, once a load has finished, that the rows of the source
+// landed in the sink. It collects the keys of the records the loader wrote,
+// by shard and source table, and compares them with the rows the extractor
+// read, as counted during the run, and with the sink rows that have those
+// keys.
+type reconciler struct {
+	loader    *SQLLoader
+	source    config.SourceConfig
+	checksums []string
+	strict    bool
+	tables    []*reconcileTable
+	sources   []string
+	groups    map[reconcileGroupKey]*reconcileGroup
+	alerter   *alert.Alerter
+}
+
+// reconcileTable holds the sink columns of the key and checksum fields of
+// one sink table.
+type reconcileTable struct {
+	table    config.TableConfig
+	key      []int
+	checksum []int
+}
+
+type reconcileGroupKey struct {
+	shard, table string
+}
+
+type reconcileGroup struct {
+	// rows by sink table; each maps the text of a key to the last row
+	// written with it
+	rows []map[string]loadedRow
+}
+
+// loadedRow is the key of a row the loader wrote, with the checksum of its
+// checksum columns.
+type loadedRow struct {
+	key []interface{}
+	sum pipeline.Checksum
+}
+
+// reconcileReport is recorded in the run metadata under reconciliation.
+type reconcileReport struct {
+	Passed bool               `json:"passed"`
+	Error  string             `json:"error,omitempty"`
+	Tables []*reconcileResult `json:"tables"`
+}
+
+// reconcileResult compares, for one shard and table, the rows read from the
+// source with the rows loaded, which differ by those the transformations
+// dropped (complete), and the rows loaded with those in the sink (landed).
+type reconcileResult struct {
+	Shard          string `json:"shard"`
+	Table          string `json:"table"`
+	Extracted      int64  `json:"extracted"`
+	Loaded         int64  `json:"loaded"`
+	SinkRows       int64  `json:"sink_rows"`
+	SourceChecksum string `json:"source_checksum,omitempty"`
+	LoadedChecksum string `json:"loaded_checksum,omitempty"`
+	SinkChecksum   string `json:"sink_checksum,omitempty"`
+	Complete       bool   `json:"complete"`
+	Landed         bool   `json:"landed"`
+	Passed         bool   `json:"passed"`
+}
+
+func newReconciler(cfg *config.PipelineConfig, l *SQLLoader) (*reconciler, error) {
+	if _, err := extract.Shards(cfg.Source); err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+	tables, err := sourceTables(cfg.Source)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+	alerter, err := alert.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reconciler{
+		loader:    l,
+		source:    cfg.Source,
+		checksums: cfg.Reconcile.Checksums,
+		strict:    cfg.Reconcile.Strict,
+		tables:    make([]*reconcileTable, len(l.router.tables)),
+		sources:   tables,
+		groups:    make(map[reconcileGroupKey]*reconcileGroup),
+		alerter:   alerter,
+	}
+
+	reconciled := 0
+	for i, table := range l.router.tables {
+		rt := &reconcileTable{table: table}
+		var missing string
+		rt.key, missing = columnIndexes(table, cfg.Reconcile.Key)
+		if missing == "" {
+			rt.checksum, missing = columnIndexes(table, cfg.Reconcile.Checksums)
+		}
+		if missing != "" {
+			log.Printf("Reconciliation: leaving out sink table %s, which has no column for %s", table.Name, missing)
+			continue
+		}
+		r.tables[i] = rt
+		reconciled++
+	}
+	if reconciled == 0 {
+		return nil, fmt.Errorf("reconcile: no sink table has columns for the key %s", strings.Join(cfg.Reconcile.Key, ", "))
+	}
+	return r, nil
+}
+
+// sourceTables returns the names of the tables of a SQL source.
+func sourceTables(src config.SourceConfig) ([]string, error) {
+	if len(src.Tables) == 0 {
+		if src.Table == "" {
+			return nil, fmt.Errorf("source requires either table or tables")
+		}
+		return []string{src.Table}, nil
+	}
+	names := make([]string, len(src.Tables))
+	for i, t := range src.Tables {
+		names[i] = t.Name
+	}
+	return names, nil
+}
+
+// columnIndexes returns the index of the column of table that each field is
+// written to, or the first field that has none.
+func columnIndexes(table config.TableConfig, fields []string) ([]int, string) {
+	indexes := make([]int, len(fields))
+	for i, field := range fields {
+		indexes[i] = -1
+		for j, col := range table.Columns {
+			if col.Field() == field {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return nil, field
+		}
+	}
+	return indexes, ""
+}
+
+// add notes a record the loader wrote to the sink table at index target,
+// with args the values of its columns. Records that were not read from a
+// source table, such as aggregates, are not reconciled. Every key is kept
+// until the load ends, since the sink is only counted then: some 280 bytes
+// a row for a key of three short columns.
+func (r *reconciler) add(record pipeline.DataRecord, target int, args []interface{}) {
+	table, _ := record[pipeline.FieldSourceTable].(string)
+	if !r.isSource(table) {
+		return
+	}
+	rt := r.tables[target]
+	if rt == nil {
+		return
+	}
+	shard, _ := record[pipeline.FieldSourceShard].(string)
+
+	k := reconcileGroupKey{shard: shard, table: table}
+	g, ok := r.groups[k]
+	if !ok {
+		g = &reconcileGroup{rows: make([]map[string]loadedRow, len(r.tables))}
+		r.groups[k] = g
+	}
+	if g.rows[target] == nil {
+		g.rows[target] = make(map[string]loadedRow)
+	}
+
+	row := loadedRow{key: make([]interface{}, len(rt.key))}
+	text := make([]string, len(rt.key))
+	for i, col := range rt.key {
+		row.key[i] = args[col]
+		text[i] = toString(args[col])
+	}
+	values := make([]interface{}, len(rt.checksum))
+	for i, col := range rt.checksum {
+		values[i] = args[col]
+	}
+	row.sum.Add(values)
+	g.rows[target][strings.Join(text, "\x00")] = row
+}
+
+func (r *reconciler) isSource(table string) bool {
+	for _, t := range r.sources {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcile compares the source with the sink and records the result in the
+// run metadata. Tables that did not pass, and failures to reconcile, are
+// alerted; they do not fail the run, whose records have already been
+// loaded.
+func (r *reconciler) reconcile(ctx context.Context) {
+	report := &reconcileReport{Passed: true}
+	defer pipeline.RunFromContext(ctx).Set("reconciliation", report)
+
+	if err := r.compare(ctx, report); err != nil {
+		report.Passed = false
+		report.Error = err.Error()
+		log.Printf("Reconciliation failed: %v", err)
+		if aerr := r.alerter.Send(ctx, "reconciliation", fmt.Sprintf("reconciliation failed: %v", err), nil); aerr != nil {
+			log.Printf("Error sending alert: %v", aerr)
+		}
+		return
+	}
+
+	var failed []*reconcileResult
+	var names []string
+	for _, result := range report.Tables {
+		if !result.Passed {
+			failed = append(failed, result)
+			names = append(names, result.Shard+"/"+result.Table)
+		}
+	}
+	log.Printf("Reconciliation: %d of %d tables passed", len(report.Tables)-len(failed), len(report.Tables))
+	if len(failed) == 0 {
+		return
+	}
+
+	report.Passed = false
+	for _, result := range failed {
+		log.Printf("Reconciliation of %s/%s: %d rows extracted, %d loaded, %d in the sink%s",
+			result.Shard, result.Table, result.Extracted, result.Loaded, result.SinkRows, checksumDetail(result))
+	}
+	summary := fmt.Sprintf("%d of %d tables did not pass reconciliation: %s", len(failed), len(report.Tables), strings.Join(names, ", "))
+	if err := r.alerter.Send(ctx, "reconciliation", summary, failed); err != nil {
+		log.Printf("Error sending alert: %v", err)
+	}
+}
+
+func checksumDetail(result *reconcileResult) string {
+	if result.SourceChecksum == result.LoadedChecksum && result.LoadedChecksum == result.SinkChecksum {
+		return ""
+	}
+	return fmt.Sprintf(", checksums %s extracted, %s loaded, %s in the sink", result.SourceChecksum, result.LoadedChecksum, result.SinkChecksum)
+}
+
+// compare reconciles every table of every shard. A table passes when the
+// rows loaded are in the sink, and with reconcile.strict also when no rows
+// were dropped between the source and the sink.
+func (r *reconciler) compare(ctx context.Context, report *reconcileReport) error {
+	counts := pipeline.SourceCountsFromContext(ctx)
+	if counts == nil {
+		return fmt.Errorf("the source rows of the run were not counted")
+	}
+	shards, err := extract.Shards(r.source)
+	if err != nil {
+		return err
+	}
+
+	for _, shard := range shards {
+		for _, table := range r.sources {
+			extracted := counts.Get(shard, table)
+			result := &reconcileResult{Shard: shard, Table: table, Extracted: extracted.Rows}
+
+			var loadedSum, sinkSum pipeline.Checksum
+			if g, ok := r.groups[reconcileGroupKey{shard: shard, table: table}]; ok {
+				for i, rows := range g.rows {
+					if len(rows) == 0 {
+						continue
+					}
+					for _, row := range rows {
+						loadedSum += row.sum
+					}
+					n, s, err := r.countSink(ctx, r.tables[i], rows)
+					if err != nil {
+						return fmt.Errorf("failed to count rows of %s in %s: %w", table, r.tables[i].table.Name, err)
+					}
+					result.Loaded += int64(len(rows))
+					result.SinkRows += n
+					sinkSum += s
+				}
+			}
+
+			result.Complete = result.Extracted == result.Loaded
+			result.Landed = result.Loaded == result.SinkRows
+			if len(r.checksums) > 0 {
+				result.SourceChecksum = extracted.Checksum.String()
+				result.LoadedChecksum = loadedSum.String()
+				result.SinkChecksum = sinkSum.String()
+				result.Complete = result.Complete && extracted.Checksum == loadedSum
+				result.Landed = result.Landed && loadedSum == sinkSum
+			}
+			result.Passed = result.Landed && (result.Complete || !r.strict)
+			report.Tables = append(report.Tables, result)
+		}
+	}
+	return nil
+}
+
+// countSink counts the rows of a sink table that have one of keys, and
+// takes the checksum of their checksum columns. The keys are looked up a
+// chunk at a time.
+func (r *reconciler) countSink(ctx context.Context, rt *reconcileTable, rows map[string]loadedRow) (int64, pipeline.Checksum, error) {
+	keyCols := columnNames(rt.table, rt.key)
+	selected := "COUNT(*)"
+	if len(rt.checksum) > 0 {
+		selected = strings.Join(columnNames(rt.table, rt.checksum), ", ")
+	}
+	chunk := reconcileParams / len(keyCols)
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	var (
+		total int64
+		sum   pipeline.Checksum
+	)
+	args := make([]interface{}, 0, chunk*len(keyCols))
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", selected, rt.table.Name, keyCondition(keyCols, len(args)/len(keyCols)))
+		n, s, err := r.countChunk(ctx, query, args, len(rt.checksum) > 0)
+		if err != nil {
+			return err
+		}
+		total, sum, args = total+n, sum+s, args[:0]
+		return nil
+	}
+
+	for _, row := range rows {
+		args = append(args, row.key...)
+		if len(args) >= chunk*len(keyCols) {
+			if err := flush(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, 0, err
+	}
+	return total, sum, nil
+}
+
+func (r *reconciler) countChunk(ctx context.Context, query string, args []interface{}, checksum bool) (int64, pipeline.Checksum, error) {
+	if !checksum {
+		var n int64
+		err := r.loader.db.QueryRowContext(ctx, query, args...).Scan(&n)
+		return n, 0, err
+	}
+	rows, err := r.loader.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	return extract.ChecksumRows(rows, r.loader.driver)
+}
+
+func columnNames(table config.TableConfig, indexes []int) []string {
+	names := make([]string, len(indexes))
+	for i, col := range indexes {
+		names[i] = table.Columns[col].Name
+	}
+	return names
+}
+
+// keyCondition matches n keys of the given columns, numbered from $1.
+func keyCondition(cols []string, n int) string {
+	if len(cols) == 1 {
+		placeholders := make([]string, n)
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		return fmt.Sprintf("%s IN (%s)", cols[0], strings.Join(placeholders, ", "))
+	}
+
+	terms := make([]string, n)
+	p := 1
+	for i := range terms {
+		conds := make([]string, len(cols))
+		for j, col := range cols {
+			conds[j] = fmt.Sprintf("%s = $%d", col, p)
+			p++
+		}
+		terms[i] = "(" + strings.Join(conds, " AND ") + ")"
+	}
+	return strings.Join(terms, " OR ")
+}
@@ -0,0 +1,146 @@
+package load
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// reconcileRun loads records read from the dealers table of a SQLite source
+// into a SQLite sink set up by schema, and returns the reconciliation of
+// the run. extracted are the rows the source read.
+func reconcileRun(t *testing.T, schema string, strict bool, extracted, loaded []pipeline.DataRecord) reconcileReport {
+	t.Helper()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.db")
+	sink := filepath.Join(dir, "sink.db")
+
+	db, err := sql.Open("sqlite", sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE dealers (id INTEGER PRIMARY KEY, name TEXT);` + schema); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	cfg := &config.PipelineConfig{}
+	cfg.Source = config.SourceConfig{Type: "sqlite", Path: source, Table: "dealers"}
+	cfg.Sink = config.SinkConfig{Type: "sqlite", Path: sink, Tables: []config.TableConfig{{
+		Name:         "dealers",
+		Columns:      []config.ColumnConfig{{Name: "id", Source: "dealer_id"}, {Name: "name"}},
+		ConflictKeys: []string{"id"},
+	}}}
+	cfg.Reconcile = config.ReconcileConfig{Key: []string{"dealer_id"}, Checksums: []string{"name"}, Strict: strict}
+
+	run := pipeline.NewRun("test")
+	counts := pipeline.NewSourceCounts(cfg.Reconcile.Checksums)
+	ctx := pipeline.WithSourceCounts(pipeline.WithRun(context.Background(), run), counts)
+	for _, r := range extracted {
+		counts.Add(source, "dealers", r)
+	}
+
+	l := NewSQLiteLoader()
+	if err := l.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	input := make(chan pipeline.DataRecord, len(loaded))
+	for _, r := range loaded {
+		r[pipeline.FieldSourceTable] = "dealers"
+		r[pipeline.FieldSourceShard] = source
+		input <- r
+	}
+	close(input)
+	if err := l.Load(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok := run.Get("reconciliation")
+	if !ok {
+		t.Fatal("no reconciliation in the run metadata")
+	}
+	// read it back as the run metadata has it
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reconcileReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Tables) != 1 {
+		t.Fatalf("reconciled %d tables, want 1: %s", len(report.Tables), data)
+	}
+	return report
+}
+
+func dealers() []pipeline.DataRecord {
+	return []pipeline.DataRecord{
+		{"dealer_id": int64(1), "name": "Pune Motors"},
+		{"dealer_id": int64(2), "name": "Delhi Autos"},
+		{"dealer_id": int64(3), "name": nil},
+	}
+}
+
+func TestReconcileMatches(t *testing.T) {
+	report := reconcileRun(t, "", true, dealers(), dealers())
+	r := report.Tables[0]
+	if !report.Passed || !r.Passed || !r.Complete || !r.Landed {
+		t.Errorf("report %+v, table %+v", report, r)
+	}
+	if r.Extracted != 3 || r.Loaded != 3 || r.SinkRows != 3 {
+		t.Errorf("%d extracted, %d loaded, %d in the sink", r.Extracted, r.Loaded, r.SinkRows)
+	}
+	if r.SourceChecksum != r.LoadedChecksum || r.LoadedChecksum != r.SinkChecksum {
+		t.Errorf("checksums %s, %s, %s", r.SourceChecksum, r.LoadedChecksum, r.SinkChecksum)
+	}
+}
+
+func TestReconcileMissingSinkRow(t *testing.T) {
+	// the sink loses a row after it was written
+	lose := `CREATE TRIGGER lose AFTER INSERT ON dealers WHEN NEW.id = 2 BEGIN DELETE FROM dealers WHERE id = 2; END;`
+	report := reconcileRun(t, lose, false, dealers(), dealers())
+	r := report.Tables[0]
+	if report.Passed || r.Passed || r.Landed || !r.Complete {
+		t.Errorf("report %+v, table %+v", report, r)
+	}
+	if r.Loaded != 3 || r.SinkRows != 2 {
+		t.Errorf("%d loaded, %d in the sink", r.Loaded, r.SinkRows)
+	}
+
+	// a row dropped before the load only fails a strict reconciliation
+	for _, strict := range []bool{false, true} {
+		report := reconcileRun(t, "", strict, dealers(), dealers()[:2])
+		r := report.Tables[0]
+		if r.Complete || !r.Landed || r.Passed == strict || r.Extracted != 3 || r.Loaded != 2 {
+			t.Errorf("strict %v: table %+v", strict, r)
+		}
+	}
+}
+
+func TestReconcileChecksumMismatch(t *testing.T) {
+	// the sink changes a value on the way in
+	rename := `CREATE TRIGGER rename AFTER INSERT ON dealers WHEN NEW.id = 1 BEGIN UPDATE dealers SET name = 'Pune Motor' WHERE id = 1; END;`
+	report := reconcileRun(t, rename, false, dealers(), dealers())
+	r := report.Tables[0]
+	if report.Passed || r.Passed || r.Landed || !r.Complete {
+		t.Errorf("report %+v, table %+v", report, r)
+	}
+	if r.SinkRows != 3 || r.LoadedChecksum == r.SinkChecksum || r.SourceChecksum != r.LoadedChecksum {
+		t.Errorf("table %+v", r)
+	}
+
+	// a transformation that changes a checksum column makes the run incomplete
+	loaded := dealers()
+	loaded[1]["name"] = "DELHI AUTOS"
+	report = reconcileRun(t, "", false, dealers(), loaded)
+	if r := report.Tables[0]; r.Complete || !r.Landed || !r.Passed {
+		t.Errorf("table %+v", r)
+	}
+}
@@ -0,0 +1,222 @@
+package load
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/aniketwaliyan/etl-framework/internal/expr"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// Router picks the sink table for each record from the routing rules. A
+// sink with a single table and no rules sends every record to it.
+type Router struct {
+	tables     []config.TableConfig
+	routes     []route
+	fallback   int
+	unmatched  string
+	deadLetter *pipeline.DeadLetter
+	skipped    int
+}
+
+type route struct {
+	field   string
+	equals  *string
+	prefix  string
+	pattern *regexp.Regexp
+	expr    config.Expression
+	prog    *expr.Program
+	table   int
+}
+
+// NewRouter builds the router for the tables and routing rules of the sink.
+func NewRouter(cfg *config.PipelineConfig) (*Router, error) {
+	sink := cfg.Sink
+	if len(sink.Tables) == 0 {
+		return nil, fmt.Errorf("%s sink requires a table with columns", sink.Type)
+	}
+
+	r := &Router{tables: sink.Tables, fallback: -1}
+	index := make(map[string]int, len(sink.Tables))
+	for i, table := range sink.Tables {
+		if table.Name == "" {
+			return nil, fmt.Errorf("sink table %d has no name", i)
+		}
+		if len(table.Columns) == 0 {
+			return nil, fmt.Errorf("sink table %s has no columns", table.Name)
+		}
+		if _, dup := index[table.Name]; dup {
+			return nil, fmt.Errorf("duplicate sink table %s", table.Name)
+		}
+		index[table.Name] = i
+	}
+
+	rules := sink.Routing
+	lookup := func(name string) (int, error) {
+		i, ok := index[name]
+		if !ok {
+			return 0, fmt.Errorf("route to unknown table %s", name)
+		}
+		return i, nil
+	}
+
+	if len(rules.Routes) == 0 && rules.Default == "" {
+		if len(sink.Tables) > 1 {
+			return nil, fmt.Errorf("sink has %d tables but no routing", len(sink.Tables))
+		}
+		r.fallback = 0
+	}
+
+	for i, rc := range rules.Routes {
+		table, err := lookup(rc.Table)
+		if err != nil {
+			return nil, err
+		}
+		rt := route{field: rc.Field, table: table}
+		if rt.field == "" {
+			rt.field = rules.Field
+		}
+		if rt.field == "" {
+			rt.field = pipeline.FieldSourceTable
+		}
+
+		set := 0
+		if rc.Equals != "" {
+			equals := rc.Equals
+			rt.equals = &equals
+			set++
+		}
+		if rc.Prefix != "" {
+			rt.prefix = rc.Prefix
+			set++
+		}
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: invalid pattern: %w", i, err)
+			}
+			rt.pattern = re
+			set++
+		}
+		if rc.Expr.Source != "" {
+			prog, err := expr.Compile(rc.Expr.Source)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: line %d: expr %q: %w", i, rc.Expr.Line, rc.Expr.Source, err)
+			}
+			rt.expr, rt.prog = rc.Expr, prog
+			set++
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("route %d must set exactly one of equals, prefix, pattern or expr", i)
+		}
+		r.routes = append(r.routes, rt)
+	}
+
+	if rules.Default != "" {
+		table, err := lookup(rules.Default)
+		if err != nil {
+			return nil, err
+		}
+		r.fallback = table
+	}
+
+	switch rules.Unmatched {
+	case "", "fail", "skip":
+	case "dead_letter":
+		if cfg.Pipeline.DeadLetter.Path == "" {
+			return nil, fmt.Errorf("unmatched: dead_letter requires pipeline.dead_letter.path")
+		}
+		r.deadLetter = pipeline.NewDeadLetter(cfg.Pipeline.DeadLetter.Path, cfg.Pipeline.Name)
+	default:
+		return nil, fmt.Errorf("unsupported unmatched policy: %s", rules.Unmatched)
+	}
+	r.unmatched = rules.Unmatched
+
+	return r, nil
+}
+
+// Table returns the name of the table for record, or "" when the record
+// matched no route and was skipped or dead-lettered.
+func (r *Router) Table(record pipeline.DataRecord) (string, error) {
+	i, err := r.route(record)
+	if err != nil || i < 0 {
+		return "", err
+	}
+	return r.tables[i].Name, nil
+}
+
+// route returns the index of the table for record, or -1 when the record
+// matched no route and was skipped or dead-lettered.
+func (r *Router) route(record pipeline.DataRecord) (int, error) {
+	for _, rt := range r.routes {
+		ok, err := rt.match(record)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return rt.table, nil
+		}
+	}
+	if r.fallback >= 0 {
+		return r.fallback, nil
+	}
+
+	err := fmt.Errorf("no route matches record from %v", record[pipeline.FieldSourceTable])
+	switch r.unmatched {
+	case "skip":
+		r.skipped++
+		return -1, nil
+	case "dead_letter":
+		r.skipped++
+		return -1, r.deadLetter.Write("route", record, err)
+	default:
+		return -1, err
+	}
+}
+
+// match reports whether record takes the route. An expression that is null
+// does not match, like a missing field.
+func (rt route) match(record pipeline.DataRecord) (bool, error) {
+	if rt.prog != nil {
+		ok, err := rt.prog.Match(record)
+		if err != nil {
+			return false, fmt.Errorf("line %d: route expr %q: %w", rt.expr.Line, rt.expr.Source, err)
+		}
+		return ok, nil
+	}
+
+	v, ok := record[rt.field]
+	if !ok || v == nil {
+		return false, nil
+	}
+	s := toString(v)
+	switch {
+	case rt.equals != nil:
+		return s == *rt.equals, nil
+	case rt.prefix != "":
+		return strings.HasPrefix(s, rt.prefix), nil
+	default:
+		return rt.pattern.MatchString(s), nil
+	}
+}
+
+func (r *Router) report() {
+	if r.skipped == 0 {
+		return
+	}
+	if r.deadLetter != nil {
+		log.Printf("%d unrouted records written to %s", r.skipped, r.deadLetter.Path())
+		return
+	}
+	log.Printf("Skipped %d unrouted records", r.skipped)
+}
+
+func (r *Router) Close() error {
+	if r.deadLetter != nil {
+		return r.deadLetter.Close()
+	}
+	return nil
+}
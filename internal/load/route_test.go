@@ -0,0 +1,153 @@
+package load
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func testRouter(t *testing.T, sink string) (*Router, error) {
+	t.Helper()
+	cfg := &config.PipelineConfig{}
+	if err := yaml.Unmarshal([]byte(sink), &cfg.Sink); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Pipeline.Name = "test"
+	cfg.Pipeline.DeadLetter.Path = filepath.Join(t.TempDir(), "dead.jsonl")
+	return NewRouter(cfg)
+}
+
+const routedTables = `
+tables:
+  - {name: history, columns: [{name: id}]}
+  - {name: log, columns: [{name: id}]}
+  - {name: admin, columns: [{name: id}]}
+  - {name: failures, columns: [{name: id}]}
+`
+
+func TestRouterMatchesInOrder(t *testing.T) {
+	r, err := testRouter(t, routedTables+`
+routing:
+  field: _source_table
+  routes:
+    - equals: dbo.tbl_UserConnectionHistory
+      table: history
+    - field: details
+      pattern: "^ADMIN:"
+      table: admin
+    - expr: "success_failure == 0 and _source_shard in [1, 2]"
+      table: failures
+    - prefix: dbo.tbl_UserConnectionLog
+      table: log
+  unmatched: fail
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		record pipeline.DataRecord
+		table  string
+	}{
+		{pipeline.DataRecord{"_source_table": "dbo.tbl_UserConnectionHistory", "details": "ADMIN: x"}, "history"},
+		{pipeline.DataRecord{"_source_table": "dbo.tbl_UserConnectionLog", "details": "ADMIN: x"}, "admin"},
+		{pipeline.DataRecord{"_source_table": "dbo.tbl_UserConnectionLog", "success_failure": int64(0), "_source_shard": int64(2)}, "failures"},
+		{pipeline.DataRecord{"_source_table": "dbo.tbl_UserConnectionLog", "success_failure": int64(0), "_source_shard": int64(3)}, "log"},
+		// null in the expression does not match
+		{pipeline.DataRecord{"_source_table": "dbo.tbl_UserConnectionLog_archive", "_source_shard": int64(1)}, "log"},
+	} {
+		table, err := r.Table(tc.record)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.record, err)
+		}
+		if table != tc.table {
+			t.Errorf("%v: routed to %q, want %q", tc.record, table, tc.table)
+		}
+	}
+
+	if _, err := r.Table(pipeline.DataRecord{"_source_table": "other"}); err == nil {
+		t.Error("unmatched record did not fail")
+	}
+}
+
+func TestRouterDefaultAndUnmatched(t *testing.T) {
+	r, err := testRouter(t, routedTables+`
+routing:
+  routes:
+    - {equals: dbo.tbl_UserConnectionHistory, table: history}
+  default: log
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table, err := r.Table(pipeline.DataRecord{"_source_table": "other"}); err != nil || table != "log" {
+		t.Errorf("routed to %q (%v), want the default", table, err)
+	}
+
+	for _, policy := range []string{"skip", "dead_letter"} {
+		r, err := testRouter(t, routedTables+`
+routing:
+  routes:
+    - {equals: dbo.tbl_UserConnectionHistory, table: history}
+  unmatched: `+policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := r.Table(pipeline.DataRecord{"_source_table": "other"})
+		if err != nil || table != "" {
+			t.Errorf("%s: routed to %q (%v)", policy, table, err)
+		}
+		if r.skipped != 1 {
+			t.Errorf("%s: skipped %d records, want 1", policy, r.skipped)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRouterRejectsInvalidRoutes(t *testing.T) {
+	for name, tc := range map[string]struct{ routing, err string }{
+		"no match":     {"routes: [{table: log}]", "exactly one of"},
+		"two matches":  {"routes: [{equals: a, prefix: a, table: log}]", "exactly one of"},
+		"bad pattern":  {"routes: [{pattern: '(', table: log}]", "invalid pattern"},
+		"bad expr":     {"routes: [{expr: 'a == ', table: log}]", "column 6"},
+		"unknown":      {"routes: [{equals: a, table: nope}]", "unknown table nope"},
+		"no routing":   {"unmatched: fail", "no routing"},
+		"bad policy":   {"default: log\n  unmatched: drop", "unsupported unmatched"},
+		"dead letters": {"routes: [{equals: a, table: log}]\n  unmatched: dead_letter", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := testRouter(t, routedTables+"routing:\n  "+tc.routing+"\n")
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got %v, want an error containing %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestRouterExprError(t *testing.T) {
+	r, err := testRouter(t, routedTables+`
+routing:
+  routes:
+    - {expr: "hour(details) == 1", table: log}
+  default: history
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Table(pipeline.DataRecord{"details": "abc"}); err == nil || !strings.Contains(err.Error(), "route expr") {
+		t.Fatalf("got %v, want the expression error", err)
+	}
+}
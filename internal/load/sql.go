@@ -0,0 +1,319 @@
+package load
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const commitEvery = 500
+
+// SQLLoader upserts records into the sink tables described by the pipeline
+// configuration, choosing the table for each record with the sink's routing
+// rules. The same statements are used for PostgreSQL and SQLite, which both
+// accept numbered placeholders and ON CONFLICT ... DO UPDATE.
+type SQLLoader struct {
+	driver  string
+	config  *config.PipelineConfig
+	db      *sql.DB
+	router  *Router
+	queries []string
+	counts  []int
+	errors  int
+
+	reconciler *reconciler
+}
+
+func NewPostgresLoader() *SQLLoader {
+	return &SQLLoader{driver: "postgres"}
+}
+
+func NewSQLiteLoader() *SQLLoader {
+	return &SQLLoader{driver: "sqlite"}
+}
+
+func (l *SQLLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.config = cfg
+
+	router, err := NewRouter(cfg)
+	if err != nil {
+		return err
+	}
+	l.router = router
+	l.queries = make([]string, len(router.tables))
+	for i, table := range router.tables {
+		l.queries[i] = upsertQuery(table)
+	}
+	l.counts = make([]int, len(router.tables))
+	l.errors = 0
+
+	dsn, err := l.dsn(cfg.Sink)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(l.driver, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s sink: %w", l.driver, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to ping %s sink: %w", l.driver, err)
+	}
+	l.db = db
+
+	l.reconciler = nil
+	if cfg.Reconcile.Enabled() {
+		if l.reconciler, err = newReconciler(cfg, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *SQLLoader) dsn(sink config.SinkConfig) (string, error) {
+	switch l.driver {
+	case "sqlite":
+		if sink.Path == "" {
+			return "", fmt.Errorf("sqlite sink requires a path")
+		}
+		return fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)", sink.Path), nil
+	case "postgres":
+		password, err := secrets.Resolve(sink.Password)
+		if err != nil {
+			return "", fmt.Errorf("postgres password: %w", err)
+		}
+		params := []string{
+			"host=" + pqValue(sink.Server),
+			"dbname=" + pqValue(sink.Database),
+			"sslmode=disable",
+		}
+		if sink.User != "" {
+			params = append(params, "user="+pqValue(sink.User))
+		}
+		if password != "" {
+			params = append(params, "password="+pqValue(password))
+		}
+		return strings.Join(params, " "), nil
+	default:
+		return "", fmt.Errorf("unsupported sql driver: %s", l.driver)
+	}
+}
+
+// pqValue quotes a value of a key=value connection string.
+func pqValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+func sinkTable(sink config.SinkConfig) (config.TableConfig, error) {
+	switch {
+	case len(sink.Tables) > 1:
+		return config.TableConfig{}, fmt.Errorf("%s sink supports a single table, got %d", sink.Type, len(sink.Tables))
+	case len(sink.Tables) == 1:
+		table := sink.Tables[0]
+		if table.Name == "" {
+			return table, fmt.Errorf("sink table has no name")
+		}
+		if len(table.Columns) == 0 {
+			return table, fmt.Errorf("sink table %s has no columns", table.Name)
+		}
+		return table, nil
+	default:
+		return config.TableConfig{}, fmt.Errorf("%s sink requires a table with columns", sink.Type)
+	}
+}
+
+func upsertQuery(table config.TableConfig) string {
+	names := make([]string, len(table.Columns))
+	placeholders := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		names[i] = col.Name
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table.Name, strings.Join(names, ", "), strings.Join(placeholders, ", "))
+
+	if len(table.ConflictKeys) == 0 {
+		return query
+	}
+
+	keys := make(map[string]bool, len(table.ConflictKeys))
+	for _, k := range table.ConflictKeys {
+		keys[k] = true
+	}
+	var updates []string
+	for _, col := range table.Columns {
+		if !keys[col.Name] {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", col.Name, col.Name))
+		}
+	}
+
+	query += fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(table.ConflictKeys, ", "))
+	if len(updates) == 0 {
+		return query + " DO NOTHING"
+	}
+	return query + " DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+func (l *SQLLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	w := &sqlWriter{loader: l, stmts: make([]*sql.Stmt, len(l.queries))}
+	defer w.rollback()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case record, ok := <-input:
+			if !ok {
+				return l.finish(ctx, w)
+			}
+			if err := w.write(ctx, record); err != nil {
+				return err
+			}
+			if w.pending >= commitEvery {
+				if err := w.commit(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// LoadBatches writes each batch in a transaction of its own.
+func (l *SQLLoader) LoadBatches(ctx context.Context, input <-chan pipeline.Batch) error {
+	w := &sqlWriter{loader: l, stmts: make([]*sql.Stmt, len(l.queries))}
+	defer w.rollback()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case batch, ok := <-input:
+			if !ok {
+				return l.finish(ctx, w)
+			}
+			for _, record := range batch {
+				if err := w.write(ctx, record); err != nil {
+					return err
+				}
+			}
+			if err := w.commit(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *SQLLoader) finish(ctx context.Context, w *sqlWriter) error {
+	if err := w.commit(); err != nil {
+		return err
+	}
+	for i, table := range l.router.tables {
+		log.Printf("Loaded %d records into %s", l.counts[i], table.Name)
+	}
+	l.router.report()
+	if l.reconciler != nil {
+		l.reconciler.reconcile(ctx)
+	}
+	return nil
+}
+
+// sqlWriter holds the open transaction of a load and the statements
+// prepared in it.
+type sqlWriter struct {
+	loader  *SQLLoader
+	tx      *sql.Tx
+	stmts   []*sql.Stmt
+	pending int
+}
+
+func (w *sqlWriter) write(ctx context.Context, record pipeline.DataRecord) error {
+	l := w.loader
+	target, err := l.router.route(record)
+	if err != nil {
+		l.errors++
+		return err
+	}
+	if target < 0 {
+		return nil
+	}
+	table := l.router.tables[target]
+
+	if w.tx == nil {
+		if w.tx, err = l.db.BeginTx(ctx, nil); err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+	}
+	if w.stmts[target] == nil {
+		if w.stmts[target], err = w.tx.PrepareContext(ctx, l.queries[target]); err != nil {
+			return fmt.Errorf("failed to prepare statement for %s: %w", table.Name, err)
+		}
+	}
+
+	args := make([]interface{}, len(table.Columns))
+	for i, col := range table.Columns {
+		args[i] = record[col.Field()]
+	}
+	if _, err := w.stmts[target].ExecContext(ctx, args...); err != nil {
+		l.errors++
+		return fmt.Errorf("failed to insert/update record into %s: %w", table.Name, err)
+	}
+
+	l.counts[target]++
+	w.pending++
+	if l.reconciler != nil {
+		l.reconciler.add(record, target, args)
+	}
+	return nil
+}
+
+func (w *sqlWriter) closeStmts() {
+	for i, stmt := range w.stmts {
+		if stmt != nil {
+			stmt.Close()
+			w.stmts[i] = nil
+		}
+	}
+}
+
+func (w *sqlWriter) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	w.closeStmts()
+	err := w.tx.Commit()
+	w.tx, w.pending = nil, 0
+	if err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+func (w *sqlWriter) rollback() {
+	if w.tx != nil {
+		w.closeStmts()
+		w.tx.Rollback()
+		w.tx = nil
+	}
+}
+
+func (l *SQLLoader) Close() error {
+	if l.router != nil {
+		if err := l.router.Close(); err != nil {
+			log.Printf("Error closing dead letter file: %v", err)
+		}
+	}
+	if l.db != nil {
+		return l.db.Close()
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package load
+
+import (
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	t.Setenv("WAREHOUSE_PASSWORD", `it's a \secret`)
+
+	l := &SQLLoader{driver: "postgres"}
+	dsn, err := l.dsn(config.SinkConfig{
+		Server:   "db.internal",
+		Database: "analytics",
+		User:     "etl",
+		Password: "env:WAREHOUSE_PASSWORD",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `host='db.internal' dbname='analytics' sslmode=disable user='etl' password='it\'s a \\secret'`
+	if dsn != want {
+		t.Fatalf("dsn = %s, want %s", dsn, want)
+	}
+
+	if _, err := l.dsn(config.SinkConfig{Password: "env:UNSET_WAREHOUSE_PASSWORD"}); err == nil {
+		t.Fatal("expected an error for an unset password variable")
+	}
+}
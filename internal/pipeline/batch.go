@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const defaultFlushInterval = time.Second
+
+// Batch is a group of records passed between stages with a single channel
+// send. It is used when pipeline.batch.size is above one.
+type Batch []DataRecord
+
+// BatchSender collects records into batches of the configured size. A batch
+// that is not full is sent once it has waited for the flush interval, so
+// that a slow source still delivers its records. It is safe for concurrent
+// use; call Stop before closing the channel it sends on.
+type BatchSender struct {
+	ctx      context.Context
+	out      chan<- Batch
+	size     int
+	interval time.Duration
+
+	mu         sync.Mutex
+	batch      Batch
+	timer      *time.Timer
+	generation int
+	stopped    bool
+}
+
+func NewBatchSender(ctx context.Context, out chan<- Batch, cfg config.BatchConfig) *BatchSender {
+	s := &BatchSender{ctx: ctx, out: out, size: cfg.Size, interval: cfg.FlushInterval}
+	if s.size < 1 {
+		s.size = 1
+	}
+	if s.interval == 0 {
+		s.interval = defaultFlushInterval
+	}
+	return s
+}
+
+// Send adds record to the current batch, and sends the batch once it is
+// full. It fails once the context is done.
+func (s *BatchSender) Send(record DataRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.batch == nil {
+		s.batch = make(Batch, 0, s.size)
+		if s.interval > 0 {
+			generation := s.generation
+			s.timer = time.AfterFunc(s.interval, func() { s.timeout(generation) })
+		}
+	}
+	s.batch = append(s.batch, record)
+	if len(s.batch) >= s.size {
+		return s.flush()
+	}
+	return nil
+}
+
+// Flush sends the current batch, if any.
+func (s *BatchSender) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Stop discards the current batch and stops the flush timer.
+func (s *BatchSender) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.batch = nil
+	s.stopped = true
+}
+
+// timeout flushes the batch the timer was started for, unless it has been
+// sent already.
+func (s *BatchSender) timeout(generation int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped && generation == s.generation {
+		s.flush()
+	}
+}
+
+func (s *BatchSender) flush() error {
+	if len(s.batch) == 0 || s.stopped {
+		return s.ctx.Err()
+	}
+	batch := s.batch
+	s.batch = nil
+	s.generation++
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	select {
+	case s.out <- batch:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// Batches groups the records of input into batches, for stages that work
+// on batches behind one that produces records.
+func Batches(ctx context.Context, input <-chan DataRecord, cfg config.BatchConfig) <-chan Batch {
+	output := make(chan Batch)
+	go func() {
+		defer close(output)
+		sender := NewBatchSender(ctx, output, cfg)
+		defer sender.Stop()
+
+		for {
+			select {
+			case record, ok := <-input:
+				if !ok {
+					sender.Flush()
+					return
+				}
+				if err := sender.Send(record); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Unbatch passes on the records of each batch of input one at a time, for
+// stages that work on records behind one that produces batches.
+func Unbatch(ctx context.Context, input <-chan Batch) <-chan DataRecord {
+	output := make(chan DataRecord)
+	go func() {
+		defer close(output)
+		for {
+			select {
+			case batch, ok := <-input:
+				if !ok {
+					return
+				}
+				for _, record := range batch {
+					select {
+					case output <- record:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestBatchesFlushesThePartialBatch(t *testing.T) {
+	ctx := context.Background()
+	var sizes []int
+	for batch := range Batches(ctx, numbers(ctx, 10), config.BatchConfig{Size: 4, FlushInterval: time.Hour}) {
+		for i, record := range batch {
+			if record["id"] != len(sizes)*4+i {
+				t.Fatalf("batch %d holds %v at %d", len(sizes), record["id"], i)
+			}
+		}
+		sizes = append(sizes, len(batch))
+	}
+	if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 4 || sizes[2] != 2 {
+		t.Errorf("batch sizes %v, want [4 4 2]", sizes)
+	}
+}
+
+func TestBatchSenderFlushesAfterTheInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan Batch, 4)
+	s := NewBatchSender(ctx, out, config.BatchConfig{Size: 100, FlushInterval: 20 * time.Millisecond})
+	defer s.Stop()
+
+	for i := 0; i < 3; i++ {
+		if err := s.Send(DataRecord{"id": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case batch := <-out:
+		if len(batch) != 3 {
+			t.Errorf("flushed %d records, want 3", len(batch))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a partial batch was not flushed after the interval")
+	}
+
+	// the next batch starts its own interval
+	if err := s.Send(DataRecord{"id": 3}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case batch := <-out:
+		if len(batch) != 1 {
+			t.Errorf("flushed %d records, want 1", len(batch))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second batch was not flushed")
+	}
+}
+
+func TestBatchSenderStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan Batch, 1)
+	s := NewBatchSender(ctx, out, config.BatchConfig{Size: 10, FlushInterval: 10 * time.Millisecond})
+	s.Send(DataRecord{"id": 0})
+	s.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if len(out) != 0 {
+		t.Error("a stopped sender flushed its batch")
+	}
+
+	// once the run is cancelled, sends fail rather than block
+	s = NewBatchSender(ctx, make(chan Batch), config.BatchConfig{Size: 1})
+	cancel()
+	if err := s.Send(DataRecord{"id": 0}); err != context.Canceled {
+		t.Errorf("got %v, want the run cancelled", err)
+	}
+}
+
+func TestBatchWorkersKeepOrder(t *testing.T) {
+	ctx := context.Background()
+	batches := Batches(ctx, numbers(ctx, 1000), config.BatchConfig{Size: 7})
+	output, errs := RunBatchWorkers(ctx, batches, 4, true, func(batch Batch) (Batch, error) {
+		var kept Batch
+		for _, record := range batch {
+			if r, _ := slowly(record); r != nil {
+				kept = append(kept, r)
+			}
+		}
+		return kept, nil
+	})
+	ids, err := collectIDs(Unbatch(ctx, output), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 900 {
+		t.Fatalf("got %d records, want 900", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("id %d left after %d", ids[i], ids[i-1])
+		}
+	}
+}
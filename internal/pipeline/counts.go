@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SourceCounts are the rows extractors read during a run, by shard and
+// table, with a checksum of some of their columns. The orchestrator puts
+// one in the context of a reconciled run; SQL extractors add the rows they
+// read, and the SQL loader compares the sink with them once it has
+// finished. It is safe for concurrent use.
+type SourceCounts struct {
+	columns []string
+
+	mu     sync.Mutex
+	counts map[SourceKey]*SourceCount
+}
+
+type SourceKey struct {
+	Shard, Table string
+}
+
+type SourceCount struct {
+	Rows     int64
+	Checksum Checksum
+}
+
+// NewSourceCounts counts rows, taking the checksum of the given columns.
+func NewSourceCounts(columns []string) *SourceCounts {
+	return &SourceCounts{columns: columns, counts: make(map[SourceKey]*SourceCount)}
+}
+
+type sourceCountsKey struct{}
+
+func WithSourceCounts(ctx context.Context, c *SourceCounts) context.Context {
+	return context.WithValue(ctx, sourceCountsKey{}, c)
+}
+
+// SourceCountsFromContext returns the counts of ctx, or nil when the run is
+// not reconciled. Add on nil counts does nothing.
+func SourceCountsFromContext(ctx context.Context) *SourceCounts {
+	c, _ := ctx.Value(sourceCountsKey{}).(*SourceCounts)
+	return c
+}
+
+// Add counts a record read from table on shard.
+func (c *SourceCounts) Add(shard, table string, record DataRecord) {
+	if c == nil {
+		return
+	}
+	values := make([]interface{}, len(c.columns))
+	for i, col := range c.columns {
+		values[i] = record[col]
+	}
+	var sum Checksum
+	sum.Add(values)
+
+	k := SourceKey{Shard: shard, Table: table}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count, ok := c.counts[k]
+	if !ok {
+		count = &SourceCount{}
+		c.counts[k] = count
+	}
+	count.Rows++
+	count.Checksum += sum
+}
+
+// Get returns what was read from table on shard.
+func (c *SourceCounts) Get(shard, table string) SourceCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if count, ok := c.counts[SourceKey{Shard: shard, Table: table}]; ok {
+		return *count
+	}
+	return SourceCount{}
+}
+
+// Checksum is a checksum of a set of rows that does not depend on their
+// order: the sum of a 64-bit FNV-1a hash of each row. Values are hashed as
+// text, so an integer checksums the same as a string of its digits, and
+// times are taken in UTC.
+type Checksum uint64
+
+// Add adds a row of values to the checksum.
+func (c *Checksum) Add(values []interface{}) {
+	h := fnv.New64a()
+	for _, v := range values {
+		if v == nil {
+			h.Write([]byte{'-'})
+			continue
+		}
+		text := checksumText(v)
+		fmt.Fprintf(h, "%d:%s", len(text), text)
+	}
+	*c += Checksum(h.Sum64())
+}
+
+func (c Checksum) String() string {
+	return fmt.Sprintf("%016x", uint64(c))
+}
+
+func checksumText(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
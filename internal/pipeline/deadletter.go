@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeadLetter appends records that a component gave up on to a JSON Lines
+// file, one entry per record with the error that caused it. The file is
+// created on the first write, so runs without failures leave nothing behind.
+// It is safe for concurrent use.
+type DeadLetter struct {
+	path     string
+	pipeline string
+
+	mu    sync.Mutex
+	file  *os.File
+	count int
+}
+
+// DeadLetterEntry is the shape of each line in the dead letter file.
+type DeadLetterEntry struct {
+	Time     time.Time  `json:"time"`
+	Pipeline string     `json:"pipeline"`
+	Stage    string     `json:"stage"`
+	Error    string     `json:"error"`
+	Record   DataRecord `json:"record"`
+}
+
+func NewDeadLetter(path, pipelineName string) *DeadLetter {
+	return &DeadLetter{path: path, pipeline: pipelineName}
+}
+
+// Write records a failed record for the given stage.
+func (d *DeadLetter) Write(stage string, record DataRecord, cause error) error {
+	line, err := json.Marshal(DeadLetterEntry{
+		Time:     time.Now().UTC(),
+		Pipeline: d.pipeline,
+		Stage:    stage,
+		Error:    cause.Error(),
+		Record:   record,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+	line = append(line, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+			return fmt.Errorf("failed to create dead letter directory: %w", err)
+		}
+		f, err := os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open dead letter file: %w", err)
+		}
+		d.file = f
+	}
+
+	if _, err := d.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write dead letter file: %w", err)
+	}
+	d.count++
+	return nil
+}
+
+// Count returns the number of records written so far.
+func (d *DeadLetter) Count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+func (d *DeadLetter) Path() string {
+	return d.path
+}
+
+func (d *DeadLetter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close dead letter file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+import "errors"
+
+// PermanentError is an error that running the pipeline again would only
+// repeat, such as failed data quality checks. The orchestrator does not
+// retry it.
+type PermanentError struct {
+	Err error
+}
+
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err, or an error it wraps, is permanent.
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
@@ -8,24 +8,68 @@ import (
 
 type DataRecord map[string]interface{}
 
+// FieldSourceTable is set by extractors on every record to the table or file
+// the record was read from.
+const FieldSourceTable = "_source_table"
+
+// FieldSourceShard is set by SQL extractors when the run is reconciled, to
+// the server or file the record was read from.
+const FieldSourceShard = "_source_shard"
+
 type Extractor interface {
 	Init(ctx context.Context, cfg *config.PipelineConfig) error
 	Extract(ctx context.Context) (<-chan DataRecord, <-chan error)
 	Close() error
 }
 
+// BatchExtractor is implemented by extractors that can read batches of
+// records themselves. The orchestrator calls ExtractBatches instead of
+// Extract when pipeline.batch.size is above one.
+type BatchExtractor interface {
+	ExtractBatches(ctx context.Context) (<-chan Batch, <-chan error)
+}
+
+// Committer is implemented by extractors that acknowledge consumed records
+// at the source, such as message queues, and by transformers that keep state
+// between runs. The orchestrator calls Commit once the loader has finished
+// successfully, so records are only acknowledged after they have been
+// loaded.
+type Committer interface {
+	Commit(ctx context.Context) error
+}
+
 type Transformer interface {
 	Init(ctx context.Context, cfg *config.PipelineConfig) error
 	Transform(ctx context.Context, input <-chan DataRecord) (<-chan DataRecord, <-chan error)
 	Close() error
 }
 
+// ParallelTransformer is implemented by transformers that can spread their
+// work over several goroutines. The orchestrator calls TransformParallel
+// instead of Transform when pipeline.workers is above one.
+type ParallelTransformer interface {
+	TransformParallel(ctx context.Context, input <-chan DataRecord, workers int, ordered bool) (<-chan DataRecord, <-chan error)
+}
+
+// BatchTransformer is implemented by transformers that can work on batches.
+// It replaces both Transform and TransformParallel when batching is
+// configured; workers is pipeline.workers.
+type BatchTransformer interface {
+	TransformBatches(ctx context.Context, input <-chan Batch, workers int, ordered bool) (<-chan Batch, <-chan error)
+}
+
 type Loader interface {
 	Init(ctx context.Context, cfg *config.PipelineConfig) error
 	Load(ctx context.Context, input <-chan DataRecord) error
 	Close() error
 }
 
+// BatchLoader is implemented by loaders that can write a batch of records
+// at a time, such as in one transaction.
+type BatchLoader interface {
+	LoadBatches(ctx context.Context, input <-chan Batch) error
+}
+
 type Pipeline interface {
 	Init(ctx context.Context, cfg *config.PipelineConfig) error
 	Run(ctx context.Context) error
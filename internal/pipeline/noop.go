@@ -20,6 +20,10 @@ type NoopLoader struct{}
 
 func (l *NoopLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error { return nil }
 
-func (l *NoopLoader) Load(ctx context.Context, input <-chan DataRecord) error { return nil }
+func (l *NoopLoader) Load(ctx context.Context, input <-chan DataRecord) error {
+	for range input {
+	}
+	return nil
+}
 
 func (l *NoopLoader) Close() error { return nil }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/aniketwaliyan/etl-framework/internal/state"
 	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
 )
 
@@ -25,7 +26,23 @@ func NewOrchestrator(cfg *config.PipelineConfig, ext Extractor, trans Transforme
 	}
 }
 
+// Execute runs the pipeline, retrying as configured, and records the
+// outcome in the run metadata.
 func (o *Orchestrator) Execute(ctx context.Context) error {
+	run := NewRun(o.config.Pipeline.Name)
+	ctx = WithRun(ctx, run)
+
+	err := o.execute(ctx, run)
+	path, werr := run.finish(o.config.Pipeline.RunsDir, err)
+	if werr != nil {
+		log.Printf("Error writing run metadata: %v", werr)
+	} else {
+		log.Printf("Run metadata written to %s", path)
+	}
+	return err
+}
+
+func (o *Orchestrator) execute(ctx context.Context, run *Run) error {
 	var lastErr error
 
 	for attempt := 0; attempt <= o.config.Pipeline.Retries; attempt++ {
@@ -34,8 +51,12 @@ func (o *Orchestrator) Execute(ctx context.Context) error {
 				attempt, o.config.Pipeline.Retries, lastErr)
 			time.Sleep(o.config.Pipeline.RetryDelay)
 		}
+		run.Attempts = attempt + 1
 
 		if err := o.runPipeline(ctx); err != nil {
+			if IsPermanent(err) {
+				return fmt.Errorf("pipeline failed, not retrying: %w", err)
+			}
 			lastErr = err
 			continue
 		}
@@ -48,43 +69,56 @@ func (o *Orchestrator) Execute(ctx context.Context) error {
 }
 
 func (o *Orchestrator) runPipeline(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Each attempt loads the state afresh, shared by the components, and
+	// counts the source rows it reads anew.
+	ctx = state.NewContext(ctx)
+	if o.config.Reconcile.Enabled() {
+		ctx = WithSourceCounts(ctx, NewSourceCounts(o.config.Reconcile.Checksums))
+	}
 
 	if err := o.initComponents(ctx); err != nil {
 		return fmt.Errorf("initialization failed: %w", err)
 	}
 	defer o.closeComponents()
 
-	records, extractErrs := o.extractor.Extract(ctx)
+	extracted, extractErrs := o.extract(ctx)
 
-	transformed, transformErrs := o.transformer.Transform(ctx, records)
+	transformed, transformErrs := o.transform(ctx, extracted)
+
+	input, errs := o.guard(ctx, transformed, extractErrs, transformErrs)
 
 	errCh := make(chan error, 1)
+	loaded := make(chan struct{})
 	go func() {
+		defer close(loaded)
 		defer close(errCh)
-		if err := o.loader.Load(ctx, transformed); err != nil {
+		if err := o.load(ctx, input); err != nil {
 			errCh <- err
 		}
 	}()
+	// The loader must stop before the components are closed, also when
+	// another stage has failed.
+	defer func() {
+		cancel()
+		<-loaded
+	}()
 
 	for {
 		select {
-		case err, ok := <-extractErrs:
+		case err, ok := <-errs:
 			if !ok {
-				extractErrs = nil
+				errs = nil
 				continue
 			}
-			return fmt.Errorf("extraction error: %w", err)
-
-		case err, ok := <-transformErrs:
-			if !ok {
-				transformErrs = nil
-				continue
-			}
-			return fmt.Errorf("transformation error: %w", err)
+			return err
 
 		case err, ok := <-errCh:
 			if !ok {
-				return nil
+				// The loader only sees the end of its input once the
+				// other stages have ended without an error.
+				return o.commit(ctx)
 			}
 			return fmt.Errorf("loading error: %w", err)
 
@@ -94,6 +128,176 @@ func (o *Orchestrator) runPipeline(ctx context.Context) error {
 	}
 }
 
+// stream is the output of a stage: records, or batches when batching is
+// configured and the stage produces them. Each stage converts the stream
+// to what it consumes, so per-record components keep working between ones
+// that pass batches.
+type stream struct {
+	records <-chan DataRecord
+	batches <-chan Batch
+}
+
+func (o *Orchestrator) records(ctx context.Context, s stream) <-chan DataRecord {
+	if s.batches != nil {
+		return Unbatch(ctx, s.batches)
+	}
+	return s.records
+}
+
+func (o *Orchestrator) batches(ctx context.Context, s stream) <-chan Batch {
+	if s.records != nil {
+		return Batches(ctx, s.records, o.config.Pipeline.Batch)
+	}
+	return s.batches
+}
+
+func (o *Orchestrator) extract(ctx context.Context) (stream, <-chan error) {
+	if o.config.Pipeline.Batch.Enabled() {
+		if be, ok := o.extractor.(BatchExtractor); ok {
+			batches, errs := be.ExtractBatches(ctx)
+			return stream{batches: batches}, errs
+		}
+	}
+	records, errs := o.extractor.Extract(ctx)
+	return stream{records: records}, errs
+}
+
+// transform runs the transformer on pipeline.workers goroutines when it
+// supports that, and on one otherwise.
+func (o *Orchestrator) transform(ctx context.Context, input stream) (stream, <-chan error) {
+	workers := o.config.Pipeline.Workers
+	if o.config.Pipeline.Batch.Enabled() {
+		if bt, ok := o.transformer.(BatchTransformer); ok {
+			batches, errs := bt.TransformBatches(ctx, o.batches(ctx, input), workers, o.config.Pipeline.PreserveOrder)
+			return stream{batches: batches}, errs
+		}
+	}
+
+	records := o.records(ctx, input)
+	if workers > 1 {
+		if pt, ok := o.transformer.(ParallelTransformer); ok {
+			records, errs := pt.TransformParallel(ctx, records, workers, o.config.Pipeline.PreserveOrder)
+			return stream{records: records}, errs
+		}
+		log.Printf("Transformer does not support workers, transforming on one")
+	}
+	records, errs := o.transformer.Transform(ctx, records)
+	return stream{records: records}, errs
+}
+
+func (o *Orchestrator) load(ctx context.Context, input stream) error {
+	if o.config.Pipeline.Batch.Enabled() {
+		if bl, ok := o.loader.(BatchLoader); ok {
+			return bl.LoadBatches(ctx, o.batches(ctx, input))
+		}
+	}
+	return o.loader.Load(ctx, o.records(ctx, input))
+}
+
+// guard passes the transformed stream on to the loader while watching the
+// errors of the extractor and the transformer. A stage that fails also ends
+// its output, which the loader would take for the end of a complete run and
+// commit; instead, guard reports the error and leaves the loader's input
+// open, so that the loader stops when the run is cancelled.
+func (o *Orchestrator) guard(ctx context.Context, s stream, extractErrs, transformErrs <-chan error) (stream, <-chan error) {
+	if s.batches != nil {
+		batches, errs := guard(ctx, s.batches, extractErrs, transformErrs)
+		return stream{batches: batches}, errs
+	}
+	records, errs := guard(ctx, s.records, extractErrs, transformErrs)
+	return stream{records: records}, errs
+}
+
+func guard[T any](ctx context.Context, input <-chan T, extractErrs, transformErrs <-chan error) (<-chan T, <-chan error) {
+	output := make(chan T)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			select {
+			case err, ok := <-extractErrs:
+				if !ok {
+					extractErrs = nil
+					continue
+				}
+				errs <- fmt.Errorf("extraction error: %w", err)
+				return
+
+			case err, ok := <-transformErrs:
+				if !ok {
+					transformErrs = nil
+					continue
+				}
+				// A transformer that flushed what a failed extractor
+				// read may fail on it; the extraction error is the cause.
+				if xerr := pendingError(extractErrs); xerr != nil {
+					errs <- fmt.Errorf("extraction error: %w", xerr)
+					return
+				}
+				errs <- fmt.Errorf("transformation error: %w", err)
+				return
+
+			case v, ok := <-input:
+				if !ok {
+					// Stages report errors before closing their output,
+					// so any error is already buffered by now.
+					if err := pendingError(extractErrs); err != nil {
+						errs <- fmt.Errorf("extraction error: %w", err)
+						return
+					}
+					if err := pendingError(transformErrs); err != nil {
+						errs <- fmt.Errorf("transformation error: %w", err)
+						return
+					}
+					close(output)
+					close(errs)
+					return
+				}
+				select {
+				case output <- v:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return output, errs
+}
+
+func pendingError(errs <-chan error) error {
+	if errs == nil {
+		return nil
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// commit runs the commit hooks once the loader has succeeded. The
+// transformer goes first: if the extractor then fails to commit, the source
+// is read again and stateful transformations see the records twice, which
+// they tolerate, rather than losing their state for records that will not
+// be read again.
+func (o *Orchestrator) commit(ctx context.Context) error {
+	for _, component := range []interface{}{o.transformer, o.extractor} {
+		committer, ok := component.(Committer)
+		if !ok {
+			continue
+		}
+		if err := committer.Commit(ctx); err != nil {
+			return fmt.Errorf("commit failed: %w", err)
+		}
+	}
+	return nil
+}
+
 func (o *Orchestrator) initComponents(ctx context.Context) error {
 	if err := o.extractor.Init(ctx, o.config); err != nil {
 		return fmt.Errorf("extractor initialization failed: %w", err)
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+type testExtractor struct {
+	records   int
+	inits     int
+	committed bool
+}
+
+func (e *testExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	e.inits++
+	return nil
+}
+
+func (e *testExtractor) Extract(ctx context.Context) (<-chan DataRecord, <-chan error) {
+	out := make(chan DataRecord)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errs)
+		for i := 0; i < e.records; i++ {
+			select {
+			case out <- DataRecord{"id": i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+func (e *testExtractor) Commit(ctx context.Context) error {
+	e.committed = true
+	return nil
+}
+
+func (e *testExtractor) Close() error { return nil }
+
+// testTransformer passes records on and then fails with the error that
+// fail returns for the attempt, as a check step does once its input ends.
+type testTransformer struct {
+	attempts int
+	fail     func(attempt int) error
+}
+
+func (t *testTransformer) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	t.attempts++
+	return nil
+}
+
+func (t *testTransformer) Transform(ctx context.Context, input <-chan DataRecord) (<-chan DataRecord, <-chan error) {
+	out := make(chan DataRecord)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errs)
+		for r := range input {
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := t.fail(t.attempts); err != nil {
+			errs <- err
+		}
+	}()
+	return out, errs
+}
+
+func (t *testTransformer) Close() error { return nil }
+
+// testLoader records whether it saw the end of its input, where a SQL
+// loader commits its last transaction.
+type testLoader struct {
+	loaded   int
+	finished bool
+}
+
+func (l *testLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.loaded, l.finished = 0, false
+	return nil
+}
+
+func (l *testLoader) Load(ctx context.Context, input <-chan DataRecord) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-input:
+			if !ok {
+				l.finished = true
+				return nil
+			}
+			l.loaded++
+		}
+	}
+}
+
+func (l *testLoader) Close() error { return nil }
+
+func testOrchestrator(t *testing.T, fail func(int) error) (*Orchestrator, *testExtractor, *testTransformer, *testLoader) {
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.Name = "test"
+	cfg.Pipeline.Retries = 2
+	cfg.Pipeline.RunsDir = t.TempDir()
+
+	e := &testExtractor{records: 10}
+	tr := &testTransformer{fail: fail}
+	l := &testLoader{}
+	return NewOrchestrator(cfg, e, tr, l), e, tr, l
+}
+
+func TestPermanentErrorIsNotRetried(t *testing.T) {
+	checkErr := errors.New("checks login_quality failed: not_null(sDealerId)")
+	o, e, tr, l := testOrchestrator(t, func(int) error { return Permanent(checkErr) })
+
+	err := o.Execute(context.Background())
+	if !errors.Is(err, checkErr) || !IsPermanent(err) {
+		t.Fatalf("expected the permanent check error, got %v", err)
+	}
+	if tr.attempts != 1 {
+		t.Fatalf("ran %d attempts, want 1", tr.attempts)
+	}
+	if l.finished {
+		t.Fatal("loader saw the end of its input after the transformation failed")
+	}
+	if e.committed {
+		t.Fatal("extractor committed after the transformation failed")
+	}
+}
+
+func TestTransientErrorIsRetried(t *testing.T) {
+	o, e, tr, l := testOrchestrator(t, func(attempt int) error {
+		if attempt == 1 {
+			return fmt.Errorf("lookup source unavailable")
+		}
+		return nil
+	})
+
+	if err := o.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tr.attempts != 2 {
+		t.Fatalf("ran %d attempts, want 2", tr.attempts)
+	}
+	if !l.finished || l.loaded != 10 || !e.committed {
+		t.Fatalf("loaded %d records, finished=%v committed=%v", l.loaded, l.finished, e.committed)
+	}
+}
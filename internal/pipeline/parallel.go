@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// reorderWindow is how many records per worker may be in flight when the
+// order is preserved. It bounds the records held back behind a slow one.
+const reorderWindow = 64
+
+// RecordFunc transforms one record. It returns nil to drop the record.
+type RecordFunc func(record DataRecord) (DataRecord, error)
+
+// BatchFunc transforms one batch. It may return fewer records than it was
+// given, or none.
+type BatchFunc func(batch Batch) (Batch, error)
+
+type sequenced[T any] struct {
+	seq  uint64
+	item T
+}
+
+// RunWorkers applies fn to the records of input on the given number of
+// goroutines, so fn must be safe for concurrent use. With ordered, each
+// record is numbered as it arrives and the results leave in that order;
+// otherwise they leave as soon as they are ready. The first error from any
+// worker stops the others and is sent on the error channel before the
+// record channel is closed.
+func RunWorkers(ctx context.Context, input <-chan DataRecord, workers int, ordered bool, fn RecordFunc) (<-chan DataRecord, <-chan error) {
+	return runWorkers(ctx, input, workers, ordered, fn, func(record DataRecord) bool {
+		return record != nil
+	})
+}
+
+// RunBatchWorkers is RunWorkers for batches. Empty batches are not passed on.
+func RunBatchWorkers(ctx context.Context, input <-chan Batch, workers int, ordered bool, fn BatchFunc) (<-chan Batch, <-chan error) {
+	return runWorkers(ctx, input, workers, ordered, fn, func(batch Batch) bool {
+		return len(batch) > 0
+	})
+}
+
+// runWorkers is the worker pool behind RunWorkers and RunBatchWorkers. Only
+// the results that keep accepts are passed on.
+func runWorkers[T any](ctx context.Context, input <-chan T, workers int, ordered bool, fn func(T) (T, error), keep func(T) bool) (<-chan T, <-chan error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	output := make(chan T)
+	errs := make(chan error, 1)
+	jobs := make(chan sequenced[T])
+	results := make(chan sequenced[T])
+
+	var tokens chan struct{}
+	if ordered {
+		tokens = make(chan struct{}, workers*reorderWindow)
+	}
+
+	var once sync.Once
+	fail := func(err error) {
+		once.Do(func() {
+			errs <- err
+			cancel()
+		})
+	}
+
+	go func() {
+		defer close(jobs)
+		for seq := uint64(0); ; seq++ {
+			var item T
+			select {
+			case i, ok := <-input:
+				if !ok {
+					return
+				}
+				item = i
+			case <-ctx.Done():
+				return
+			}
+			if tokens != nil {
+				select {
+				case tokens <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case jobs <- sequenced[T]{seq: seq, item: item}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				item, err := fn(job.item)
+				if err != nil {
+					fail(err)
+					return
+				}
+				select {
+				case results <- sequenced[T]{seq: job.seq, item: item}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer cancel()
+		defer close(errs)
+		defer close(output)
+
+		send := func(item T) bool {
+			if !keep(item) {
+				return true
+			}
+			select {
+			case output <- item:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// pending holds results that finished before the ones numbered
+		// below them, including the ones that are dropped.
+		pending := make(map[uint64]T)
+		var next uint64
+		for r := range results {
+			if !ordered {
+				if !send(r.item) {
+					return
+				}
+				continue
+			}
+			pending[r.seq] = r.item
+			for {
+				item, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-tokens
+				if !send(item) {
+					return
+				}
+			}
+		}
+	}()
+
+	return output, errs
+}
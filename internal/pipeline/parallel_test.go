@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// numbers sends records with ids 0 to n-1 until ctx is done.
+func numbers(ctx context.Context, n int) <-chan DataRecord {
+	out := make(chan DataRecord)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			select {
+			case out <- DataRecord{"id": i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// slowly drops every tenth record and holds the others back for a time that
+// varies with their id, so that workers finish out of order.
+func slowly(record DataRecord) (DataRecord, error) {
+	id := record["id"].(int)
+	if id%10 == 0 {
+		return nil, nil
+	}
+	time.Sleep(time.Duration(id%7) * 100 * time.Microsecond)
+	return record, nil
+}
+
+func collectIDs(output <-chan DataRecord, errs <-chan error) ([]int, error) {
+	var ids []int
+	for record := range output {
+		ids = append(ids, record["id"].(int))
+	}
+	return ids, <-errs
+}
+
+func TestRunWorkersKeepsOrder(t *testing.T) {
+	ctx := context.Background()
+	ids, err := collectIDs(RunWorkers(ctx, numbers(ctx, 1000), 8, true, slowly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 900 {
+		t.Fatalf("got %d records, want 900", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("id %d left after %d", ids[i], ids[i-1])
+		}
+	}
+
+	// unordered, the same records leave in any order
+	ids, err = collectIDs(RunWorkers(ctx, numbers(ctx, 1000), 8, false, slowly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(ids)
+	if len(ids) != 900 {
+		t.Fatalf("got %d records, want 900", len(ids))
+	}
+	if ids[0] != 1 || ids[899] != 999 {
+		t.Errorf("got ids from %d to %d", ids[0], ids[899])
+	}
+}
+
+func TestRunWorkersStopsOnError(t *testing.T) {
+	boom := errors.New("boom")
+	for _, ordered := range []bool{true, false} {
+		ctx, cancel := context.WithCancel(context.Background())
+		output, errs := RunWorkers(ctx, numbers(ctx, 100000), 4, ordered, func(record DataRecord) (DataRecord, error) {
+			if record["id"].(int) == 50 {
+				return nil, boom
+			}
+			return record, nil
+		})
+
+		done := make(chan int)
+		go func() {
+			n := 0
+			for range output {
+				n++
+			}
+			done <- n
+		}()
+		select {
+		case n := <-done:
+			if n >= 100000-1 {
+				t.Errorf("ordered %v: the pool ran on after the error", ordered)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ordered %v: the output was not closed after the error", ordered)
+		}
+		if err := <-errs; !errors.Is(err, boom) {
+			t.Errorf("ordered %v: got %v, want the worker's error", ordered, err)
+		}
+		if _, ok := <-errs; ok {
+			t.Errorf("ordered %v: more than one error", ordered)
+		}
+		cancel()
+	}
+}
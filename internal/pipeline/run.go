@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultRunsDir = "runs"
+
+// Run is the metadata of one pipeline execution. Components find it in the
+// context passed to Init and record what they did with Set; the orchestrator
+// writes it to runs/<pipeline>/<run_id>.json when the run ends. It is safe
+// for concurrent use.
+type Run struct {
+	ID         string    `json:"run_id"`
+	Pipeline   string    `json:"pipeline"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Attempts   int       `json:"attempts"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+
+	mu       sync.Mutex
+	metadata map[string]interface{}
+}
+
+func NewRun(pipelineName string) *Run {
+	started := time.Now().UTC()
+	return &Run{
+		ID:        started.Format("20060102T150405.000Z"),
+		Pipeline:  pipelineName,
+		StartedAt: started,
+		metadata:  make(map[string]interface{}),
+	}
+}
+
+type runKey struct{}
+
+func WithRun(ctx context.Context, run *Run) context.Context {
+	return context.WithValue(ctx, runKey{}, run)
+}
+
+// RunFromContext returns the run of ctx, or nil outside the orchestrator.
+// Set and Get on a nil run do nothing.
+func RunFromContext(ctx context.Context) *Run {
+	run, _ := ctx.Value(runKey{}).(*Run)
+	return run
+}
+
+// Set records v under key in the run metadata, replacing any earlier value.
+func (r *Run) Set(key string, v interface{}) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metadata[key] = v
+}
+
+func (r *Run) Get(key string) (interface{}, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.metadata[key]
+	return v, ok
+}
+
+func (r *Run) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type plain Run
+	return json.Marshal(struct {
+		*plain
+		Metadata map[string]interface{} `json:"metadata,omitempty"`
+	}{(*plain)(r), r.metadata})
+}
+
+// finish sets the outcome of the run and writes it under dir.
+func (r *Run) finish(dir string, err error) (string, error) {
+	r.mu.Lock()
+	r.FinishedAt = time.Now().UTC()
+	r.Status = "succeeded"
+	if err != nil {
+		r.Status = "failed"
+		r.Error = err.Error()
+	}
+	r.mu.Unlock()
+
+	data, jerr := json.MarshalIndent(r, "", "  ")
+	if jerr != nil {
+		return "", fmt.Errorf("failed to encode run metadata: %w", jerr)
+	}
+	if dir == "" {
+		dir = defaultRunsDir
+	}
+	path := filepath.Join(dir, r.Pipeline, r.ID+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create run metadata directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write run metadata: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,448 @@
+// Package schema describes the fields of records and coerces the loosely
+// typed values extractors produce into them.
+package schema
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// Field types. A field without a type is only checked for nulls.
+const (
+	String    = "string"
+	Int       = "int"
+	Float     = "float"
+	Decimal   = "decimal"
+	Bool      = "bool"
+	Timestamp = "timestamp"
+	UUID      = "uuid"
+	Bytes     = "bytes"
+)
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// Field is one field of a record. Bits is the width of an integer field,
+// and an 8-bit integer is unsigned as in SQL Server's TINYINT. MixedEndian
+// is set for uuids read from SQL Server, which sends the first three groups
+// of a uniqueidentifier little-endian.
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Nullable    bool   `json:"nullable"`
+	Precision   int    `json:"precision,omitempty"`
+	Scale       int    `json:"scale,omitempty"`
+	Length      int    `json:"length,omitempty"`
+	Bits        int    `json:"bits,omitempty"`
+	MixedEndian bool   `json:"-"`
+}
+
+// New returns a nullable field of the declared type, which is one of the
+// types above or a SQL type name such as NVARCHAR(50), SMALLINT or
+// DECIMAL(18,4).
+func New(name, declared string) (Field, error) {
+	f := Field{Name: name, Nullable: true}
+
+	t := strings.ToLower(strings.TrimSpace(declared))
+	var args []string
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		if !strings.HasSuffix(t, ")") {
+			return f, fmt.Errorf("invalid type %s", declared)
+		}
+		for _, arg := range strings.Split(t[i+1:len(t)-1], ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+		t = strings.TrimSpace(t[:i])
+	}
+	size := func(i int) (int, error) {
+		if i >= len(args) || args[i] == "max" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(args[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid type %s", declared)
+		}
+		return n, nil
+	}
+
+	var err error
+	switch t {
+	case "":
+	case String, "text", "varchar", "nvarchar", "char", "nchar", "ntext", "xml", "clob":
+		f.Type = String
+		f.Length, err = size(0)
+	case Int, "integer", "bigint", "int64", "long":
+		f.Type, f.Bits = Int, 64
+	case "int32":
+		f.Type, f.Bits = Int, 32
+	case "smallint", "int16":
+		f.Type, f.Bits = Int, 16
+	case "tinyint":
+		f.Type, f.Bits = Int, 8
+	case Float, "double", "real", "float32", "float64":
+		f.Type = Float
+	case Decimal, "numeric", "number":
+		f.Type = Decimal
+		if f.Precision, err = size(0); err == nil {
+			f.Scale, err = size(1)
+		}
+	case "money":
+		f.Type, f.Precision, f.Scale = Decimal, 19, 4
+	case "smallmoney":
+		f.Type, f.Precision, f.Scale = Decimal, 10, 4
+	case Bool, "boolean", "bit":
+		f.Type = Bool
+	case Timestamp, "datetime", "datetime2", "smalldatetime", "datetimeoffset", "date", "time":
+		f.Type = Timestamp
+	case UUID, "uniqueidentifier", "guid":
+		f.Type = UUID
+	case Bytes, "binary", "varbinary", "blob", "image", "bytea":
+		f.Type = Bytes
+	default:
+		return f, fmt.Errorf("unsupported type: %s", declared)
+	}
+	return f, err
+}
+
+// FromConfig returns the fields declared in the pipeline configuration.
+func FromConfig(fields []config.FieldConfig) ([]Field, error) {
+	seen := make(map[string]bool, len(fields))
+	result := make([]Field, 0, len(fields))
+	for i, fc := range fields {
+		if fc.Name == "" {
+			return nil, fmt.Errorf("field %d has no name", i)
+		}
+		if seen[fc.Name] {
+			return nil, fmt.Errorf("field %s is declared twice", fc.Name)
+		}
+		seen[fc.Name] = true
+
+		f, err := New(fc.Name, fc.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", fc.Name, err)
+		}
+		if fc.Nullable != nil {
+			f.Nullable = *fc.Nullable
+		}
+		if fc.Precision > 0 {
+			f.Precision = fc.Precision
+		}
+		if fc.Scale > 0 {
+			f.Scale = fc.Scale
+		}
+		if fc.Length > 0 {
+			f.Length = fc.Length
+		}
+		if f.Precision > 0 && f.Scale > f.Precision {
+			return nil, fmt.Errorf("field %s: scale %d exceeds precision %d", fc.Name, f.Scale, f.Precision)
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// Coerce converts v to the Go type of the field: string, int64, float64,
+// bool, time.Time or []byte, a decimal as a string with Scale digits after
+// the point, and a uuid as an uppercase string in the form SQL Server
+// prints it.
+func (f Field) Coerce(v interface{}) (interface{}, error) {
+	if v == nil {
+		if !f.Nullable {
+			return nil, fmt.Errorf("null is not allowed")
+		}
+		return nil, nil
+	}
+
+	switch f.Type {
+	case String:
+		s := toString(v)
+		if f.Length > 0 && utf8.RuneCountInString(s) > f.Length {
+			return nil, fmt.Errorf("%d characters exceed length %d", utf8.RuneCountInString(s), f.Length)
+		}
+		return s, nil
+	case Int:
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		return n, f.checkRange(n)
+	case Float:
+		return toFloat64(v)
+	case Decimal:
+		return f.decimal(v)
+	case Bool:
+		return toBool(v)
+	case Timestamp:
+		return toTime(v)
+	case UUID:
+		return f.uuid(v)
+	case Bytes:
+		switch b := v.(type) {
+		case []byte:
+			return b, nil
+		case string:
+			return []byte(b), nil
+		default:
+			return nil, fmt.Errorf("cannot convert %T to bytes", v)
+		}
+	default:
+		return v, nil
+	}
+}
+
+func (f Field) checkRange(n int64) error {
+	var min, max int64
+	switch f.Bits {
+	case 8:
+		min, max = 0, math.MaxUint8
+	case 16:
+		min, max = math.MinInt16, math.MaxInt16
+	case 32:
+		min, max = math.MinInt32, math.MaxInt32
+	default:
+		return nil
+	}
+	if n < min || n > max {
+		return fmt.Errorf("value %d is outside %d to %d", n, min, max)
+	}
+	return nil
+}
+
+// decimal returns v as an exact decimal string, rounded to Scale digits
+// when a precision or scale is declared.
+func (f Field) decimal(v interface{}) (string, error) {
+	var text string
+	switch n := v.(type) {
+	case string:
+		text = strings.TrimSpace(n)
+	case []byte:
+		text = strings.TrimSpace(string(n))
+	case json.Number:
+		text = string(n)
+	case float64:
+		text = strconv.FormatFloat(n, 'f', -1, 64)
+	case float32:
+		text = strconv.FormatFloat(float64(n), 'f', -1, 32)
+	case bool:
+		return "", fmt.Errorf("cannot convert bool to a decimal")
+	default:
+		i, err := toInt64(v)
+		if err != nil {
+			return "", fmt.Errorf("cannot convert %T to a decimal", v)
+		}
+		text = strconv.FormatInt(i, 10)
+	}
+
+	r, ok := new(big.Rat).SetString(text)
+	if !ok || strings.ContainsRune(text, '/') {
+		return "", fmt.Errorf("invalid decimal %q", text)
+	}
+	if f.Precision == 0 && f.Scale == 0 {
+		return r.FloatString(decimalPlaces(r)), nil
+	}
+
+	out := r.FloatString(f.Scale)
+	// a negative value that rounds to zero is printed as -0.00
+	if strings.Trim(out, "-0.") == "" {
+		out = strings.TrimPrefix(out, "-")
+	}
+	if f.Precision > 0 {
+		whole := strings.TrimLeft(strings.SplitN(strings.TrimPrefix(out, "-"), ".", 2)[0], "0")
+		if len(whole) > f.Precision-f.Scale {
+			return "", fmt.Errorf("%s does not fit DECIMAL(%d,%d)", out, f.Precision, f.Scale)
+		}
+	}
+	return out, nil
+}
+
+// decimalPlaces returns how many digits after the point r needs to be
+// printed exactly.
+func decimalPlaces(r *big.Rat) int {
+	ten := big.NewRat(10, 1)
+	x := new(big.Rat).Set(r)
+	places := 0
+	for !x.IsInt() && places < 64 {
+		x.Mul(x, ten)
+		places++
+	}
+	return places
+}
+
+func (f Field) uuid(v interface{}) (string, error) {
+	switch u := v.(type) {
+	case [16]byte:
+		return FormatUUID(u[:], f.MixedEndian), nil
+	case []byte:
+		if len(u) == 16 {
+			return FormatUUID(u, f.MixedEndian), nil
+		}
+		return parseUUID(string(u))
+	case string:
+		return parseUUID(u)
+	default:
+		return "", fmt.Errorf("cannot convert %T to a uuid", v)
+	}
+}
+
+func parseUUID(s string) (string, error) {
+	text := strings.Trim(strings.TrimSpace(s), "{}")
+	b, err := hex.DecodeString(strings.ReplaceAll(text, "-", ""))
+	if err != nil || len(b) != 16 {
+		return "", fmt.Errorf("invalid uuid %q", s)
+	}
+	return FormatUUID(b, false), nil
+}
+
+// FormatUUID prints 16 bytes as an uppercase uuid. With mixedEndian, the
+// first three groups are reversed, as SQL Server stores them.
+func FormatUUID(b []byte, mixedEndian bool) string {
+	u := append([]byte(nil), b...)
+	if mixedEndian {
+		u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+		u[4], u[5] = u[5], u[4]
+		u[6], u[7] = u[7], u[6]
+	}
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case time.Time:
+		return s.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(s), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", n)
+		}
+		return int64(n), nil
+	case float32:
+		return floatToInt64(float64(n))
+	case float64:
+		return floatToInt64(n)
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case json.Number:
+		return strconv.ParseInt(string(n), 10, 64)
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+	case []byte:
+		return strconv.ParseInt(strings.TrimSpace(string(n)), 10, 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to an integer", v)
+	}
+}
+
+func floatToInt64(f float64) (int64, error) {
+	// 2^63 is the first float64 above the range of int64
+	if f != math.Trunc(f) || f >= 1<<63 || f < -(1<<63) {
+		return 0, fmt.Errorf("value %v is not an integer", f)
+	}
+	return int64(f), nil
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	case []byte:
+		return strconv.ParseFloat(strings.TrimSpace(string(n)), 64)
+	default:
+		i, err := toInt64(v)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %T to a float", v)
+		}
+		return float64(i), nil
+	}
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(b))
+	case []byte:
+		return strconv.ParseBool(strings.TrimSpace(string(b)))
+	default:
+		i, err := toInt64(v)
+		if err != nil {
+			return false, fmt.Errorf("cannot convert %T to a boolean", v)
+		}
+		if i != 0 && i != 1 {
+			return false, fmt.Errorf("value %d is not a boolean", i)
+		}
+		return i == 1, nil
+	}
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		s := strings.TrimSpace(t)
+		for _, layout := range timestampLayouts {
+			if parsed, err := time.Parse(layout, s); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognised timestamp %q", t)
+	case []byte:
+		return toTime(string(t))
+	default:
+		return time.Time{}, fmt.Errorf("cannot convert %T to a timestamp", v)
+	}
+}
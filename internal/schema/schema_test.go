@@ -0,0 +1,270 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		declared string
+		want     Field
+	}{
+		{"", Field{}},
+		{"NVARCHAR(50)", Field{Type: String, Length: 50}},
+		{"varchar(max)", Field{Type: String}},
+		{"text", Field{Type: String}},
+		{"BIGINT", Field{Type: Int, Bits: 64}},
+		{"int", Field{Type: Int, Bits: 64}},
+		{"int32", Field{Type: Int, Bits: 32}},
+		{"SMALLINT", Field{Type: Int, Bits: 16}},
+		{"TINYINT", Field{Type: Int, Bits: 8}},
+		{"real", Field{Type: Float}},
+		{" DECIMAL(18, 4) ", Field{Type: Decimal, Precision: 18, Scale: 4}},
+		{"numeric(10)", Field{Type: Decimal, Precision: 10}},
+		{"money", Field{Type: Decimal, Precision: 19, Scale: 4}},
+		{"smallmoney", Field{Type: Decimal, Precision: 10, Scale: 4}},
+		{"bit", Field{Type: Bool}},
+		{"datetime2(7)", Field{Type: Timestamp}},
+		{"datetimeoffset", Field{Type: Timestamp}},
+		{"UNIQUEIDENTIFIER", Field{Type: UUID}},
+		{"varbinary(max)", Field{Type: Bytes}},
+	} {
+		f, err := New("c", tc.declared)
+		if err != nil {
+			t.Errorf("New(%q): %v", tc.declared, err)
+			continue
+		}
+		tc.want.Name, tc.want.Nullable = "c", true
+		if f != tc.want {
+			t.Errorf("New(%q) = %+v, want %+v", tc.declared, f, tc.want)
+		}
+	}
+
+	for _, declared := range []string{"decimal(18", "varchar(x)", "varchar(-1)", "decimal(18,x)", "geometry"} {
+		if _, err := New("c", declared); err == nil {
+			t.Errorf("New(%q) did not fail", declared)
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	notNull := false
+	fields, err := FromConfig([]config.FieldConfig{
+		{Name: "id", Type: "int", Nullable: &notNull},
+		{Name: "amount", Type: "decimal", Precision: 12, Scale: 2},
+		{Name: "code", Type: "varchar(10)", Length: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields[0].Nullable || !fields[1].Nullable {
+		t.Errorf("nullable %v, %v", fields[0].Nullable, fields[1].Nullable)
+	}
+	if fields[1].Precision != 12 || fields[1].Scale != 2 || fields[2].Length != 4 {
+		t.Errorf("fields %+v", fields)
+	}
+
+	for _, fc := range [][]config.FieldConfig{
+		{{Type: "int"}},
+		{{Name: "a", Type: "int"}, {Name: "a", Type: "text"}},
+		{{Name: "a", Type: "decimal(4,2)", Scale: 6}},
+		{{Name: "a", Type: "geometry"}},
+	} {
+		if _, err := FromConfig(fc); err == nil {
+			t.Errorf("FromConfig(%+v) did not fail", fc)
+		}
+	}
+}
+
+func TestFormatUUID(t *testing.T) {
+	// SQL Server returns uniqueidentifier 6F9619FF-8B86-D011-B42D-00C04FC964FF
+	// as these bytes
+	stored := []byte{0xFF, 0x19, 0x96, 0x6F, 0x86, 0x8B, 0x11, 0xD0, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF}
+	if got := FormatUUID(stored, true); got != "6F9619FF-8B86-D011-B42D-00C04FC964FF" {
+		t.Errorf("mixed-endian uuid %s", got)
+	}
+	if got := FormatUUID(stored, false); got != "FF19966F-868B-11D0-B42D-00C04FC964FF" {
+		t.Errorf("big-endian uuid %s", got)
+	}
+	if stored[0] != 0xFF {
+		t.Error("FormatUUID changed its argument")
+	}
+
+	f := Field{Name: "id", Type: UUID, Nullable: true, MixedEndian: true}
+	var array [16]byte
+	copy(array[:], stored)
+	for _, v := range []interface{}{stored, array, "{6f9619ff-8b86-d011-b42d-00c04fc964ff}", []byte("6F9619FF8B86D011B42D00C04FC964FF")} {
+		got, err := f.Coerce(v)
+		if err != nil || got != "6F9619FF-8B86-D011-B42D-00C04FC964FF" {
+			t.Errorf("Coerce(%v) = %v, %v", v, got, err)
+		}
+	}
+	for _, v := range []interface{}{"6F9619FF-8B86", stored[:15], 42} {
+		if _, err := f.Coerce(v); err == nil {
+			t.Errorf("Coerce(%v) did not fail", v)
+		}
+	}
+}
+
+func TestCoerceDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		declared string
+		in       interface{}
+		want     string
+	}{
+		{"decimal", "12.3400", "12.34"},
+		{"decimal", 0.1, "0.1"},
+		{"decimal", int64(-7), "-7"},
+		{"decimal", json.Number("1e3"), "1000"},
+		{"decimal(18,4)", "12.3", "12.3000"},
+		{"decimal(10,2)", "2.345", "2.35"},
+		{"decimal(10,2)", "-2.345", "-2.35"},
+		{"decimal(10,2)", "2.344999", "2.34"},
+		{"decimal(10,2)", "-0.001", "0.00"},
+		{"decimal(5,2)", "999.994", "999.99"},
+		{"decimal(5,2)", "-999.99", "-999.99"},
+		{"decimal(5,2)", "000123.4", "123.40"},
+		{"decimal(10,0)", float32(2.5), "3"},
+		{"money", []byte(" 19.99 "), "19.9900"},
+	} {
+		f, err := New("amount", tc.declared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Coerce(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("%s of %#v = %v, %v, want %s", tc.declared, tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		declared string
+		in       interface{}
+		err      string
+	}{
+		{"decimal(5,2)", "999.995", "1000.00 does not fit DECIMAL(5,2)"},
+		{"decimal(5,2)", "-1000", "does not fit"},
+		{"decimal(3,3)", "1", "does not fit"},
+		{"decimal", "1/3", "invalid decimal"},
+		{"decimal", "abc", "invalid decimal"},
+		{"decimal", true, "cannot convert bool"},
+		{"decimal", time.Now(), "cannot convert time.Time"},
+	} {
+		f, err := New("amount", tc.declared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f.Coerce(tc.in); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s of %#v = %v, %v, want an error containing %q", tc.declared, tc.in, got, err, tc.err)
+		}
+	}
+}
+
+func TestCoerceIntRanges(t *testing.T) {
+	for _, tc := range []struct {
+		declared string
+		in       interface{}
+		ok       bool
+	}{
+		{"tinyint", 0, true},
+		{"tinyint", uint8(255), true},
+		{"tinyint", 256, false},
+		{"tinyint", -1, false},
+		{"smallint", int16(math.MinInt16), true},
+		{"smallint", "32767", true},
+		{"smallint", 32768, false},
+		{"smallint", -32769, false},
+		{"int32", int64(math.MaxInt32), true},
+		{"int32", int64(math.MaxInt32) + 1, false},
+		{"bigint", uint64(math.MaxInt64), true},
+		{"bigint", uint64(math.MaxInt64) + 1, false},
+		{"bigint", 1e18, true},
+		{"bigint", float64(1 << 63), false},
+		{"bigint", 2.5, false},
+		{"bigint", math.NaN(), false},
+		{"bigint", "12x", false},
+	} {
+		f, err := New("n", tc.declared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Coerce(tc.in)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s of %#v = %v, %v", tc.declared, tc.in, got, err)
+		}
+		if err == nil {
+			if _, ok := got.(int64); !ok {
+				t.Errorf("%s of %#v is %T, want int64", tc.declared, tc.in, got)
+			}
+		}
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	at := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		declared string
+		in, want interface{}
+	}{
+		{"varchar(5)", "héllo", "héllo"},
+		{"text", 1.5, "1.5"},
+		{"text", at, "2026-10-17T09:30:00Z"},
+		{"int", true, int64(1)},
+		{"int", json.Number("42"), int64(42)},
+		{"float", "2.5", 2.5},
+		{"float", int32(3), 3.0},
+		{"bit", 1, true},
+		{"bool", " false ", false},
+		{"datetime", "2026-10-17 09:30:00", at},
+		{"datetime", []byte("2026-10-17T09:30:00Z"), at},
+		{"date", "2026-10-17", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"varbinary", "ab", []byte("ab")},
+		{"", struct{}{}, struct{}{}},
+	} {
+		f, err := New("c", tc.declared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Coerce(tc.in)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s of %#v = %#v, %v, want %#v", tc.declared, tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		declared string
+		in       interface{}
+	}{
+		{"varchar(4)", "héllo"},
+		{"bit", 2},
+		{"bool", "maybe"},
+		{"datetime", "17/10/2026"},
+		{"datetime", 1760693400},
+		{"varbinary", 12},
+		{"float", "1,5"},
+	} {
+		f, err := New("c", tc.declared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f.Coerce(tc.in); err == nil {
+			t.Errorf("%s of %#v = %#v, want an error", tc.declared, tc.in, got)
+		}
+	}
+
+	f := Field{Name: "id", Type: Int}
+	if _, err := f.Coerce(nil); err == nil {
+		t.Error("null accepted by a field that is not nullable")
+	}
+	f.Nullable = true
+	if v, err := f.Coerce(nil); v != nil || err != nil {
+		t.Errorf("Coerce(nil) = %v, %v", v, err)
+	}
+}
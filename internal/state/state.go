@@ -0,0 +1,160 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const defaultDir = "state"
+
+// Store keeps small pieces of pipeline state, such as processed files, as
+// JSON values under string keys in one file per pipeline. Changes are held
+// in memory until Save, which replaces the file atomically, so a crash never
+// leaves a half-written state file. It is safe for concurrent use.
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+// registry holds the stores opened during one run, by path.
+type registry struct {
+	mu     sync.Mutex
+	stores map[string]*Store
+}
+
+type registryKey struct{}
+
+// NewContext returns a context in which Open returns one store per state
+// file. Components of a run that share a file then see each other's
+// changes, and a Save by one of them does not write back stale values of
+// another.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, registryKey{}, &registry{stores: make(map[string]*Store)})
+}
+
+// Open loads the state of the pipeline from pipeline.state_path, by default
+// state/<pipeline>.json. A missing file is an empty state. Within a context
+// from NewContext, the store is loaded once and shared.
+func Open(ctx context.Context, cfg *config.PipelineConfig) (*Store, error) {
+	path := cfg.Pipeline.StatePath
+	if path == "" {
+		if cfg.Pipeline.Name == "" {
+			return nil, fmt.Errorf("state requires pipeline.name or pipeline.state_path")
+		}
+		path = filepath.Join(defaultDir, cfg.Pipeline.Name+".json")
+	}
+
+	reg, ok := ctx.Value(registryKey{}).(*registry)
+	if !ok {
+		return OpenFile(path)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if s, ok := reg.stores[path]; ok {
+		return s, nil
+	}
+	s, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reg.stores[path] = s
+	return s, nil
+}
+
+func OpenFile(path string) (*Store, error) {
+	s := &Store{path: path, values: make(map[string]json.RawMessage)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s.values); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get decodes the value stored under key into v and reports whether it was
+// present.
+func (s *Store) Get(key string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	raw, ok := s.values[key]
+	s.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("invalid state %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (s *Store) Put(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode state %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = raw
+	return nil
+}
+
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+// Save writes the state to disk.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.values, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
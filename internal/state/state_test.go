@@ -0,0 +1,54 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestOpenSharesStoreWithinRun(t *testing.T) {
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.StatePath = filepath.Join(t.TempDir(), "p.json")
+
+	ctx := NewContext(context.Background())
+	a, err := Open(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatal("Open returned two stores for one state file in the same run")
+	}
+
+	// Two components committing in turn must both be kept.
+	if err := a.Put("sessionize:sessions", map[string]int{"d1": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put("sftp:files", []string{"a.csv"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sessions map[string]int
+	if ok, err := reopened.Get("sessionize:sessions", &sessions); err != nil || !ok || sessions["d1"] != 1 {
+		t.Fatalf("sessions lost after a second save: ok=%v err=%v %v", ok, err, sessions)
+	}
+	var files []string
+	if ok, err := reopened.Get("sftp:files", &files); err != nil || !ok || len(files) != 1 {
+		t.Fatalf("files not saved: ok=%v err=%v %v", ok, err, files)
+	}
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	s3Scheme        = "s3://"
+	defaultEndpoint = "s3.amazonaws.com"
+	defaultPartSize = 16 * 1024 * 1024
+)
+
+var errAborted = errors.New("upload aborted")
+
+var s3State struct {
+	mu     sync.Mutex
+	config config.S3Config
+	client *minio.Client
+}
+
+// Configure sets the object store used for s3:// paths. Without it, s3://
+// paths use AWS S3 with credentials from the environment.
+func Configure(cfg config.StorageConfig) {
+	s3State.mu.Lock()
+	defer s3State.mu.Unlock()
+	s3State.config = cfg.S3
+	s3State.client = nil
+}
+
+func isS3(p string) bool {
+	return strings.HasPrefix(p, s3Scheme)
+}
+
+// splitS3 splits s3://bucket/key into its bucket and key.
+func splitS3(p string) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(p, s3Scheme), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid s3 path %s: missing bucket", p)
+	}
+	return bucket, key, nil
+}
+
+func s3Client() (*minio.Client, uint64, error) {
+	s3State.mu.Lock()
+	defer s3State.mu.Unlock()
+
+	cfg := s3State.config
+	partSize := cfg.PartSize
+	if partSize == 0 {
+		partSize = defaultPartSize
+	}
+	if s3State.client != nil {
+		return s3State.client, partSize, nil
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	secure := !cfg.Insecure
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid s3 endpoint %s: %w", endpoint, err)
+		}
+		endpoint = u.Host
+		secure = u.Scheme == "https"
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		accessKey, err := secrets.Resolve(cfg.AccessKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("s3 access key: %w", err)
+		}
+		secretKey, err := secrets.Resolve(cfg.SecretKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("s3 secret key: %w", err)
+		}
+		token, err := secrets.Resolve(cfg.SessionToken)
+		if err != nil {
+			return nil, 0, fmt.Errorf("s3 session token: %w", err)
+		}
+		creds = credentials.NewStaticV4(accessKey, secretKey, token)
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.IAM{},
+		})
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+	s3State.client = client
+	return client, partSize, nil
+}
+
+// s3Glob lists the objects under the literal prefix of pattern and keeps
+// those matching it. As with local paths, a pattern that matches a "directory"
+// selects every object below it.
+func s3Glob(ctx context.Context, pattern string) ([]string, error) {
+	bucket, keyPattern, err := splitS3(pattern)
+	if err != nil {
+		return nil, err
+	}
+	keyPattern = strings.TrimSuffix(keyPattern, "/")
+	if _, err := path.Match(keyPattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	client, _, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := keyPattern
+	if i := strings.IndexAny(prefix, `*?[\`); i >= 0 {
+		prefix = prefix[:i]
+	}
+	depth := 0
+	if keyPattern != "" {
+		depth = strings.Count(keyPattern, "/") + 1
+	}
+
+	var files []string
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true}
+	for obj := range client.ListObjects(ctx, bucket, opts) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", pattern, obj.Err)
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		if s3Matches(keyPattern, depth, obj.Key) {
+			files = append(files, s3Scheme+bucket+"/"+obj.Key)
+		}
+	}
+	return files, nil
+}
+
+func s3Matches(pattern string, depth int, key string) bool {
+	segments := strings.Split(key, "/")
+	if len(segments) < depth {
+		return false
+	}
+	if ok, _ := path.Match(pattern, strings.Join(segments[:depth], "/")); !ok && depth > 0 {
+		return false
+	}
+	for _, s := range segments[depth:] {
+		if IsHidden(s) {
+			return false
+		}
+	}
+	return true
+}
+
+type s3File struct {
+	*minio.Object
+	size int64
+}
+
+func (f *s3File) Size() int64 { return f.size }
+
+// s3OpenFile streams the object; reads at an offset become ranged requests.
+func s3OpenFile(ctx context.Context, p string) (File, error) {
+	bucket, key, err := splitS3(p)
+	if err != nil {
+		return nil, err
+	}
+	client, _, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	return &s3File{Object: obj, size: info.Size}, nil
+}
+
+func s3Remove(ctx context.Context, p string) error {
+	bucket, key, err := splitS3(p)
+	if err != nil {
+		return err
+	}
+	client, _, err := s3Client()
+	if err != nil {
+		return err
+	}
+	if err := client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", p, err)
+	}
+	return nil
+}
+
+// s3Writer feeds an upload running in the background through a pipe. The
+// object only appears once the upload completes, and aborting makes the
+// upload fail so that any parts already sent are discarded.
+type s3Writer struct {
+	path string
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func s3Create(ctx context.Context, p string) (AtomicWriter, error) {
+	bucket, key, err := splitS3(p)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("invalid s3 path %s: missing key", p)
+	}
+	client, partSize, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := &s3Writer{path: p, pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := client.PutObject(ctx, bucket, key, pr, -1, minio.PutObjectOptions{PartSize: partSize})
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	n, err := w.pw.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("failed to upload %s: %w", w.path, err)
+	}
+	return n, nil
+}
+
+func (w *s3Writer) Close() error {
+	w.pw.Close()
+	if err := <-w.done; err != nil {
+		return fmt.Errorf("failed to upload %s: %w", w.path, err)
+	}
+	return nil
+}
+
+func (w *s3Writer) Abort() error {
+	w.pw.CloseWithError(errAborted)
+	<-w.done
+	return nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestS3Matches(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		// a whole bucket, without its hidden and _-prefixed entries
+		{"", "logins/part-0.parquet", true},
+		{"", "_manifests/m.avro", false},
+		{"", "logins/.part-0.parquet.crc", false},
+
+		// a literal key, or the "directory" it names
+		{"logins/part-0.parquet", "logins/part-0.parquet", true},
+		{"logins", "logins/2026/10/part-0.parquet", true},
+		{"logins", "logins/2026/_SUCCESS", false},
+		{"logins", "logins_archive/part-0.parquet", false},
+		{"logins", "logins", true},
+
+		// globs match whole segments, and what is below them
+		{"logins/2026-*", "logins/2026-10/part-0.parquet", true},
+		{"logins/2026-*", "logins/2026-10", true},
+		{"logins/2026-*", "logins/2025-12/part-0.parquet", false},
+		{"logins/2026-*", "logins", false},
+		{"logins/*/part-?.parquet", "logins/2026-10/part-1.parquet", true},
+		{"logins/*/part-?.parquet", "logins/2026-10/part-10.parquet", false},
+		{"logins/*.parquet", "logins/a/b.parquet", false},
+		{"logins/[ab]*", "logins/b1/x.parquet", true},
+
+		// a pattern may name a hidden entry itself
+		{"logins/_staging", "logins/_staging/part-0.parquet", true},
+		{"logins/_staging", "logins/_staging/_tmp/part-0.parquet", false},
+	} {
+		depth := 0
+		if tc.pattern != "" {
+			depth = strings.Count(tc.pattern, "/") + 1
+		}
+		if got := s3Matches(tc.pattern, depth, tc.key); got != tc.want {
+			t.Errorf("%q against %q: got %v, want %v", tc.key, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestSplitS3(t *testing.T) {
+	bucket, key, err := splitS3("s3://lake/logins/part-0.parquet")
+	if err != nil || bucket != "lake" || key != "logins/part-0.parquet" {
+		t.Errorf("got %q, %q, %v", bucket, key, err)
+	}
+	if bucket, key, err := splitS3("s3://lake"); err != nil || bucket != "lake" || key != "" {
+		t.Errorf("got %q, %q, %v", bucket, key, err)
+	}
+	if _, _, err := splitS3("s3:///logins"); err == nil {
+		t.Error("a path without a bucket was accepted")
+	}
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Expand resolves each path or glob pattern to the files it matches and
+// returns them sorted and without duplicates. A pattern that matches nothing
+// is an error so that a typo in the config does not look like an empty run.
+func Expand(ctx context.Context, patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, pattern := range patterns {
+		matches, err := Glob(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Glob returns the files matching pattern. A directory, or an S3 prefix,
+// is listed recursively, skipping hidden and underscore-prefixed entries such
+// as in-progress temporary files and _manifests.
+func Glob(ctx context.Context, pattern string) ([]string, error) {
+	if isS3(pattern) {
+		return s3Glob(ctx, pattern)
+	}
+	return localGlob(ctx, pattern)
+}
+
+// IsHidden reports whether listings skip a file or directory name: hidden
+// names and underscore-prefixed ones such as _SUCCESS.
+func IsHidden(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	if isS3(path) {
+		return s3OpenFile(ctx, path)
+	}
+	return localOpen(ctx, path)
+}
+
+// File is a readable file that also supports random access, as needed by
+// columnar formats such as Parquet.
+type File interface {
+	io.ReadCloser
+	io.ReaderAt
+	Size() int64
+}
+
+func OpenFile(ctx context.Context, path string) (File, error) {
+	if isS3(path) {
+		return s3OpenFile(ctx, path)
+	}
+	return localOpenFile(ctx, path)
+}
+
+// OpenDecompressed opens path and transparently gunzips it. The compression
+// is "gzip", "none", or "auto"/"" to decide by the .gz extension.
+func OpenDecompressed(ctx context.Context, path, compression string) (io.ReadCloser, error) {
+	rc, err := Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Decompress(rc, path, compression)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Decompress wraps a reader opened by other means the way OpenDecompressed
+// does. rc is closed when the returned reader is closed; on error it is
+// left to the caller.
+func Decompress(rc io.ReadCloser, path, compression string) (io.ReadCloser, error) {
+	gzipped, err := isGzip(path, compression)
+	if err != nil {
+		return nil, err
+	}
+	if !gzipped {
+		return rc, nil
+	}
+
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip header of %s: %w", path, err)
+	}
+	return &gzipReadCloser{Reader: zr, file: rc}, nil
+}
+
+func isGzip(path, compression string) (bool, error) {
+	switch strings.ToLower(compression) {
+	case "", "auto":
+		return strings.HasSuffix(strings.ToLower(path), ".gz"), nil
+	case "gzip", "gz":
+		return true, nil
+	case "none":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// AtomicWriter is a file that only becomes visible at its final path once
+// Close succeeds. Abort discards everything written so far.
+type AtomicWriter interface {
+	io.WriteCloser
+	Abort() error
+}
+
+// Create opens path for writing. Local files are written through a
+// temporary file and renamed; S3 objects are streamed as a multipart upload
+// that is only completed by Close.
+func Create(ctx context.Context, path string) (AtomicWriter, error) {
+	if isS3(path) {
+		return s3Create(ctx, path)
+	}
+	return localCreate(ctx, path)
+}
+
+// Remove deletes a file or object. A file that does not exist is not an
+// error.
+func Remove(ctx context.Context, path string) error {
+	if isS3(path) {
+		return s3Remove(ctx, path)
+	}
+	return localRemove(ctx, path)
+}
+
+// Join appends slash-separated elements to a local directory or an S3 URL.
+func Join(base string, elem ...string) string {
+	if isS3(base) {
+		return strings.TrimSuffix(base, "/") + "/" + path.Join(elem...)
+	}
+	parts := []string{base}
+	for _, e := range elem {
+		parts = append(parts, filepath.FromSlash(e))
+	}
+	return filepath.Join(parts...)
+}
@@ -0,0 +1,673 @@
+package transform
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/expr"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/state"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const (
+	defaultMaxGroups = 1000000
+
+	fieldWindowStart = "window_start"
+	fieldWindowEnd   = "window_end"
+)
+
+// aggregate groups records by the group_by fields and, with an interval, by
+// tumbling windows of time_field, and emits one summary record per group
+// once the input ends. Summary records carry table as their source table,
+// so that the sink can route them to a summary table and upsert them on the
+// group fields. At most max_groups groups are kept in memory; beyond that
+// they are merged into a file under spill_dir, and without one the run
+// fails.
+//
+// Groups that are still open at the end of a run are kept in the state store
+// and merged with the records of later runs, so that the upserted summaries
+// hold the totals over every run. A window closes once the latest event is
+// lateness past its end; groups without a window never close. Records for a
+// window that closed in an earlier run are not counted.
+type aggregate struct {
+	name      string
+	groupBy   []string
+	timeField string
+	unit      time.Duration
+	interval  time.Duration
+	lateness  time.Duration
+	location  *time.Location
+	measures  []measure
+	table     string
+	keep      bool
+	maxGroups int
+	spillDir  string
+	policy    *errorPolicy
+	state     *state.Store
+	stateKey  string
+
+	groups  map[digest]*aggGroup
+	seq     int64
+	records int64
+	late    int64
+	spill   *aggregateSpill
+
+	// latest is the latest event time over every run, and closed the end
+	// of the last window closed by an earlier run.
+	latest time.Time
+	closed time.Time
+	// open holds the groups to keep once the run has been loaded.
+	open map[digest]*aggGroup
+}
+
+// aggregateState is what the state store keeps, gob-encoded, between runs.
+type aggregateState struct {
+	Latest time.Time
+	Groups map[digest]*aggGroup
+}
+
+// measure is one aggregate column, such as failures: sum(is_failure).
+type measure struct {
+	column string
+	fn     string
+	expr   config.Expression
+	arg    *expr.Program
+}
+
+// aggGroup is the running state of one group. Its fields are exported so
+// that it can be spilled with gob.
+type aggGroup struct {
+	Seq    int64
+	Fields map[string]interface{}
+	Accs   []accumulator
+}
+
+// accumulator holds what every aggregate function needs; each function
+// reads the part it uses.
+type accumulator struct {
+	Count    int64
+	Int      int64
+	Float    float64
+	IsFloat  bool
+	Min, Max interface{}
+	Distinct map[string]bool
+}
+
+func (c *Chain) newAggregate(ctx context.Context, cfg *config.PipelineConfig, tc config.TransformationConfig) (*aggregate, error) {
+	if len(tc.Aggregates) == 0 {
+		return nil, fmt.Errorf("aggregate requires aggregates")
+	}
+	a := &aggregate{
+		name:      tc.Name,
+		groupBy:   tc.GroupBy,
+		timeField: tc.TimeField,
+		unit:      time.Second,
+		interval:  tc.Interval,
+		lateness:  tc.Lateness,
+		table:     tc.Table,
+		maxGroups: tc.MaxGroups,
+		spillDir:  tc.SpillDir,
+		stateKey:  tc.StateKey,
+		groups:    make(map[digest]*aggGroup),
+	}
+	if a.name == "" {
+		a.name = "aggregate"
+	}
+	if a.table == "" {
+		a.table = a.name
+	}
+	if a.maxGroups <= 0 {
+		a.maxGroups = defaultMaxGroups
+	}
+	if a.stateKey == "" {
+		a.stateKey = a.name
+	}
+	a.stateKey = "aggregate:" + a.stateKey
+
+	switch {
+	case a.interval == 0 && a.timeField != "":
+		return nil, fmt.Errorf("aggregate time_field requires interval")
+	case a.interval != 0 && a.timeField == "":
+		return nil, fmt.Errorf("aggregate interval requires time_field")
+	case a.interval < 0 || a.interval%time.Second != 0:
+		return nil, fmt.Errorf("aggregate interval must be a whole number of seconds, got %s", a.interval)
+	case a.lateness < 0 || (a.lateness != 0 && a.interval == 0):
+		return nil, fmt.Errorf("aggregate lateness must be positive and requires interval")
+	}
+	if tc.From != "" {
+		unit, ok := epochUnits[tc.From]
+		if !ok {
+			return nil, fmt.Errorf("aggregate from must be epoch_seconds, epoch_millis or epoch_micros, got %q", tc.From)
+		}
+		a.unit = unit
+	}
+	var err error
+	if a.location, err = loadLocation(tc.Timezone); err != nil {
+		return nil, fmt.Errorf("aggregate timezone: %w", err)
+	}
+
+	switch tc.Events {
+	case "", "keep":
+		a.keep = true
+	case "drop":
+	default:
+		return nil, fmt.Errorf("aggregate events must be keep or drop, got %q", tc.Events)
+	}
+
+	columns := make(map[string]bool)
+	for _, field := range a.groupBy {
+		columns[field] = true
+	}
+	if a.interval != 0 {
+		columns[fieldWindowStart], columns[fieldWindowEnd] = true, true
+	}
+	for _, d := range tc.Aggregates {
+		if columns[d.Column] {
+			return nil, fmt.Errorf("line %d: aggregate column %s is also a group column", d.Expr.Line, d.Column)
+		}
+		m, err := parseMeasure(d)
+		if err != nil {
+			return nil, err
+		}
+		a.measures = append(a.measures, m)
+	}
+
+	if a.policy, err = c.newErrorPolicy(cfg, "aggregate "+a.name, tc.OnError, false); err != nil {
+		return nil, err
+	}
+	if a.state, err = state.Open(ctx, cfg); err != nil {
+		return nil, err
+	}
+	if err := a.restore(); err != nil {
+		return nil, fmt.Errorf("aggregate %s: %w", a.name, err)
+	}
+	return a, nil
+}
+
+// restore loads the groups left open by earlier runs.
+func (a *aggregate) restore() error {
+	var data []byte
+	ok, err := a.state.Get(a.stateKey, &data)
+	if err != nil || !ok {
+		return err
+	}
+	var saved aggregateState
+	if err := decodeSpilled(data, &saved); err != nil {
+		return err
+	}
+	a.latest = saved.Latest
+	if a.interval != 0 && !a.latest.IsZero() {
+		a.closed = a.latest.Add(-a.lateness)
+	}
+	for k, g := range saved.Groups {
+		a.groups[k] = g
+		if g.Seq > a.seq {
+			a.seq = g.Seq
+		}
+	}
+	return nil
+}
+
+// parseMeasure reads an aggregate such as count(*), sum(bytes) or
+// count_distinct(lower(sSessionId)); the argument is an expression.
+func parseMeasure(d config.Derivation) (measure, error) {
+	m := measure{column: d.Column, expr: d.Expr}
+	src := strings.TrimSpace(d.Expr.Source)
+	fn, arg := src, ""
+	if i := strings.IndexByte(src, '('); i >= 0 {
+		if !strings.HasSuffix(src, ")") {
+			return m, fmt.Errorf("line %d: aggregate %s: %q is missing a closing parenthesis", d.Expr.Line, d.Column, src)
+		}
+		fn, arg = strings.TrimSpace(src[:i]), strings.TrimSpace(src[i+1:len(src)-1])
+	}
+	m.fn = strings.ToLower(fn)
+
+	switch m.fn {
+	case "count":
+		if arg == "" || arg == "*" {
+			return m, nil
+		}
+	case "count_distinct", "sum", "avg", "min", "max":
+		if arg == "" {
+			return m, fmt.Errorf("line %d: aggregate %s: %s requires an argument", d.Expr.Line, d.Column, m.fn)
+		}
+	default:
+		return m, fmt.Errorf("line %d: aggregate %s: unknown function %q, expected count, count_distinct, sum, avg, min or max", d.Expr.Line, d.Column, fn)
+	}
+
+	var err error
+	m.arg, err = compile("aggregate "+d.Column, config.Expression{Source: arg, Line: d.Expr.Line, Column: d.Expr.Column})
+	return m, err
+}
+
+// value evaluates the argument of the measure for record. Values to sum or
+// average are converted to numbers here, so that a record is rejected before
+// it is added to any accumulator.
+func (m measure) value(record pipeline.DataRecord) (interface{}, error) {
+	if m.arg == nil {
+		return true, nil
+	}
+	v, err := m.arg.Eval(record)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: aggregate %s: %w", m.expr.Line, m.column, err)
+	}
+	if v == nil || (m.fn != "sum" && m.fn != "avg") {
+		return v, nil
+	}
+	n, err := summable(v)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: aggregate %s: %w", m.expr.Line, m.column, err)
+	}
+	return n, nil
+}
+
+// summable returns v as an int64 or a float64. Booleans count as 0 and 1, so
+// that sum(success_failure != 1) counts failures.
+func summable(v interface{}) (interface{}, error) {
+	switch n := v.(type) {
+	case bool:
+		if n {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case json.Number, string, []byte:
+		s := strings.TrimSpace(canonical(n))
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+	return nil, fmt.Errorf("cannot sum %T", v)
+}
+
+func (a *aggregate) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	if err := a.add(record); err != nil {
+		return a.policy.handle(record, fmt.Errorf("aggregate %s: %w", a.name, err))
+	}
+	if len(a.groups) > a.maxGroups {
+		if a.spillDir == "" {
+			return nil, fmt.Errorf("aggregate %s: more than %d groups, raise max_groups or set spill_dir", a.name, a.maxGroups)
+		}
+		if err := a.spillGroups(); err != nil {
+			return nil, fmt.Errorf("aggregate %s: %w", a.name, err)
+		}
+	}
+	if !a.keep {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (a *aggregate) add(record pipeline.DataRecord) error {
+	values := make([]interface{}, len(a.measures))
+	for i, m := range a.measures {
+		v, err := m.value(record)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+
+	keyValues := make([]interface{}, 0, len(a.groupBy)+1)
+	for _, field := range a.groupBy {
+		keyValues = append(keyValues, record[field])
+	}
+	var start, end time.Time
+	if a.interval != 0 {
+		v := record[a.timeField]
+		if v == nil {
+			return fmt.Errorf("%s is null", a.timeField)
+		}
+		at, err := fieldTime(v, a.unit)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.timeField, err)
+		}
+		start, end = a.window(at)
+		if !a.closed.IsZero() && !end.After(a.closed) {
+			a.late++
+			return nil
+		}
+		if at.After(a.latest) {
+			a.latest = at
+		}
+		keyValues = append(keyValues, start.UnixNano())
+	}
+	a.records++
+
+	k := digestOf(joinKey(keyValues))
+	g, ok := a.groups[k]
+	if !ok {
+		a.seq++
+		g = &aggGroup{Seq: a.seq, Fields: make(map[string]interface{}, len(a.groupBy)+2), Accs: make([]accumulator, len(a.measures))}
+		for _, field := range a.groupBy {
+			g.Fields[field] = record[field]
+		}
+		if a.interval != 0 {
+			g.Fields[fieldWindowStart], g.Fields[fieldWindowEnd] = start, end
+		}
+		a.groups[k] = g
+	}
+	for i, m := range a.measures {
+		g.Accs[i].add(m.fn, values[i])
+	}
+	return nil
+}
+
+// window returns the tumbling window holding t. Intervals of whole days, and
+// intervals that divide a day, start at midnight in the timezone.
+func (a *aggregate) window(t time.Time) (time.Time, time.Time) {
+	local := t.In(a.location)
+	const day = 24 * time.Hour
+	if a.interval%day == 0 {
+		days := int64(a.interval / day)
+		y, m, d := local.Date()
+		n := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+		n -= (n%days + days) % days
+		start := time.Date(1970, 1, 1+int(n), 0, 0, 0, 0, a.location)
+		return start, start.AddDate(0, 0, int(days))
+	}
+
+	_, offset := local.Zone()
+	shift := int64(offset) * int64(time.Second)
+	wall := local.UnixNano() + shift
+	wall -= (wall%int64(a.interval) + int64(a.interval)) % int64(a.interval)
+	start := time.Unix(0, wall-shift).In(a.location)
+	return start, start.Add(a.interval)
+}
+
+func (acc *accumulator) add(fn string, v interface{}) {
+	if v == nil {
+		return
+	}
+	acc.Count++
+	switch fn {
+	case "sum", "avg":
+		switch n := v.(type) {
+		case int64:
+			acc.Int += n
+		case float64:
+			acc.Float += n
+			acc.IsFloat = true
+		}
+	case "min":
+		if acc.Min == nil || compareValues(v, acc.Min) < 0 {
+			acc.Min = v
+		}
+	case "max":
+		if acc.Max == nil || compareValues(v, acc.Max) > 0 {
+			acc.Max = v
+		}
+	case "count_distinct":
+		if acc.Distinct == nil {
+			acc.Distinct = make(map[string]bool)
+		}
+		acc.Distinct[canonical(v)] = true
+	}
+}
+
+// merge adds the state of o, a partial result for the same group and
+// function.
+func (acc *accumulator) merge(o *accumulator) {
+	acc.Count += o.Count
+	acc.Int += o.Int
+	acc.Float += o.Float
+	acc.IsFloat = acc.IsFloat || o.IsFloat
+	if o.Min != nil && (acc.Min == nil || compareValues(o.Min, acc.Min) < 0) {
+		acc.Min = o.Min
+	}
+	if o.Max != nil && (acc.Max == nil || compareValues(o.Max, acc.Max) > 0) {
+		acc.Max = o.Max
+	}
+	if len(o.Distinct) > 0 && acc.Distinct == nil {
+		acc.Distinct = make(map[string]bool, len(o.Distinct))
+	}
+	for v := range o.Distinct {
+		acc.Distinct[v] = true
+	}
+}
+
+// result is the value of fn over the group. Like SQL, sum, avg, min and max
+// of no values are null.
+func (acc *accumulator) result(fn string) interface{} {
+	switch fn {
+	case "count":
+		return acc.Count
+	case "count_distinct":
+		return int64(len(acc.Distinct))
+	case "min":
+		return acc.Min
+	case "max":
+		return acc.Max
+	}
+	if acc.Count == 0 {
+		return nil
+	}
+	if fn == "avg" {
+		return (float64(acc.Int) + acc.Float) / float64(acc.Count)
+	}
+	if acc.IsFloat {
+		return float64(acc.Int) + acc.Float
+	}
+	return acc.Int
+}
+
+func (a *aggregate) record(g *aggGroup) pipeline.DataRecord {
+	r := make(pipeline.DataRecord, len(g.Fields)+len(a.measures)+1)
+	for field, v := range g.Fields {
+		r[field] = v
+	}
+	for i, m := range a.measures {
+		r[m.column] = g.Accs[i].result(m.fn)
+	}
+	r[pipeline.FieldSourceTable] = a.table
+	return r
+}
+
+// spillGroups merges the groups in memory into the spill file.
+func (a *aggregate) spillGroups() error {
+	if a.spill == nil {
+		var err error
+		if a.spill, err = openAggregateSpill(a.spillDir); err != nil {
+			return err
+		}
+		log.Printf("Aggregate %s: more than %d groups, spilling to %s", a.name, a.maxGroups, a.spillDir)
+	}
+	for k, g := range a.groups {
+		if err := a.spill.merge(k, g); err != nil {
+			return err
+		}
+	}
+	a.groups = make(map[digest]*aggGroup)
+	return nil
+}
+
+// flush emits one record per group, in the order the groups were first
+// seen, and sets aside the groups still open for Commit.
+func (a *aggregate) flush(emit func(pipeline.DataRecord) bool) error {
+	groups := 0
+	a.open = make(map[digest]*aggGroup)
+	defer func() {
+		log.Printf("Aggregate %s: %d records into %d groups, %d still open", a.name, a.records, groups, len(a.open))
+		if a.late > 0 {
+			log.Printf("Aggregate %s: %d records for windows closed by an earlier run were not counted", a.name, a.late)
+		}
+	}()
+	each := func(k digest, g *aggGroup) bool {
+		groups++
+		if !a.isClosed(g) {
+			a.open[k] = g
+		}
+		return emit(a.record(g))
+	}
+
+	if a.spill != nil {
+		if err := a.spillGroups(); err != nil {
+			return fmt.Errorf("aggregate %s: %w", a.name, err)
+		}
+		if err := a.spill.each(each); err != nil {
+			return fmt.Errorf("aggregate %s: %w", a.name, err)
+		}
+		return nil
+	}
+
+	keys := make([]digest, 0, len(a.groups))
+	for k := range a.groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return a.groups[keys[i]].Seq < a.groups[keys[j]].Seq })
+	for _, k := range keys {
+		if !each(k, a.groups[k]) {
+			break
+		}
+	}
+	a.groups = make(map[digest]*aggGroup)
+	return nil
+}
+
+// isClosed reports whether the latest event is lateness past the end of the
+// window of g.
+func (a *aggregate) isClosed(g *aggGroup) bool {
+	if a.interval == 0 {
+		return false
+	}
+	end, _ := g.Fields[fieldWindowEnd].(time.Time)
+	return !end.Add(a.lateness).After(a.latest)
+}
+
+// Commit saves the groups still open once the run has been loaded.
+func (a *aggregate) Commit(ctx context.Context) error {
+	if a.open == nil {
+		return nil
+	}
+	data, err := encodeSpilled(&aggregateState{Latest: a.latest, Groups: a.open})
+	if err != nil {
+		return fmt.Errorf("aggregate %s: %w", a.name, err)
+	}
+	if err := a.state.Put(a.stateKey, data); err != nil {
+		return err
+	}
+	return a.state.Save()
+}
+
+func (a *aggregate) report() {
+	a.policy.report()
+}
+
+func (a *aggregate) Close() error {
+	if a.spill == nil {
+		return nil
+	}
+	err := a.spill.close()
+	a.spill = nil
+	return err
+}
+
+// aggregateSpill keeps the partial state of groups that did not fit in
+// memory.
+type aggregateSpill struct {
+	*spillFile
+
+	getStmt     *sql.Stmt
+	putStmt     *sql.Stmt
+	replaceStmt *sql.Stmt
+}
+
+func openAggregateSpill(dir string) (*aggregateSpill, error) {
+	f, err := openSpillFile(dir, "aggregate",
+		"CREATE TABLE groups (k BLOB PRIMARY KEY, seq INTEGER NOT NULL, state BLOB NOT NULL) WITHOUT ROWID")
+	if err != nil {
+		return nil, err
+	}
+	s := &aggregateSpill{spillFile: f}
+	err = f.prepare(map[**sql.Stmt]string{
+		&s.getStmt:     "SELECT state FROM groups WHERE k = ?",
+		&s.putStmt:     "INSERT INTO groups (k, seq, state) VALUES (?, ?, ?)",
+		&s.replaceStmt: "UPDATE groups SET state = ? WHERE k = ?",
+	})
+	if err != nil {
+		f.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// merge adds g to the state spilled for its group, if any.
+func (s *aggregateSpill) merge(k digest, g *aggGroup) error {
+	var data []byte
+	switch err := s.getStmt.QueryRow(k[:]).Scan(&data); err {
+	case nil:
+	case sql.ErrNoRows:
+		if data, err = encodeSpilled(g); err != nil {
+			return err
+		}
+		if _, err := s.putStmt.Exec(k[:], g.Seq, data); err != nil {
+			return fmt.Errorf("spill write failed: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("spill lookup failed: %w", err)
+	}
+
+	var spilled aggGroup
+	if err := decodeSpilled(data, &spilled); err != nil {
+		return err
+	}
+	for i := range spilled.Accs {
+		spilled.Accs[i].merge(&g.Accs[i])
+	}
+	data, err := encodeSpilled(&spilled)
+	if err != nil {
+		return err
+	}
+	if _, err := s.replaceStmt.Exec(data, k[:]); err != nil {
+		return fmt.Errorf("spill write failed: %w", err)
+	}
+	return nil
+}
+
+// each passes the spilled groups to fn in the order they were first seen,
+// until fn returns false.
+func (s *aggregateSpill) each(fn func(digest, *aggGroup) bool) error {
+	rows, err := s.tx.Query("SELECT k, state FROM groups ORDER BY seq")
+	if err != nil {
+		return fmt.Errorf("spill read failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return fmt.Errorf("spill read failed: %w", err)
+		}
+		var g aggGroup
+		if err := decodeSpilled(data, &g); err != nil {
+			return err
+		}
+		var k digest
+		copy(k[:], key)
+		if !fn(k, &g) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,302 @@
+package transform
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func testAggregate(t *testing.T, statePath, step string) *aggregate {
+	t.Helper()
+	var tc config.TransformationConfig
+	if err := yaml.Unmarshal([]byte(step), &tc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.StatePath = statePath
+	c := &Chain{}
+	a, err := c.newAggregate(context.Background(), cfg, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+// runAggregate applies records, flushes and commits, and returns the
+// summary records.
+func runAggregate(t *testing.T, a *aggregate, records ...pipeline.DataRecord) []pipeline.DataRecord {
+	t.Helper()
+	for _, r := range records {
+		if _, err := a.apply(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out []pipeline.DataRecord
+	if err := a.flush(func(r pipeline.DataRecord) bool {
+		out = append(out, r)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestAggregateFunctions(t *testing.T) {
+	a := testAggregate(t, filepath.Join(t.TempDir(), "state.json"), `
+group_by: [dealer]
+events: drop
+aggregates:
+  logins: count(*)
+  with_session: count(session)
+  sessions: count_distinct(lower(session))
+  failures: sum(status != 1)
+  bytes: sum(bytes)
+  avg_bytes: avg(bytes)
+  first: min(at)
+  last: max(at)
+`)
+	out := runAggregate(t, a,
+		pipeline.DataRecord{"dealer": "D1", "session": "S1", "status": int64(1), "bytes": int64(10), "at": "b"},
+		pipeline.DataRecord{"dealer": "D1", "session": "s1", "status": int64(0), "bytes": "2.5", "at": "a"},
+		pipeline.DataRecord{"dealer": "D1", "session": nil, "status": int64(0), "bytes": nil, "at": "c"},
+		pipeline.DataRecord{"dealer": "D2", "session": "S9", "status": int64(1), "bytes": nil, "at": nil},
+	)
+	if len(out) != 2 || out[0]["dealer"] != "D1" || out[1]["dealer"] != "D2" {
+		t.Fatalf("got %v, want D1 then D2", out)
+	}
+
+	d1 := out[0]
+	for column, want := range map[string]interface{}{
+		"logins":       int64(3),
+		"with_session": int64(2),
+		"sessions":     int64(1),
+		"failures":     int64(2),
+		"bytes":        12.5,
+		"avg_bytes":    6.25,
+		"first":        "a",
+		"last":         "c",
+	} {
+		if d1[column] != want {
+			t.Errorf("D1 %s = %#v, want %#v", column, d1[column], want)
+		}
+	}
+	if d1[pipeline.FieldSourceTable] != "aggregate" {
+		t.Errorf("source table %v", d1[pipeline.FieldSourceTable])
+	}
+	if d2 := out[1]; d2["bytes"] != nil || d2["avg_bytes"] != nil || d2["first"] != nil || d2["sessions"] != int64(1) {
+		t.Errorf("D2 = %v, want null sum, avg and min of no values", d2)
+	}
+}
+
+func TestAggregateRejectsInvalidSteps(t *testing.T) {
+	for _, step := range []string{
+		"group_by: [a]",
+		"aggregates: {n: median(x)}",
+		"aggregates: {n: sum()}",
+		"aggregates: {n: 'count(*'}",
+		"group_by: [n]\naggregates: {n: count(*)}",
+		"time_field: at\naggregates: {n: count(*)}",
+		"interval: 1h\naggregates: {n: count(*)}",
+		"time_field: at\ninterval: 90ms\naggregates: {n: count(*)}",
+		"lateness: 1h\naggregates: {n: count(*)}",
+	} {
+		var tc config.TransformationConfig
+		if err := yaml.Unmarshal([]byte(step), &tc); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &config.PipelineConfig{}
+		cfg.Pipeline.StatePath = filepath.Join(t.TempDir(), "state.json")
+		if _, err := (&Chain{}).newAggregate(context.Background(), cfg, tc); err == nil {
+			t.Errorf("%q accepted", step)
+		}
+	}
+}
+
+func TestAggregateWindow(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	for _, tc := range []struct {
+		interval   time.Duration
+		location   *time.Location
+		at         time.Time
+		start, end time.Time
+	}{
+		// hours start on the local hour, half past in UTC
+		{time.Hour, kolkata, time.Date(2026, 10, 17, 4, 10, 0, 0, time.UTC),
+			time.Date(2026, 10, 17, 9, 0, 0, 0, kolkata), time.Date(2026, 10, 17, 10, 0, 0, 0, kolkata)},
+		{24 * time.Hour, kolkata, time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 18, 0, 0, 0, 0, kolkata), time.Date(2026, 10, 19, 0, 0, 0, 0, kolkata)},
+		// days follow the calendar across DST: 23 hours in spring,
+		// 25 in autumn
+		{24 * time.Hour, newYork, time.Date(2026, 3, 8, 12, 0, 0, 0, newYork),
+			time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 9, 0, 0, 0, 0, newYork)},
+		{24 * time.Hour, newYork, time.Date(2026, 11, 1, 23, 30, 0, 0, newYork),
+			time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), time.Date(2026, 11, 2, 0, 0, 0, 0, newYork)},
+		{7 * 24 * time.Hour, time.UTC, time.Date(1970, 1, 9, 0, 0, 0, 0, time.UTC),
+			time.Date(1970, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(1970, 1, 15, 0, 0, 0, 0, time.UTC)},
+		// hours in local wall time, after the clocks go back
+		{6 * time.Hour, newYork, time.Date(2026, 11, 1, 7, 0, 0, 0, newYork),
+			time.Date(2026, 11, 1, 6, 0, 0, 0, newYork), time.Date(2026, 11, 1, 12, 0, 0, 0, newYork)},
+	} {
+		a := &aggregate{interval: tc.interval, location: tc.location}
+		start, end := a.window(tc.at)
+		if !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("%s window of %s in %s = [%s, %s), want [%s, %s)",
+				tc.interval, tc.at, tc.location, start, end, tc.start, tc.end)
+		}
+	}
+	if start, end := (&aggregate{interval: 24 * time.Hour, location: newYork}).window(time.Date(2026, 3, 8, 12, 0, 0, 0, newYork)); end.Sub(start) != 23*time.Hour {
+		t.Errorf("spring-forward day lasts %s, want 23h", end.Sub(start))
+	}
+}
+
+func TestAggregateSpillMerges(t *testing.T) {
+	a := testAggregate(t, filepath.Join(t.TempDir(), "state.json"), `
+group_by: [dealer]
+max_groups: 2
+spill_dir: `+t.TempDir()+`
+events: drop
+aggregates:
+  logins: count(*)
+  sessions: count_distinct(session)
+  first: min(at)
+`)
+	var records []pipeline.DataRecord
+	for i := 0; i < 30; i++ {
+		records = append(records, pipeline.DataRecord{
+			"dealer":  []string{"D1", "D2", "D3", "D4", "D5"}[i%5],
+			"session": []string{"S1", "S2", "S3"}[i%3],
+			"at":      int64(100 - i),
+		})
+	}
+	out := runAggregate(t, a, records...)
+	if a.spill == nil {
+		t.Fatal("more than max_groups groups were not spilled")
+	}
+
+	if len(out) != 5 {
+		t.Fatalf("got %d groups, want 5", len(out))
+	}
+	for i, r := range out {
+		dealer := []string{"D1", "D2", "D3", "D4", "D5"}[i]
+		if r["dealer"] != dealer || r["logins"] != int64(6) || r["sessions"] != int64(3) {
+			t.Errorf("group %d = %v, want %s with 6 logins in 3 sessions", i, r, dealer)
+		}
+		if r["first"] != int64(100-25-i) {
+			t.Errorf("%s first = %v, want %d", dealer, r["first"], 100-25-i)
+		}
+	}
+}
+
+func TestAggregateMergesOpenWindowsAcrossRuns(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	const step = `
+name: daily
+group_by: [dealer]
+time_field: at
+interval: 24h
+events: drop
+aggregates:
+  logins: count(*)
+  sessions: count_distinct(session)
+`
+	day := func(d, h int) int64 {
+		return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC).Unix()
+	}
+	logins := func(out []pipeline.DataRecord) map[int]int64 {
+		counts := make(map[int]int64)
+		for _, r := range out {
+			counts[r[fieldWindowStart].(time.Time).Day()] = r["logins"].(int64)
+		}
+		return counts
+	}
+
+	first := testAggregate(t, statePath, step)
+	out := runAggregate(t, first,
+		pipeline.DataRecord{"dealer": "D1", "session": "S1", "at": day(17, 9)},
+		pipeline.DataRecord{"dealer": "D1", "session": "S2", "at": day(18, 9)},
+		pipeline.DataRecord{"dealer": "D1", "session": "S3", "at": day(18, 10)},
+	)
+	if got := logins(out); len(got) != 2 || got[17] != 1 || got[18] != 2 {
+		t.Fatalf("first run logins by day %v", got)
+	}
+	if len(first.open) != 1 {
+		t.Fatalf("%d groups left open, want the 18th only", len(first.open))
+	}
+
+	second := testAggregate(t, statePath, step)
+	out = runAggregate(t, second,
+		pipeline.DataRecord{"dealer": "D1", "session": "S3", "at": day(18, 23)},
+		pipeline.DataRecord{"dealer": "D1", "session": "S4", "at": day(17, 23)}, // the 17th closed
+		pipeline.DataRecord{"dealer": "D1", "session": "S5", "at": day(19, 1)},
+	)
+	if got := logins(out); len(got) != 2 || got[18] != 3 || got[19] != 1 {
+		t.Fatalf("second run logins by day %v, want the 18th merged with the first run", got)
+	}
+	for _, r := range out {
+		if r[fieldWindowStart].(time.Time).Day() == 18 && r["sessions"] != int64(2) {
+			t.Errorf("sessions on the 18th = %v, want 2", r["sessions"])
+		}
+	}
+	if second.late != 1 {
+		t.Errorf("%d late records, want 1", second.late)
+	}
+	if len(second.open) != 1 {
+		t.Errorf("%d groups left open, want the 19th only", len(second.open))
+	}
+}
+
+func TestAggregateLatenessAndTotals(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	const windowed = `
+name: hourly
+time_field: at
+interval: 1h
+lateness: 30m
+events: drop
+aggregates: {n: count(*)}
+`
+	const totals = `
+name: totals
+group_by: [dealer]
+aggregates: {n: count(*)}
+`
+	t0 := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC).Unix()
+
+	a := testAggregate(t, statePath, windowed)
+	runAggregate(t, a, pipeline.DataRecord{"at": t0 + 600}, pipeline.DataRecord{"at": t0 + 3600 + 1200})
+	if len(a.open) != 2 {
+		t.Fatalf("%d windows open, want 2 within the lateness", len(a.open))
+	}
+	b := testAggregate(t, statePath, totals)
+	runAggregate(t, b, pipeline.DataRecord{"dealer": "D1"}, pipeline.DataRecord{"dealer": "D1"})
+
+	// both steps share the state file under their own keys
+	a = testAggregate(t, statePath, windowed)
+	out := runAggregate(t, a, pipeline.DataRecord{"at": t0 + 1800})
+	if len(out) != 2 || out[0]["n"] != int64(2) || a.late != 0 {
+		t.Fatalf("got %v, want the late record counted in the first hour", out)
+	}
+	b = testAggregate(t, statePath, totals)
+	out = runAggregate(t, b, pipeline.DataRecord{"dealer": "D1"}, pipeline.DataRecord{"dealer": "D2"})
+	if len(out) != 2 || out[0]["n"] != int64(3) || out[1]["n"] != int64(1) {
+		t.Fatalf("got %v, want cumulative totals", out)
+	}
+}
@@ -0,0 +1,383 @@
+package transform
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// checks runs data quality checks on the records passing through it, which
+// it passes on unchanged. The per-record checks count the records that
+// fail them as they go; once the input ends, every check is evaluated and
+// the results are recorded in the run metadata. A failed check of severity
+// fail then fails the run, before anything is committed, with an error the
+// orchestrator does not retry.
+type checks struct {
+	name   string
+	checks []*check
+	report *checkReport
+}
+
+type check struct {
+	kind     string
+	severity string
+	fields   []string
+	result   *checkResult
+
+	values     map[string]bool
+	min, max   interface{}
+	pattern    *regexp.Regexp
+	maxAge     time.Duration
+	unit       time.Duration
+	maxRate    float64
+	timeBounds bool
+	seen       map[digest]struct{}
+	failures   int64
+	nulls      int64
+	latest     time.Time
+	haveLatest bool
+}
+
+// checkReport is recorded in the run metadata under checks.<name>.
+type checkReport struct {
+	Records int64          `json:"records"`
+	Passed  bool           `json:"passed"`
+	Results []*checkResult `json:"results"`
+}
+
+type checkResult struct {
+	Name     string `json:"name"`
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Passed   bool   `json:"passed"`
+	Failures int64  `json:"failures,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+func (c *Chain) newChecks(tc config.TransformationConfig) (*checks, error) {
+	s := &checks{name: tc.Name, report: &checkReport{}}
+	if s.name == "" {
+		s.name = "checks"
+	}
+	if _, dup := c.checks[s.name]; dup {
+		return nil, fmt.Errorf("duplicate check name %s", s.name)
+	}
+	if len(tc.Checks) == 0 {
+		return nil, fmt.Errorf("check requires checks")
+	}
+
+	names := make(map[string]bool, len(tc.Checks))
+	for i, cc := range tc.Checks {
+		ch, err := newCheck(cc)
+		if err != nil {
+			return nil, fmt.Errorf("checks[%d]: %w", i, err)
+		}
+		if names[ch.result.Name] {
+			return nil, fmt.Errorf("checks[%d]: duplicate check name %s", i, ch.result.Name)
+		}
+		names[ch.result.Name] = true
+		s.checks = append(s.checks, ch)
+		s.report.Results = append(s.report.Results, ch.result)
+	}
+
+	c.checks[s.name] = s.report
+	return s, nil
+}
+
+func newCheck(cc config.CheckConfig) (*check, error) {
+	ch := &check{kind: cc.Check, severity: cc.Severity, fields: cc.Fields(), unit: time.Second}
+	if ch.severity == "" {
+		ch.severity = "fail"
+	}
+	if ch.severity != "fail" && ch.severity != "warn" {
+		return nil, fmt.Errorf("check severity must be fail or warn, got %q", cc.Severity)
+	}
+
+	name := cc.Name
+	if name == "" {
+		name = cc.Check
+		if len(ch.fields) > 0 {
+			name += "(" + strings.Join(ch.fields, ", ") + ")"
+		}
+	}
+	ch.result = &checkResult{Name: name, Check: cc.Check, Severity: ch.severity}
+
+	if cc.Check != "row_count" && len(ch.fields) == 0 {
+		return nil, fmt.Errorf("%s check requires column or columns", cc.Check)
+	}
+	single := func() error {
+		if len(ch.fields) != 1 {
+			return fmt.Errorf("%s check takes a single column", cc.Check)
+		}
+		return nil
+	}
+
+	switch cc.Check {
+	case "not_null":
+	case "unique":
+		ch.seen = make(map[digest]struct{})
+	case "accepted_values":
+		if len(cc.Values) == 0 {
+			return nil, fmt.Errorf("accepted_values check requires values")
+		}
+		ch.values = make(map[string]bool, len(cc.Values))
+		for _, v := range cc.Values {
+			ch.values[canonical(v)] = true
+		}
+	case "range":
+		if cc.Min == nil && cc.Max == nil {
+			return nil, fmt.Errorf("range check requires min or max")
+		}
+		ch.min, ch.max = rangeBound(cc.Min), rangeBound(cc.Max)
+		_, minTime := ch.min.(time.Time)
+		_, maxTime := ch.max.(time.Time)
+		ch.timeBounds = minTime || maxTime
+	case "regex":
+		re, err := regexp.Compile(cc.Pattern)
+		if err != nil || cc.Pattern == "" {
+			return nil, fmt.Errorf("regex check requires a valid pattern: %q", cc.Pattern)
+		}
+		ch.pattern = re
+	case "row_count":
+		if cc.Min == nil && cc.Max == nil {
+			return nil, fmt.Errorf("row_count check requires min or max")
+		}
+		for _, bound := range []interface{}{cc.Min, cc.Max} {
+			if bound == nil {
+				continue
+			}
+			if _, err := strconv.ParseInt(canonical(bound), 10, 64); err != nil {
+				return nil, fmt.Errorf("row_count bounds must be whole numbers, got %v", bound)
+			}
+		}
+		ch.min, ch.max = cc.Min, cc.Max
+	case "freshness":
+		if err := single(); err != nil {
+			return nil, err
+		}
+		if cc.MaxAge <= 0 {
+			return nil, fmt.Errorf("freshness check requires max_age")
+		}
+		ch.maxAge = cc.MaxAge
+		if cc.From != "" {
+			unit, ok := epochUnits[cc.From]
+			if !ok {
+				return nil, fmt.Errorf("freshness from must be epoch_seconds, epoch_millis or epoch_micros, got %q", cc.From)
+			}
+			ch.unit = unit
+		}
+	case "null_rate":
+		if err := single(); err != nil {
+			return nil, err
+		}
+		if cc.MaxRate == nil || *cc.MaxRate < 0 || *cc.MaxRate > 1 {
+			return nil, fmt.Errorf("null_rate check requires max_rate between 0 and 1")
+		}
+		ch.maxRate = *cc.MaxRate
+	case "":
+		return nil, fmt.Errorf("check type is required")
+	default:
+		return nil, fmt.Errorf("unsupported check: %s", cc.Check)
+	}
+	return ch, nil
+}
+
+// rangeBound reads a bound of a range check. Dates and RFC 3339 times are
+// compared as times, anything else as compareValues does.
+func rangeBound(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		if t, err := parseBound(s); err == nil {
+			return t
+		}
+	}
+	return v
+}
+
+func (s *checks) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	s.report.Records++
+	for _, ch := range s.checks {
+		if err := ch.observe(record); err != nil {
+			return nil, fmt.Errorf("check %s: %w", ch.result.Name, err)
+		}
+	}
+	return record, nil
+}
+
+// observe counts record against the check.
+func (ch *check) observe(record pipeline.DataRecord) error {
+	switch ch.kind {
+	case "not_null":
+		for _, field := range ch.fields {
+			if record[field] == nil {
+				ch.failures++
+				return nil
+			}
+		}
+	case "unique":
+		values := make([]interface{}, len(ch.fields))
+		for i, field := range ch.fields {
+			if values[i] = record[field]; values[i] == nil {
+				return nil
+			}
+		}
+		k := digestOf(joinKey(values))
+		if _, dup := ch.seen[k]; dup {
+			ch.failures++
+			return nil
+		}
+		ch.seen[k] = struct{}{}
+	case "accepted_values", "range", "regex":
+		for _, field := range ch.fields {
+			if v := record[field]; v != nil && !ch.accepts(v) {
+				ch.failures++
+				return nil
+			}
+		}
+	case "freshness":
+		v := record[ch.fields[0]]
+		if v == nil {
+			return nil
+		}
+		t, err := ch.time(v)
+		if err != nil {
+			return err
+		}
+		if !ch.haveLatest || t.After(ch.latest) {
+			ch.latest, ch.haveLatest = t, true
+		}
+	case "null_rate":
+		if record[ch.fields[0]] == nil {
+			ch.nulls++
+		}
+	}
+	return nil
+}
+
+// time reads a freshness value: a time, a datetime string without a zone
+// taken as UTC, or a count of units since the Unix epoch.
+func (ch *check) time(v interface{}) (time.Time, error) {
+	if text, ok := v.(string); ok {
+		for _, layout := range datetimeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return fieldTime(v, ch.unit)
+}
+
+func (ch *check) accepts(v interface{}) bool {
+	switch ch.kind {
+	case "accepted_values":
+		return ch.values[canonical(v)]
+	case "range":
+		if ch.timeBounds {
+			// Datetime strings would otherwise compare as text, so
+			// that 2026-12-31 10:00 is not after 2026-12-31T00:00:00Z.
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if s, ok := v.(string); ok {
+				t, err := ch.time(s)
+				if err != nil {
+					return false
+				}
+				v = t
+			}
+		}
+		if ch.min != nil && compareValues(v, ch.min) < 0 {
+			return false
+		}
+		return ch.max == nil || compareValues(v, ch.max) <= 0
+	case "regex":
+		return ch.pattern.MatchString(canonical(v))
+	}
+	return true
+}
+
+// evaluate fills in the result of the check for a run of the given number
+// of records.
+func (ch *check) evaluate(records int64) {
+	r := ch.result
+	r.Failures = ch.failures
+	r.Passed = ch.failures == 0
+	fields := strings.Join(ch.fields, ", ")
+
+	switch ch.kind {
+	case "not_null":
+		r.Detail = fmt.Sprintf("%d of %d records have a null %s", ch.failures, records, fields)
+	case "unique":
+		r.Detail = fmt.Sprintf("%d of %d records repeat a value of %s", ch.failures, records, fields)
+	case "accepted_values":
+		r.Detail = fmt.Sprintf("%d of %d records have a value of %s that is not accepted", ch.failures, records, fields)
+	case "range":
+		r.Detail = fmt.Sprintf("%d of %d records have a value of %s outside [%s, %s]", ch.failures, records, fields, boundText(ch.min), boundText(ch.max))
+	case "regex":
+		r.Detail = fmt.Sprintf("%d of %d records have a value of %s not matching %s", ch.failures, records, fields, ch.pattern)
+	case "row_count":
+		r.Detail = fmt.Sprintf("%d records, expected [%s, %s]", records, boundText(ch.min), boundText(ch.max))
+		r.Passed = (ch.min == nil || compareValues(records, ch.min) >= 0) && (ch.max == nil || compareValues(records, ch.max) <= 0)
+	case "freshness":
+		if !ch.haveLatest {
+			r.Passed = false
+			r.Detail = fmt.Sprintf("no values of %s", fields)
+			break
+		}
+		age := time.Since(ch.latest).Truncate(time.Second)
+		r.Passed = age <= ch.maxAge
+		r.Detail = fmt.Sprintf("latest %s is %s old, at most %s allowed", fields, age, ch.maxAge)
+	case "null_rate":
+		rate := 0.0
+		if records > 0 {
+			rate = float64(ch.nulls) / float64(records)
+		}
+		r.Failures = ch.nulls
+		r.Passed = rate <= ch.maxRate
+		r.Detail = fmt.Sprintf("%.2f%% of %s is null, at most %.2f%% allowed", rate*100, fields, ch.maxRate*100)
+	}
+}
+
+func boundText(v interface{}) string {
+	switch b := v.(type) {
+	case nil:
+		return "-"
+	case time.Time:
+		return b.Format(time.RFC3339)
+	default:
+		return canonical(b)
+	}
+}
+
+// flush evaluates the checks once the input has ended.
+func (s *checks) flush(emit func(pipeline.DataRecord) bool) error {
+	s.report.Passed = true
+	var failed []string
+	passed := 0
+	for _, ch := range s.checks {
+		ch.evaluate(s.report.Records)
+		r := ch.result
+		switch {
+		case r.Passed:
+			passed++
+		case r.Severity == "warn":
+			log.Printf("Check %s warning: %s", r.Name, r.Detail)
+		default:
+			log.Printf("Check %s failed: %s", r.Name, r.Detail)
+			failed = append(failed, r.Name)
+			s.report.Passed = false
+		}
+	}
+	log.Printf("Checks %s: %d of %d passed on %d records", s.name, passed, len(s.checks), s.report.Records)
+
+	if len(failed) > 0 {
+		// The same records would fail the same checks again.
+		return pipeline.Permanent(fmt.Errorf("checks %s failed: %s", s.name, strings.Join(failed, ", ")))
+	}
+	return nil
+}
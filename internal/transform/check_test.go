@@ -0,0 +1,271 @@
+package transform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// runChecks passes records through a check step and evaluates it.
+func runChecks(t *testing.T, checks []config.CheckConfig, records ...pipeline.DataRecord) (*checks, error) {
+	t.Helper()
+	c := &Chain{checks: make(map[string]*checkReport)}
+	s, err := c.newChecks(config.TransformationConfig{Checks: checks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		out, err := s.apply(r)
+		if err != nil {
+			return s, err
+		}
+		if out == nil {
+			t.Fatal("a check dropped a record")
+		}
+	}
+	return s, s.flush(func(pipeline.DataRecord) bool { return true })
+}
+
+func TestCheckNullRate(t *testing.T) {
+	rate := 0.25
+	nullRate := []config.CheckConfig{{Check: "null_rate", Column: "device", MaxRate: &rate}}
+
+	s, err := runChecks(t, nullRate,
+		pipeline.DataRecord{"device": "a"}, pipeline.DataRecord{"device": nil},
+		pipeline.DataRecord{"device": "b"}, pipeline.DataRecord{})
+	if err == nil || !pipeline.IsPermanent(err) {
+		t.Fatalf("2 nulls in 4 gave %v, want a permanent error", err)
+	}
+	r := s.report.Results[0]
+	if r.Passed || r.Failures != 2 || r.Detail != "50.00% of device is null, at most 25.00% allowed" {
+		t.Errorf("result %+v", r)
+	}
+
+	s, err = runChecks(t, nullRate,
+		pipeline.DataRecord{"device": "a"}, pipeline.DataRecord{"device": nil},
+		pipeline.DataRecord{"device": "b"}, pipeline.DataRecord{"device": "c"})
+	if err != nil || !s.report.Passed || s.report.Records != 4 {
+		t.Errorf("1 null in 4 gave %v, report %+v", err, s.report)
+	}
+
+	// no records, no nulls
+	if _, err := runChecks(t, nullRate); err != nil {
+		t.Errorf("an empty run gave %v", err)
+	}
+
+	for _, r := range []*float64{nil, ptr(-0.1), ptr(1.5)} {
+		c := &Chain{checks: make(map[string]*checkReport)}
+		if _, err := c.newChecks(config.TransformationConfig{Checks: []config.CheckConfig{{Check: "null_rate", Column: "device", MaxRate: r}}}); err == nil {
+			t.Errorf("max_rate %v accepted", r)
+		}
+	}
+}
+
+func ptr(f float64) *float64 { return &f }
+
+func TestCheckFreshness(t *testing.T) {
+	recent := time.Now().Add(-30 * time.Minute)
+	stale := time.Now().Add(-3 * time.Hour)
+
+	for _, tc := range []struct {
+		from   string
+		values []interface{}
+		passed bool
+	}{
+		{"", []interface{}{stale.Unix(), recent.Unix()}, true},
+		{"epoch_seconds", []interface{}{float64(stale.Unix())}, false},
+		{"epoch_millis", []interface{}{recent.UnixMilli(), nil, stale.UnixMilli()}, true},
+		{"epoch_millis", []interface{}{stale.UnixMilli()}, false},
+		// seconds read as milliseconds are from 1970
+		{"epoch_millis", []interface{}{recent.Unix()}, false},
+		{"epoch_micros", []interface{}{recent.UnixMicro()}, true},
+		{"epoch_micros", []interface{}{recent.UnixMilli()}, false},
+		{"", []interface{}{recent.UTC().Format("2006-01-02 15:04:05")}, true},
+		{"", []interface{}{recent}, true},
+		{"", []interface{}{nil}, false},
+	} {
+		records := make([]pipeline.DataRecord, len(tc.values))
+		for i, v := range tc.values {
+			records[i] = pipeline.DataRecord{"login_ts": v}
+		}
+		s, err := runChecks(t, []config.CheckConfig{{Check: "freshness", Column: "login_ts", MaxAge: time.Hour, From: tc.from}}, records...)
+		if r := s.report.Results[0]; r.Passed != tc.passed || (err == nil) != tc.passed {
+			t.Errorf("from %q of %v: passed %v, %v (%s), want passed %v", tc.from, tc.values, r.Passed, err, r.Detail, tc.passed)
+		}
+	}
+
+	_, err := runChecks(t, []config.CheckConfig{{Check: "freshness", Column: "login_ts", MaxAge: time.Hour}},
+		pipeline.DataRecord{"login_ts": "yesterday"})
+	if err == nil || !strings.Contains(err.Error(), "check freshness(login_ts)") {
+		t.Errorf("unreadable time gave %v", err)
+	}
+
+	for _, cc := range []config.CheckConfig{
+		{Check: "freshness", Column: "login_ts"},
+		{Check: "freshness", Column: "login_ts", MaxAge: time.Hour, From: "epoch_nanos"},
+		{Check: "freshness", Columns: []string{"a", "b"}, MaxAge: time.Hour},
+	} {
+		c := &Chain{checks: make(map[string]*checkReport)}
+		if _, err := c.newChecks(config.TransformationConfig{Checks: []config.CheckConfig{cc}}); err == nil {
+			t.Errorf("%+v accepted", cc)
+		}
+	}
+}
+
+func TestCheckRangeDates(t *testing.T) {
+	dates := []config.CheckConfig{{Check: "range", Column: "at", Min: "2026-01-01", Max: "2026-12-31"}}
+	for _, tc := range []struct {
+		v      interface{}
+		passed bool
+	}{
+		{time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 12, 31, 0, 0, 1, 0, time.UTC), false},
+		{time.Date(2025, 12, 31, 23, 0, 0, 0, time.UTC), false},
+		// 2026-01-01 03:00 in Kolkata is still 2025 in UTC
+		{time.Date(2026, 1, 1, 3, 0, 0, 0, time.FixedZone("IST", 19800)), false},
+		{"2026-06-01", true},
+		{[]byte("2026-06-01T10:00:00Z"), true},
+		{"2026-12-31 10:00:00", false},
+		{"2027-01-01", false},
+		{"not a date", false},
+		{nil, true},
+	} {
+		s, _ := runChecks(t, dates, pipeline.DataRecord{"at": tc.v})
+		if r := s.report.Results[0]; r.Passed != tc.passed {
+			t.Errorf("%v: passed %v (%s), want %v", tc.v, r.Passed, r.Detail, tc.passed)
+		}
+	}
+
+	s, _ := runChecks(t, []config.CheckConfig{{Check: "range", Column: "at", Min: "2026-10-17T09:30:00+05:30"}},
+		pipeline.DataRecord{"at": time.Date(2026, 10, 17, 4, 0, 0, 0, time.UTC)},
+		pipeline.DataRecord{"at": time.Date(2026, 10, 17, 3, 59, 0, 0, time.UTC)})
+	if r := s.report.Results[0]; r.Failures != 1 || r.Detail != "1 of 2 records have a value of at outside [2026-10-17T09:30:00+05:30, -]" {
+		t.Errorf("result %+v", r)
+	}
+
+	// bounds that are not times compare as numbers
+	s, _ = runChecks(t, []config.CheckConfig{{Check: "range", Column: "n", Min: 0, Max: 10}},
+		pipeline.DataRecord{"n": 9}, pipeline.DataRecord{"n": "10"}, pipeline.DataRecord{"n": 10.5})
+	if r := s.report.Results[0]; r.Failures != 1 {
+		t.Errorf("result %+v", r)
+	}
+}
+
+// checkExtractor emits records and notes whether the run committed it.
+type checkExtractor struct {
+	records   []pipeline.DataRecord
+	committed bool
+}
+
+func (e *checkExtractor) Init(ctx context.Context, cfg *config.PipelineConfig) error { return nil }
+
+func (e *checkExtractor) Extract(ctx context.Context) (<-chan pipeline.DataRecord, <-chan error) {
+	out := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errs)
+		for _, r := range e.records {
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+func (e *checkExtractor) Commit(ctx context.Context) error {
+	e.committed = true
+	return nil
+}
+
+func (e *checkExtractor) Close() error { return nil }
+
+type checkLoader struct{ loaded int }
+
+func (l *checkLoader) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	l.loaded = 0
+	return nil
+}
+
+func (l *checkLoader) Load(ctx context.Context, input <-chan pipeline.DataRecord) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-input:
+			if !ok {
+				return nil
+			}
+			l.loaded++
+		}
+	}
+}
+
+func (l *checkLoader) Close() error { return nil }
+
+func TestCheckSeverity(t *testing.T) {
+	records := []pipeline.DataRecord{
+		{"dealer": "D1", "flag": "I"},
+		{"dealer": nil, "flag": "X"},
+	}
+	execute := func(severity string) (*checkExtractor, *checkLoader, error, string) {
+		dir := t.TempDir()
+		cfg := &config.PipelineConfig{Transformations: []config.TransformationConfig{{
+			Type: "check",
+			Name: "login_quality",
+			Checks: []config.CheckConfig{
+				{Check: "not_null", Column: "dealer", Severity: "warn"},
+				{Check: "accepted_values", Column: "flag", Values: []interface{}{"I", "O"}, Severity: severity},
+			},
+		}}}
+		cfg.Pipeline.Name = "test"
+		cfg.Pipeline.Retries = 2
+		cfg.Pipeline.RunsDir = dir
+
+		e := &checkExtractor{records: records}
+		l := &checkLoader{}
+		err := pipeline.NewOrchestrator(cfg, e, NewChain(), l).Execute(context.Background())
+
+		paths, _ := filepath.Glob(filepath.Join(dir, "test", "*.json"))
+		if len(paths) != 1 {
+			t.Fatalf("%d run metadata files", len(paths))
+		}
+		data, rerr := os.ReadFile(paths[0])
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		return e, l, err, string(data)
+	}
+
+	e, l, err, run := execute("warn")
+	if err != nil {
+		t.Fatalf("warnings failed the run: %v", err)
+	}
+	if !e.committed || l.loaded != 2 {
+		t.Errorf("committed %v, loaded %d", e.committed, l.loaded)
+	}
+	if !strings.Contains(run, `"passed": true`) || !strings.Contains(run, `"failures": 1`) {
+		t.Errorf("run metadata %s", run)
+	}
+
+	e, _, err, run = execute("fail")
+	if err == nil || !pipeline.IsPermanent(err) || !strings.Contains(err.Error(), "checks login_quality failed: accepted_values(flag)") {
+		t.Fatalf("got %v, want the failed check", err)
+	}
+	if e.committed {
+		t.Error("the extractor committed after a check failed")
+	}
+	if !strings.Contains(run, `"attempts": 1`) || !strings.Contains(run, `"status": "failed"`) {
+		t.Errorf("run metadata %s", run)
+	}
+}
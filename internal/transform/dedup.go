@@ -0,0 +1,417 @@
+package transform
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync/atomic"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const defaultDedupWindow = 1000000
+
+// dedup drops records whose key was already seen in the run, such as a login
+// event read from both the history and the log table, or from two shards
+// after a failover.
+//
+// With keep: first and no order_by, records pass through as they arrive and
+// the keys of the last window distinct records are remembered; older keys
+// are forgotten, or moved to a set on disk when spill_dir is set so that the
+// dedup stays exact. Otherwise one record per key is held until the input
+// ends: the one with the lowest order_by value for keep: first, the highest
+// for keep: latest, or the last to arrive for keep: latest without order_by.
+// At most window keys are held in memory; beyond that they move to
+// spill_dir, and without one the run fails.
+type dedup struct {
+	name     string
+	key      []string
+	orderBy  string
+	latest   bool
+	window   int
+	spillDir string
+	stats    *dedupStats
+
+	// keys seen by keep: first without order_by, oldest first in recent
+	seen   map[digest]struct{}
+	recent []digest
+	next   int
+
+	held   map[digest]*heldRecord
+	seq    int64
+	onDisk bool
+
+	spill *dedupSpill
+}
+
+type heldRecord struct {
+	seq    int64
+	record pipeline.DataRecord
+}
+
+// dedupStats are the counts reported in the run metadata.
+type dedupStats struct {
+	Records atomic.Int64
+	Dropped atomic.Int64
+	Evicted atomic.Int64
+	Spilled atomic.Int64
+}
+
+func (s *dedupStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int64{
+		"records": s.Records.Load(),
+		"dropped": s.Dropped.Load(),
+		"evicted": s.Evicted.Load(),
+		"spilled": s.Spilled.Load(),
+	})
+}
+
+func (c *Chain) newDedup(tc config.TransformationConfig) (*dedup, error) {
+	if len(tc.Key) == 0 {
+		return nil, fmt.Errorf("dedup requires key")
+	}
+	d := &dedup{
+		name:     tc.Name,
+		key:      tc.Key,
+		orderBy:  tc.OrderBy,
+		window:   tc.Window,
+		spillDir: tc.SpillDir,
+		stats:    &dedupStats{},
+	}
+	if d.name == "" {
+		d.name = "dedup"
+	}
+	if _, dup := c.dedups[d.name]; dup {
+		return nil, fmt.Errorf("duplicate dedup name %s", d.name)
+	}
+	switch tc.Keep {
+	case "", "first":
+	case "latest":
+		d.latest = true
+	default:
+		return nil, fmt.Errorf("dedup keep must be first or latest, got %q", tc.Keep)
+	}
+	if d.window < 0 {
+		return nil, fmt.Errorf("dedup window must not be negative")
+	}
+	if d.window == 0 {
+		d.window = defaultDedupWindow
+	}
+
+	if d.buffered() {
+		d.held = make(map[digest]*heldRecord)
+	} else {
+		d.seen = make(map[digest]struct{})
+	}
+	c.dedups[d.name] = d.stats
+	return d, nil
+}
+
+// buffered reports whether records are held until the input ends.
+func (d *dedup) buffered() bool {
+	return d.latest || d.orderBy != ""
+}
+
+// keyOf returns the digest of the key of record, or false when a key field
+// is null: a record without a full key is not a duplicate of any other.
+func (d *dedup) keyOf(record pipeline.DataRecord) (digest, bool) {
+	values := make([]interface{}, len(d.key))
+	for i, field := range d.key {
+		if values[i] = record[field]; values[i] == nil {
+			return digest{}, false
+		}
+	}
+	return digestOf(joinKey(values)), true
+}
+
+func (d *dedup) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	d.stats.Records.Add(1)
+	k, ok := d.keyOf(record)
+	if !ok {
+		return record, nil
+	}
+
+	if d.buffered() {
+		if err := d.hold(k, record); err != nil {
+			return nil, fmt.Errorf("dedup %s: %w", d.name, err)
+		}
+		return nil, nil
+	}
+
+	seen, err := d.remember(k)
+	if err != nil {
+		return nil, fmt.Errorf("dedup %s: %w", d.name, err)
+	}
+	if seen {
+		d.stats.Dropped.Add(1)
+		return nil, nil
+	}
+	return record, nil
+}
+
+// remember adds k to the seen keys and reports whether it was already there.
+func (d *dedup) remember(k digest) (bool, error) {
+	if _, ok := d.seen[k]; ok {
+		return true, nil
+	}
+	if d.spill != nil {
+		if found, err := d.spill.hasKey(k); err != nil || found {
+			return found, err
+		}
+	}
+
+	d.seen[k] = struct{}{}
+	if len(d.recent) < d.window {
+		d.recent = append(d.recent, k)
+		return false, nil
+	}
+	oldest := d.recent[d.next]
+	d.recent[d.next] = k
+	d.next = (d.next + 1) % d.window
+	delete(d.seen, oldest)
+
+	if d.spillDir == "" {
+		d.stats.Evicted.Add(1)
+		return false, nil
+	}
+	if d.spill == nil {
+		var err error
+		if d.spill, err = openDedupSpill(d.spillDir); err != nil {
+			return false, err
+		}
+	}
+	d.stats.Spilled.Add(1)
+	return false, d.spill.addKey(oldest)
+}
+
+// hold keeps record if it is the first of its key or wins over the record
+// held so far.
+func (d *dedup) hold(k digest, record pipeline.DataRecord) error {
+	if d.onDisk {
+		return d.holdOnDisk(k, record)
+	}
+
+	if h, ok := d.held[k]; ok {
+		d.stats.Dropped.Add(1)
+		if d.wins(record, h.record) {
+			h.record = record
+		}
+		return nil
+	}
+	d.seq++
+	d.held[k] = &heldRecord{seq: d.seq, record: record}
+	if len(d.held) <= d.window {
+		return nil
+	}
+
+	if d.spillDir == "" {
+		return fmt.Errorf("more than %d keys held, raise window or set spill_dir", d.window)
+	}
+	var err error
+	if d.spill, err = openDedupSpill(d.spillDir); err != nil {
+		return err
+	}
+	log.Printf("Dedup %s: more than %d keys held, spilling to %s", d.name, d.window, d.spillDir)
+	for k, h := range d.held {
+		if err := d.spill.put(k, h.seq, h.record); err != nil {
+			return err
+		}
+	}
+	d.stats.Spilled.Add(int64(len(d.held)))
+	d.held = nil
+	d.onDisk = true
+	return nil
+}
+
+func (d *dedup) holdOnDisk(k digest, record pipeline.DataRecord) error {
+	current, found, err := d.spill.get(k)
+	if err != nil {
+		return err
+	}
+	if !found {
+		d.seq++
+		d.stats.Spilled.Add(1)
+		return d.spill.put(k, d.seq, record)
+	}
+	d.stats.Dropped.Add(1)
+	if d.wins(record, current) {
+		return d.spill.replace(k, record)
+	}
+	return nil
+}
+
+// wins reports whether candidate replaces current as the record kept for
+// their key. A null order_by value loses to any other, and ties keep the
+// record seen first.
+func (d *dedup) wins(candidate, current pipeline.DataRecord) bool {
+	if d.orderBy == "" {
+		return d.latest
+	}
+	a, b := candidate[d.orderBy], current[d.orderBy]
+	switch {
+	case a == nil:
+		return false
+	case b == nil:
+		return true
+	}
+	if d.latest {
+		return compareValues(a, b) > 0
+	}
+	return compareValues(a, b) < 0
+}
+
+// flush emits the held records in the order their keys were first seen.
+func (d *dedup) flush(emit func(pipeline.DataRecord) bool) error {
+	if !d.buffered() {
+		return nil
+	}
+	if d.onDisk {
+		if err := d.spill.each(emit); err != nil {
+			return fmt.Errorf("dedup %s: %w", d.name, err)
+		}
+		return nil
+	}
+
+	held := make([]*heldRecord, 0, len(d.held))
+	for _, h := range d.held {
+		held = append(held, h)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].seq < held[j].seq })
+	d.held = make(map[digest]*heldRecord)
+	for _, h := range held {
+		if !emit(h.record) {
+			break
+		}
+	}
+	return nil
+}
+
+func (d *dedup) report() {
+	log.Printf("Dedup %s: dropped %d duplicates of %d records", d.name, d.stats.Dropped.Load(), d.stats.Records.Load())
+	if evicted := d.stats.Evicted.Load(); evicted > 0 {
+		log.Printf("Dedup %s: %d keys were forgotten beyond the window of %d, set spill_dir for an exact dedup", d.name, evicted, d.window)
+	}
+}
+
+func (d *dedup) Close() error {
+	if d.spill == nil {
+		return nil
+	}
+	err := d.spill.close()
+	d.spill = nil
+	return err
+}
+
+// dedupSpill keeps the keys and held records that did not fit in memory.
+type dedupSpill struct {
+	*spillFile
+
+	hasKeyStmt  *sql.Stmt
+	addKeyStmt  *sql.Stmt
+	getStmt     *sql.Stmt
+	putStmt     *sql.Stmt
+	replaceStmt *sql.Stmt
+}
+
+func openDedupSpill(dir string) (*dedupSpill, error) {
+	f, err := openSpillFile(dir, "dedup",
+		"CREATE TABLE seen (k BLOB PRIMARY KEY) WITHOUT ROWID",
+		"CREATE TABLE held (k BLOB PRIMARY KEY, seq INTEGER NOT NULL, rec BLOB NOT NULL) WITHOUT ROWID")
+	if err != nil {
+		return nil, err
+	}
+	s := &dedupSpill{spillFile: f}
+	err = f.prepare(map[**sql.Stmt]string{
+		&s.hasKeyStmt:  "SELECT 1 FROM seen WHERE k = ?",
+		&s.addKeyStmt:  "INSERT INTO seen (k) VALUES (?)",
+		&s.getStmt:     "SELECT rec FROM held WHERE k = ?",
+		&s.putStmt:     "INSERT INTO held (k, seq, rec) VALUES (?, ?, ?)",
+		&s.replaceStmt: "UPDATE held SET rec = ? WHERE k = ?",
+	})
+	if err != nil {
+		f.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *dedupSpill) hasKey(k digest) (bool, error) {
+	var one int
+	switch err := s.hasKeyStmt.QueryRow(k[:]).Scan(&one); err {
+	case nil:
+		return true, nil
+	case sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, fmt.Errorf("spill lookup failed: %w", err)
+	}
+}
+
+func (s *dedupSpill) addKey(k digest) error {
+	if _, err := s.addKeyStmt.Exec(k[:]); err != nil {
+		return fmt.Errorf("spill write failed: %w", err)
+	}
+	return nil
+}
+
+func (s *dedupSpill) get(k digest) (pipeline.DataRecord, bool, error) {
+	var data []byte
+	switch err := s.getStmt.QueryRow(k[:]).Scan(&data); err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("spill lookup failed: %w", err)
+	}
+	var record pipeline.DataRecord
+	err := decodeSpilled(data, &record)
+	return record, true, err
+}
+
+func (s *dedupSpill) put(k digest, seq int64, record pipeline.DataRecord) error {
+	data, err := encodeSpilled(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.putStmt.Exec(k[:], seq, data); err != nil {
+		return fmt.Errorf("spill write failed: %w", err)
+	}
+	return nil
+}
+
+func (s *dedupSpill) replace(k digest, record pipeline.DataRecord) error {
+	data, err := encodeSpilled(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.replaceStmt.Exec(data, k[:]); err != nil {
+		return fmt.Errorf("spill write failed: %w", err)
+	}
+	return nil
+}
+
+// each emits the held records in the order their keys were first seen.
+func (s *dedupSpill) each(emit func(pipeline.DataRecord) bool) error {
+	rows, err := s.tx.Query("SELECT rec FROM held ORDER BY seq")
+	if err != nil {
+		return fmt.Errorf("spill read failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("spill read failed: %w", err)
+		}
+		var record pipeline.DataRecord
+		if err := decodeSpilled(data, &record); err != nil {
+			return err
+		}
+		if !emit(record) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
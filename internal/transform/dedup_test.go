@@ -0,0 +1,50 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestDedupPassesNullKeys(t *testing.T) {
+	for _, keep := range []string{"first", "latest"} {
+		t.Run(keep, func(t *testing.T) {
+			c := &Chain{dedups: make(map[string]*dedupStats)}
+			d, err := c.newDedup(config.TransformationConfig{Key: []string{"dealer", "session"}, Keep: keep})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			records := []pipeline.DataRecord{
+				{"dealer": "D1", "session": nil, "n": 1},
+				{"dealer": "D1", "session": nil, "n": 2},
+				{"dealer": "D1", "session": "S1", "n": 3},
+				{"dealer": "D1", "session": "S1", "n": 4},
+			}
+			var out []pipeline.DataRecord
+			for _, r := range records {
+				kept, err := d.apply(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if kept != nil {
+					out = append(out, kept)
+				}
+			}
+			if err := d.flush(func(r pipeline.DataRecord) bool {
+				out = append(out, r)
+				return true
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(out) != 3 {
+				t.Fatalf("got %d records, want both session-less records and one of S1: %v", len(out), out)
+			}
+			if got := d.stats.Dropped.Load(); got != 1 {
+				t.Fatalf("dropped %d records, want 1", got)
+			}
+		})
+	}
+}
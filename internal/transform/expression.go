@@ -0,0 +1,129 @@
+package transform
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/expr"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// compile compiles an expression from the configuration. Errors name the
+// configuration line and, for syntax errors, the column in the expression.
+func compile(what string, e config.Expression) (*expr.Program, error) {
+	prog, err := expr.Compile(e.Source)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s %q: %w", e.Line, what, e.Source, err)
+	}
+	return prog, nil
+}
+
+// filter keeps the records for which the expression is true. Null counts as
+// false, so a record missing the field is dropped.
+type filter struct {
+	expr    config.Expression
+	prog    *expr.Program
+	dropped atomic.Int64
+}
+
+func newFilter(tc config.TransformationConfig) (*filter, error) {
+	if tc.Filter.Source == "" {
+		return nil, fmt.Errorf("filter transformation requires filter")
+	}
+	prog, err := compile("filter", tc.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{expr: tc.Filter, prog: prog}, nil
+}
+
+func (f *filter) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	ok, err := f.prog.Match(record)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: filter %q: %w", f.expr.Line, f.expr.Source, err)
+	}
+	if !ok {
+		f.dropped.Add(1)
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (f *filter) report() {
+	log.Printf("Filter %q dropped %d records", f.expr.Source, f.dropped.Load())
+}
+
+func (f *filter) parallel() bool { return true }
+
+// derive sets columns from expressions in order, so an expression can use
+// the columns derived before it.
+type derive struct {
+	columns []derivedColumn
+}
+
+type derivedColumn struct {
+	name string
+	expr config.Expression
+	prog *expr.Program
+}
+
+func newDerive(tc config.TransformationConfig) (*derive, error) {
+	if len(tc.Derive) == 0 {
+		return nil, fmt.Errorf("derive transformation requires derive")
+	}
+	d := &derive{}
+	for _, dv := range tc.Derive {
+		prog, err := compile("derive "+dv.Column, dv.Expr)
+		if err != nil {
+			return nil, err
+		}
+		d.columns = append(d.columns, derivedColumn{name: dv.Column, expr: dv.Expr, prog: prog})
+	}
+	return d, nil
+}
+
+func (d *derive) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	for _, c := range d.columns {
+		v, err := c.prog.Eval(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: derive %s: %w", c.expr.Line, c.name, err)
+		}
+		record[c.name] = v
+	}
+	return record, nil
+}
+
+func (d *derive) parallel() bool { return true }
+
+// addColumn sets a column to a constant. CURRENT_TIMESTAMP is the time the
+// record is transformed.
+type addColumn struct {
+	name  string
+	value interface{}
+	now   bool
+}
+
+func newAddColumn(tc config.TransformationConfig) (*addColumn, error) {
+	if tc.ColumnName == "" {
+		return nil, fmt.Errorf("add_column transformation requires column_name")
+	}
+	return &addColumn{
+		name:  tc.ColumnName,
+		value: tc.DefaultValue,
+		now:   tc.DefaultValue == "CURRENT_TIMESTAMP",
+	}, nil
+}
+
+func (a *addColumn) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	if a.now {
+		record[a.name] = time.Now().UTC()
+	} else {
+		record[a.name] = a.value
+	}
+	return record, nil
+}
+
+func (a *addColumn) parallel() bool { return true }
@@ -0,0 +1,363 @@
+package transform
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aniketwaliyan/etl-framework/internal/extract"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const defaultLookupCacheSize = 10000
+
+// lookup adds columns from a reference dataset, such as a dealer master, to
+// each record by key. In cache mode the whole dataset is read at Init
+// through the extractor of its source type. In lru mode rows are queried on
+// a miss and the most recently used ones, including misses, are kept.
+type lookup struct {
+	name   string
+	key    []string
+	fields []lookupField
+	onMiss string
+	policy *errorPolicy
+	stats  *lookupStats
+	rows   map[string]lookupRow
+	lru    *lruCache
+	db     *sql.DB
+	driver string
+	query  *sql.Stmt
+
+	// ctx is the context of the attempt the chain was initialised for,
+	// which steps are not given per record: lru queries stop once the
+	// attempt ends, and each attempt initialises the chain anew.
+	ctx context.Context
+}
+
+type lookupField struct {
+	column   string
+	source   string
+	fallback interface{}
+}
+
+// lookupRow holds the looked-up values in the order of lookup.fields. A nil
+// row is a cached miss.
+type lookupRow []interface{}
+
+// lookupStats are the counts reported in the run metadata.
+type lookupStats struct {
+	Rows    int64
+	Hits    atomic.Int64
+	Misses  atomic.Int64
+	Queries atomic.Int64
+}
+
+func (s *lookupStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int64{
+		"rows":    s.Rows,
+		"hits":    s.Hits.Load(),
+		"misses":  s.Misses.Load(),
+		"queries": s.Queries.Load(),
+	})
+}
+
+func (c *Chain) newLookup(ctx context.Context, cfg *config.PipelineConfig, tc config.TransformationConfig) (*lookup, error) {
+	if tc.Source == nil || tc.Source.Type == "" {
+		return nil, fmt.Errorf("lookup requires a source with a type")
+	}
+	if len(tc.Key) == 0 || len(tc.Fields) == 0 {
+		return nil, fmt.Errorf("lookup requires key and fields")
+	}
+	lookupKey := tc.LookupKey
+	if len(lookupKey) == 0 {
+		lookupKey = tc.Key
+	}
+	if len(lookupKey) != len(tc.Key) {
+		return nil, fmt.Errorf("lookup_key has %d fields but key has %d", len(lookupKey), len(tc.Key))
+	}
+
+	l := &lookup{
+		name:   tc.Name,
+		key:    tc.Key,
+		onMiss: tc.OnMiss,
+		stats:  &lookupStats{},
+		ctx:    ctx,
+	}
+	if l.name == "" {
+		l.name = "lookup"
+	}
+	if _, dup := c.lookups[l.name]; dup {
+		return nil, fmt.Errorf("duplicate lookup name %s", l.name)
+	}
+
+	columns := make([]string, 0, len(tc.Fields))
+	for column := range tc.Fields {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		l.fields = append(l.fields, lookupField{column: column, source: tc.Fields[column], fallback: tc.Defaults[column]})
+	}
+
+	switch l.onMiss {
+	case "", "null", "reject":
+		if len(tc.Defaults) > 0 {
+			return nil, fmt.Errorf("lookup %s: defaults require on_miss: default", l.name)
+		}
+	case "default":
+		// a default of null must be given explicitly
+		for _, column := range columns {
+			if _, ok := tc.Defaults[column]; !ok {
+				return nil, fmt.Errorf("lookup %s: on_miss: default requires a default for %s", l.name, column)
+			}
+		}
+		for column := range tc.Defaults {
+			if _, ok := tc.Fields[column]; !ok {
+				return nil, fmt.Errorf("lookup %s: default for %s, which is not one of the fields", l.name, column)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("lookup on_miss must be null, default or reject, got %q", l.onMiss)
+	}
+	var err error
+	if l.policy, err = c.newErrorPolicy(cfg, "lookup "+l.name, tc.OnError, false); err != nil {
+		return nil, err
+	}
+
+	switch tc.Mode {
+	case "", "cache":
+		if err := l.load(ctx, cfg, *tc.Source, lookupKey); err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", l.name, err)
+		}
+	case "lru":
+		if tc.Query == "" {
+			return nil, fmt.Errorf("lookup mode lru requires query")
+		}
+		size := tc.CacheSize
+		if size <= 0 {
+			size = defaultLookupCacheSize
+		}
+		l.lru = newLRUCache(size)
+		l.driver = tc.Source.Type
+		if l.db, err = extract.OpenSQL(ctx, *tc.Source); err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", l.name, err)
+		}
+		if l.query, err = l.db.PrepareContext(ctx, tc.Query); err != nil {
+			l.db.Close()
+			return nil, fmt.Errorf("lookup %s: invalid query: %w", l.name, err)
+		}
+	default:
+		return nil, fmt.Errorf("lookup mode must be cache or lru, got %q", tc.Mode)
+	}
+
+	c.lookups[l.name] = l.stats
+	return l, nil
+}
+
+// load reads the whole dataset through the extractor for its source type.
+func (l *lookup) load(ctx context.Context, cfg *config.PipelineConfig, src config.SourceConfig, lookupKey []string) error {
+	ext, err := extract.New(src.Type)
+	if err != nil {
+		return err
+	}
+	child := *cfg
+	child.Source = src
+	if err := ext.Init(ctx, &child); err != nil {
+		return err
+	}
+	defer ext.Close()
+
+	l.rows = make(map[string]lookupRow)
+	duplicates := 0
+	records, errs := ext.Extract(ctx)
+	for records != nil || errs != nil {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				records = nil
+				continue
+			}
+			values := make([]interface{}, len(lookupKey))
+			for i, field := range lookupKey {
+				values[i] = record[field]
+			}
+			row := make(lookupRow, len(l.fields))
+			for i, f := range l.fields {
+				row[i] = record[f.source]
+			}
+			key := joinKey(values)
+			if _, dup := l.rows[key]; dup {
+				duplicates++
+			}
+			l.rows[key] = row
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	l.stats.Rows = int64(len(l.rows))
+	if duplicates > 0 {
+		log.Printf("Lookup %s: %d duplicate keys, the last row of each wins", l.name, duplicates)
+	}
+	log.Printf("Lookup %s: loaded %d rows", l.name, len(l.rows))
+	return nil
+}
+
+func (l *lookup) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	values := make([]interface{}, len(l.key))
+	for i, field := range l.key {
+		values[i] = record[field]
+	}
+
+	row, err := l.find(values)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", l.name, err)
+	}
+	if row != nil {
+		l.stats.Hits.Add(1)
+		for i, f := range l.fields {
+			record[f.column] = row[i]
+		}
+		return record, nil
+	}
+
+	l.stats.Misses.Add(1)
+	switch l.onMiss {
+	case "reject":
+		return l.policy.handle(record, fmt.Errorf("lookup %s: no row for key %s", l.name, strings.Join(keyParts(values), ", ")))
+	case "default":
+		for _, f := range l.fields {
+			record[f.column] = f.fallback
+		}
+	default:
+		for _, f := range l.fields {
+			record[f.column] = nil
+		}
+	}
+	return record, nil
+}
+
+func (l *lookup) find(values []interface{}) (lookupRow, error) {
+	for _, v := range values {
+		if v == nil {
+			return nil, nil
+		}
+	}
+	key := joinKey(values)
+	if l.lru == nil {
+		return l.rows[key], nil
+	}
+
+	if row, ok := l.lru.get(key); ok {
+		return row, nil
+	}
+	row, err := l.fetch(values)
+	if err != nil {
+		return nil, err
+	}
+	l.lru.put(key, row)
+	return row, nil
+}
+
+// fetch queries the row for a key on a cache miss.
+func (l *lookup) fetch(values []interface{}) (lookupRow, error) {
+	l.stats.Queries.Add(1)
+	rows, err := l.query.QueryContext(l.ctx, values...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	scanner, err := extract.NewRowScanner(rows, l.driver)
+	if err != nil {
+		return nil, err
+	}
+	byName, err := scanner.Scan(rows)
+	if err != nil {
+		return nil, err
+	}
+	row := make(lookupRow, len(l.fields))
+	for i, f := range l.fields {
+		v, ok := byName[f.source]
+		if !ok {
+			return nil, fmt.Errorf("query returned no column %s", f.source)
+		}
+		row[i] = v
+	}
+	return row, nil
+}
+
+// parallel reports whether the lookup can run on several workers, which is
+// the case in cache mode where the rows are only read.
+func (l *lookup) parallel() bool { return l.lru == nil }
+
+func (l *lookup) report() {
+	log.Printf("Lookup %s: %d hits, %d misses, %d queries",
+		l.name, l.stats.Hits.Load(), l.stats.Misses.Load(), l.stats.Queries.Load())
+	l.policy.report()
+}
+
+func (l *lookup) Close() error {
+	if l.query != nil {
+		l.query.Close()
+	}
+	if l.db != nil {
+		return l.db.Close()
+	}
+	return nil
+}
+
+// lruCache keeps the most recently used lookup rows.
+type lruCache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	row lookupRow
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string) (lookupRow, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).row, true
+}
+
+func (c *lruCache) put(key string, row lookupRow) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*lruEntry).row = row
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, row: row})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
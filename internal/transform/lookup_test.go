@@ -0,0 +1,211 @@
+package transform
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestLookupCache(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "dealers.csv")
+	if err := os.WriteFile(master, []byte("code,branch,segment\nD1,Pune,retail\nD2,Delhi,\nD1,Mumbai,retail\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.PipelineConfig{Transformations: []config.TransformationConfig{{
+		Type:      "lookup",
+		Name:      "dealer_master",
+		Source:    &config.SourceConfig{Type: "csv", Path: master},
+		Key:       []string{"dealer"},
+		LookupKey: []string{"code"},
+		Fields:    map[string]string{"branch": "branch", "segment": "segment"},
+		OnMiss:    "default",
+		Defaults:  map[string]interface{}{"branch": "UNKNOWN", "segment": nil},
+	}}}
+	run := pipeline.NewRun("test")
+	c := NewChain()
+	if err := c.Init(pipeline.WithRun(context.Background(), run), cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, tc := range []struct {
+		dealer  interface{}
+		branch  interface{}
+		segment interface{}
+	}{
+		{"D1", "Mumbai", "retail"}, // the last duplicate wins
+		{"D2", "Delhi", nil},
+		{"D9", "UNKNOWN", nil},
+		{nil, "UNKNOWN", nil},
+	} {
+		record, err := c.steps[0].apply(pipeline.DataRecord{"dealer": tc.dealer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record["branch"] != tc.branch || record["segment"] != tc.segment {
+			t.Errorf("%v: branch %#v, segment %#v", tc.dealer, record["branch"], record["segment"])
+		}
+	}
+
+	v, ok := run.Get("lookups")
+	if !ok {
+		t.Fatal("no lookups in the run metadata")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"dealer_master":{"hits":2,"misses":2,"queries":0,"rows":2}}` {
+		t.Errorf("run metadata %s", data)
+	}
+}
+
+func TestLookupLRU(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE dealers (code TEXT, branch TEXT);
+		INSERT INTO dealers VALUES ('A', 'Pune'), ('B', 'Delhi'), ('C', 'Goa')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	c := NewChain()
+	c.lookups = make(map[string]*lookupStats)
+	l, err := c.newLookup(context.Background(), &config.PipelineConfig{}, config.TransformationConfig{
+		Source:    &config.SourceConfig{Type: "sqlite", Path: path},
+		Mode:      "lru",
+		CacheSize: 2,
+		Query:     "SELECT branch FROM dealers WHERE code = ?",
+		Key:       []string{"dealer"},
+		Fields:    map[string]string{"branch": "branch"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.parallel() {
+		t.Error("an lru lookup runs on several workers")
+	}
+
+	// C evicts B, the least recently used; the miss on Z is cached
+	for i, dealer := range []string{"A", "B", "A", "C", "B", "Z", "Z"} {
+		record, err := l.apply(pipeline.DataRecord{"dealer": dealer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{"A": "Pune", "B": "Delhi", "C": "Goa"}[dealer]
+		if record["branch"] != want {
+			t.Errorf("%d: %s gave branch %v, want %v", i, dealer, record["branch"], want)
+		}
+	}
+	if hits, misses, queries := l.stats.Hits.Load(), l.stats.Misses.Load(), l.stats.Queries.Load(); hits != 5 || misses != 2 || queries != 5 {
+		t.Errorf("%d hits, %d misses, %d queries, want 5, 2 and 5", hits, misses, queries)
+	}
+	if _, ok := l.lru.entries[joinKey([]interface{}{"A"})]; ok {
+		t.Error("A was not evicted")
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRUCache(2)
+	c.put("a", lookupRow{1})
+	c.put("b", lookupRow{2})
+	c.get("a")
+	c.put("c", lookupRow{3})
+	if _, ok := c.get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	c.put("a", lookupRow{4})
+	if row, ok := c.get("a"); !ok || row[0] != 4 {
+		t.Errorf("a = %v, %v", row, ok)
+	}
+	c.put("d", nil)
+	if row, ok := c.get("d"); !ok || row != nil {
+		t.Error("a miss is not cached")
+	}
+	if _, ok := c.get("c"); ok || c.order.Len() != 2 {
+		t.Errorf("c was not evicted, %d entries", c.order.Len())
+	}
+}
+
+func TestLookupOnMiss(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "dealers.csv")
+	if err := os.WriteFile(master, []byte("code,branch\nD1,Pune\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newLookup := func(onMiss, onError string, defaults map[string]interface{}) (*Chain, *lookup, error) {
+		cfg := &config.PipelineConfig{}
+		cfg.Pipeline.Name = "test"
+		cfg.Pipeline.DeadLetter.Path = filepath.Join(dir, "dead.jsonl")
+		c := NewChain()
+		c.lookups = make(map[string]*lookupStats)
+		l, err := c.newLookup(context.Background(), cfg, config.TransformationConfig{
+			Source:   &config.SourceConfig{Type: "csv", Path: master},
+			Key:      []string{"code"},
+			Fields:   map[string]string{"branch": "branch", "region": "region"},
+			OnMiss:   onMiss,
+			OnError:  onError,
+			Defaults: defaults,
+		})
+		return c, l, err
+	}
+
+	_, l, err := newLookup("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := l.apply(pipeline.DataRecord{"code": "D2", "branch": "stale"})
+	if err != nil || record["branch"] != nil {
+		t.Errorf("on_miss null gave %v, %v", record, err)
+	}
+
+	_, l, err = newLookup("reject", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.apply(pipeline.DataRecord{"code": "D2"}); err == nil || !strings.Contains(err.Error(), "no row for key D2") {
+		t.Errorf("on_miss reject gave %v", err)
+	}
+	if record, err := l.apply(pipeline.DataRecord{"code": "D1"}); err != nil || record["branch"] != "Pune" {
+		t.Errorf("hit gave %v, %v", record, err)
+	}
+
+	c, l, err := newLookup("reject", "dead_letter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, err := l.apply(pipeline.DataRecord{"code": "D2"}); err != nil || record != nil {
+		t.Errorf("dead-lettered miss gave %v, %v", record, err)
+	}
+	if c.deadLetter.Count() != 1 {
+		t.Errorf("%d dead letters, want 1", c.deadLetter.Count())
+	}
+	c.Close()
+
+	for _, tc := range []struct {
+		onMiss   string
+		defaults map[string]interface{}
+		err      string
+	}{
+		{"default", map[string]interface{}{"branch": "UNKNOWN"}, "requires a default for region"},
+		{"default", map[string]interface{}{"branch": "UNKNOWN", "region": nil, "zone": "x"}, "zone, which is not one of the fields"},
+		{"", map[string]interface{}{"branch": "UNKNOWN"}, "defaults require on_miss: default"},
+		{"drop", nil, "on_miss must be"},
+	} {
+		if _, _, err := newLookup(tc.onMiss, "", tc.defaults); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("on_miss %q with %v gave %v, want %q", tc.onMiss, tc.defaults, err, tc.err)
+		}
+	}
+}
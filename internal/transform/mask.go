@@ -0,0 +1,259 @@
+package transform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/secrets"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const (
+	minHashKeyBytes    = 16
+	defaultRedaction   = "[REDACTED]"
+	keyFingerprintSalt = "etl-framework mask key"
+)
+
+var (
+	ipv4Pattern  = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// ipv6Candidate finds runs that might be IPv6 addresses; each one is
+	// checked with net.ParseIP so that times such as 12:30:45 are left
+	// alone.
+	ipv6Candidate = regexp.MustCompile(`[0-9A-Fa-f]*:[0-9A-Fa-f:.]*[0-9A-Fa-f:]`)
+)
+
+// MaskPolicy is what the run metadata records about each masked column.
+// Keys are identified by a fingerprint, never by value.
+type MaskPolicy struct {
+	Column    string   `json:"column"`
+	Strategy  string   `json:"strategy"`
+	KeyID     string   `json:"key_id,omitempty"`
+	KeepFirst int      `json:"keep_first,omitempty"`
+	KeepLast  int      `json:"keep_last,omitempty"`
+	Redact    []string `json:"redact,omitempty"`
+	Patterns  []string `json:"patterns,omitempty"`
+}
+
+// mask protects columns holding personal data before they reach the sinks.
+// Null values stay null.
+type mask struct {
+	columns []maskColumn
+}
+
+type maskColumn struct {
+	name  string
+	apply func(v interface{}) interface{}
+}
+
+func (c *Chain) newMask(tc config.TransformationConfig) (*mask, error) {
+	if len(tc.Columns) == 0 {
+		return nil, fmt.Errorf("mask transformation requires columns")
+	}
+
+	names := make([]string, 0, len(tc.Columns))
+	for name := range tc.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &mask{}
+	for _, name := range names {
+		mc := tc.Columns[name]
+		policy := MaskPolicy{Column: name, Strategy: mc.Strategy}
+		col := maskColumn{name: name}
+
+		switch mc.Strategy {
+		case "hash":
+			key, err := secrets.Resolve(mc.Key)
+			if err != nil {
+				return nil, fmt.Errorf("mask %s key: %w", name, err)
+			}
+			if len(key) < minHashKeyBytes {
+				return nil, fmt.Errorf("mask %s requires a key of at least %d bytes", name, minHashKeyBytes)
+			}
+			policy.KeyID = keyFingerprint(key)
+			col.apply = hashValue([]byte(key))
+
+		case "partial":
+			if mc.KeepFirst < 0 || mc.KeepLast < 0 {
+				return nil, fmt.Errorf("mask %s keep_first and keep_last must not be negative", name)
+			}
+			char := '*'
+			if mc.MaskChar != "" {
+				if utf8.RuneCountInString(mc.MaskChar) != 1 {
+					return nil, fmt.Errorf("mask %s mask_char must be one character", name)
+				}
+				char, _ = utf8.DecodeRuneInString(mc.MaskChar)
+			}
+			policy.KeepFirst, policy.KeepLast = mc.KeepFirst, mc.KeepLast
+			col.apply = partialMask(mc.KeepFirst, mc.KeepLast, char)
+
+		case "null":
+			col.apply = func(interface{}) interface{} { return nil }
+
+		case "redact":
+			replace, err := redactor(mc)
+			if err != nil {
+				return nil, fmt.Errorf("mask %s: %w", name, err)
+			}
+			policy.Redact, policy.Patterns = mc.Redact, mc.Patterns
+			col.apply = func(v interface{}) interface{} { return replace(canonical(v)) }
+
+		case "":
+			return nil, fmt.Errorf("mask %s requires a strategy", name)
+		default:
+			return nil, fmt.Errorf("mask %s: unsupported strategy %s", name, mc.Strategy)
+		}
+
+		m.columns = append(m.columns, col)
+		c.masking = append(c.masking, policy)
+	}
+	return m, nil
+}
+
+func (m *mask) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	for _, col := range m.columns {
+		if v, ok := record[col.name]; ok && v != nil {
+			record[col.name] = col.apply(v)
+		}
+	}
+	return record, nil
+}
+
+func (m *mask) parallel() bool { return true }
+
+// keyFingerprint identifies a key in the run metadata without revealing
+// it, so that a key rotation shows up as a change of key_id.
+func keyFingerprint(key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(keyFingerprintSalt))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// hashValue returns the hex HMAC-SHA256 of the value's canonical string, so
+// that the same ID hashes the same way in every table and run that uses
+// the key.
+func hashValue(key []byte) func(interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(canonical(v)))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+// partialMask replaces the letters and digits between the first keepFirst
+// and last keepLast characters with char, keeping separators so that the
+// value keeps its format. Values too short to hide anything are masked
+// entirely.
+func partialMask(keepFirst, keepLast int, char rune) func(interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		runes := []rune(canonical(v))
+		first, last := keepFirst, keepLast
+		if first+last >= len(runes) {
+			first, last = 0, 0
+		}
+		for i := first; i < len(runes)-last; i++ {
+			if unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) {
+				runes[i] = char
+			}
+		}
+		return string(runes)
+	}
+}
+
+func redactor(mc config.MaskConfig) (func(string) string, error) {
+	replacement := mc.Replacement
+	if replacement == "" {
+		replacement = defaultRedaction
+	}
+
+	var steps []func(string) string
+	for _, name := range mc.Redact {
+		switch name {
+		case "ip":
+			steps = append(steps, redactIPv6(replacement), regexReplacer(ipv4Pattern, replacement))
+		case "ipv4":
+			steps = append(steps, regexReplacer(ipv4Pattern, replacement))
+		case "ipv6":
+			steps = append(steps, redactIPv6(replacement))
+		case "email":
+			steps = append(steps, regexReplacer(emailPattern, replacement))
+		default:
+			return nil, fmt.Errorf("unknown redact pattern %s, expected ip, ipv4, ipv6 or email", name)
+		}
+	}
+	for _, p := range mc.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		steps = append(steps, regexReplacer(re, replacement))
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("redact requires redact or patterns")
+	}
+
+	return func(s string) string {
+		for _, step := range steps {
+			s = step(s)
+		}
+		return s
+	}, nil
+}
+
+func regexReplacer(re *regexp.Regexp, replacement string) func(string) string {
+	return func(s string) string {
+		return re.ReplaceAllLiteralString(s, replacement)
+	}
+}
+
+func redactIPv6(replacement string) func(string) string {
+	return func(s string) string {
+		return ipv6Candidate.ReplaceAllStringFunc(s, func(m string) string {
+			// an IPv4-mapped address may end in a sentence's full stop
+			trimmed := strings.TrimRight(m, ".")
+			if strings.Count(trimmed, ":") < 2 || net.ParseIP(trimmed) == nil {
+				return m
+			}
+			return replacement + m[len(trimmed):]
+		})
+	}
+}
+
+// canonical is the string form of a value that is hashed or masked.
+func canonical(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case json.Number:
+		return t.String()
+	case int:
+		return strconv.Itoa(t)
+	case int32:
+		return strconv.FormatInt(int64(t), 10)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
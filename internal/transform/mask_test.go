@@ -0,0 +1,177 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const testMaskKey = "0123456789abcdef-test"
+
+func TestMaskHashIsStable(t *testing.T) {
+	hash := hashValue([]byte(testMaskKey))
+	want := hash("42")
+	for _, v := range []interface{}{int64(42), 42, int32(42), json.Number("42"), []byte("42"), float64(42)} {
+		if got := hash(v); got != want {
+			t.Errorf("%T 42 hashes to %v, want %v", v, got, want)
+		}
+	}
+	if len(want.(string)) != 64 {
+		t.Errorf("hash %v is not hex HMAC-SHA256", want)
+	}
+	if hashValue([]byte(testMaskKey+"2"))("42") == want {
+		t.Error("a different key gives the same hash")
+	}
+	if hash("43") == want {
+		t.Error("different values give the same hash")
+	}
+}
+
+func TestPartialMask(t *testing.T) {
+	for _, tc := range []struct {
+		first, last int
+		char        rune
+		in          interface{}
+		want        string
+	}{
+		{0, 4, '*', "+91 98765-43210", "+** *****-*3210"},
+		{2, 0, '*', "AB-12-CD", "AB-**-**"},
+		{1, 1, 'x', "john.doe@example.com", "jxxx.xxx@xxxxxxx.xxm"},
+		{2, 2, '*', "abcd", "****"}, // nothing left to hide
+		{2, 2, '*', "abc", "***"},   // shorter than what is kept
+		{0, 4, '#', int64(12345678), "####5678"},
+		{1, 1, '*', "ßüé", "ß*é"},     // counts characters, not bytes
+		{0, 0, '*', "--:--", "--:--"}, // separators only
+	} {
+		if got := partialMask(tc.first, tc.last, tc.char)(tc.in); got != tc.want {
+			t.Errorf("partialMask(%d, %d) of %v = %v, want %v", tc.first, tc.last, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		redact []string
+		in     string
+		want   string
+	}{
+		{[]string{"ipv4"}, "login from 10.0.0.1, retry from 192.168.1.254", "login from [REDACTED], retry from [REDACTED]"},
+		{[]string{"ipv4"}, "build 999.1.1.1 and 1.2.3", "build 999.1.1.1 and 1.2.3"},
+		{[]string{"ipv6"}, "via fe80::1 and 2001:db8::8a2e:370:7334", "via [REDACTED] and [REDACTED]"},
+		{[]string{"ipv6"}, "mapped ::ffff:192.168.1.1.", "mapped [REDACTED]."},
+		{[]string{"ipv6"}, "logon at 12:30:45, mode: 2, ab:cd", "logon at 12:30:45, mode: 2, ab:cd"},
+		{[]string{"ip"}, "12:30:45 10.1.1.1 ::1", "12:30:45 [REDACTED] [REDACTED]"},
+		{[]string{"email"}, "mail a.b+tag@example.co.in now", "mail [REDACTED] now"},
+		{[]string{"ip", "email"}, "at 09:15:00 d@x.org from 10.0.0.7", "at 09:15:00 [REDACTED] from [REDACTED]"},
+	} {
+		replace, err := redactor(config.MaskConfig{Redact: tc.redact})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := replace(tc.in); got != tc.want {
+			t.Errorf("redact %v of %q = %q, want %q", tc.redact, tc.in, got, tc.want)
+		}
+	}
+
+	replace, err := redactor(config.MaskConfig{Patterns: []string{`ACC-\d{8}`}, Replacement: "#"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replace("ACC-12345678 and ACC-1"); got != "# and ACC-1" {
+		t.Errorf("pattern redaction gave %q", got)
+	}
+
+	for _, mc := range []config.MaskConfig{{}, {Redact: []string{"phone"}}, {Patterns: []string{"("}}} {
+		if _, err := redactor(mc); err == nil {
+			t.Errorf("redactor(%+v) did not fail", mc)
+		}
+	}
+}
+
+func TestMaskStepAndMetadata(t *testing.T) {
+	t.Setenv("PII_HASH_KEY", testMaskKey)
+	cfg := &config.PipelineConfig{Transformations: []config.TransformationConfig{{
+		Type: "mask",
+		Columns: map[string]config.MaskConfig{
+			"dealer_id": {Strategy: "hash", Key: "env:PII_HASH_KEY"},
+			"phone":     {Strategy: "partial", KeepLast: 4},
+			"email":     {Strategy: "null"},
+			"details":   {Strategy: "redact", Redact: []string{"ip"}},
+		},
+	}}}
+	run := pipeline.NewRun("test")
+	ctx := pipeline.WithRun(context.Background(), run)
+
+	c := NewChain()
+	if err := c.Init(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	record, err := c.steps[0].apply(pipeline.DataRecord{
+		"dealer_id": int64(1001),
+		"phone":     nil,
+		"email":     "a@b.com",
+		"details":   "from 10.0.0.1 at 12:30:45",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["dealer_id"] != hashValue([]byte(testMaskKey))("1001") {
+		t.Errorf("dealer_id = %v", record["dealer_id"])
+	}
+	if record["phone"] != nil || record["email"] != nil {
+		t.Errorf("phone = %v, email = %v, want null", record["phone"], record["email"])
+	}
+	if record["details"] != "from [REDACTED] at 12:30:45" {
+		t.Errorf("details = %v", record["details"])
+	}
+
+	v, ok := run.Get("masking")
+	if !ok {
+		t.Fatal("no masking in the run metadata")
+	}
+	policies := v.([]MaskPolicy)
+	if len(policies) != 4 {
+		t.Fatalf("masking lists %d columns, want 4", len(policies))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testMaskKey) {
+		t.Fatalf("run metadata reveals the key: %s", data)
+	}
+	for _, p := range policies {
+		switch p.Column {
+		case "dealer_id":
+			if p.Strategy != "hash" || p.KeyID != keyFingerprint(testMaskKey) || len(p.KeyID) != 12 {
+				t.Errorf("dealer_id policy %+v", p)
+			}
+		case "phone":
+			if p.Strategy != "partial" || p.KeepLast != 4 || p.KeyID != "" {
+				t.Errorf("phone policy %+v", p)
+			}
+		case "details":
+			if p.Strategy != "redact" || len(p.Redact) != 1 || p.Redact[0] != "ip" {
+				t.Errorf("details policy %+v", p)
+			}
+		}
+	}
+	if keyFingerprint(testMaskKey+"2") == keyFingerprint(testMaskKey) {
+		t.Error("rotated key has the same key_id")
+	}
+}
+
+func TestMaskRejectsShortKey(t *testing.T) {
+	t.Setenv("PII_HASH_KEY", "short")
+	c := NewChain()
+	_, err := c.newMask(config.TransformationConfig{Columns: map[string]config.MaskConfig{
+		"dealer_id": {Strategy: "hash", Key: "env:PII_HASH_KEY"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "at least 16 bytes") {
+		t.Fatalf("got %v, want a key length error", err)
+	}
+}
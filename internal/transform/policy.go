@@ -0,0 +1,84 @@
+package transform
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// errorPolicy decides what happens to a record that a step cannot
+// transform: fail the run (the default), skip it, write it to the dead
+// letter file, or, for steps that allow it, null the affected fields.
+type errorPolicy struct {
+	name       string
+	mode       string
+	deadLetter *pipeline.DeadLetter
+	count      atomic.Int64
+}
+
+func (c *Chain) newErrorPolicy(cfg *config.PipelineConfig, name, mode string, allowNull bool) (*errorPolicy, error) {
+	p := &errorPolicy{name: name, mode: mode}
+	switch mode {
+	case "", "fail", "skip":
+	case "null":
+		if !allowNull {
+			return nil, fmt.Errorf("%s does not support on_error: null", name)
+		}
+	case "dead_letter":
+		if cfg.Pipeline.DeadLetter.Path == "" {
+			return nil, fmt.Errorf("on_error: dead_letter requires pipeline.dead_letter.path")
+		}
+		if c.deadLetter == nil {
+			c.deadLetter = pipeline.NewDeadLetter(cfg.Pipeline.DeadLetter.Path, cfg.Pipeline.Name)
+		}
+		p.deadLetter = c.deadLetter
+	default:
+		return nil, fmt.Errorf("unsupported on_error: %s", mode)
+	}
+	return p, nil
+}
+
+// nulls reports whether the step should null the failed fields and keep the
+// record.
+func (p *errorPolicy) nulls() bool {
+	return p.mode == "null"
+}
+
+// handle applies the policy to a record that failed with err. It returns
+// the record to pass on, which is nil when the record is dropped.
+func (p *errorPolicy) handle(record pipeline.DataRecord, err error) (pipeline.DataRecord, error) {
+	switch p.mode {
+	case "skip":
+		p.count.Add(1)
+		return nil, nil
+	case "null":
+		p.count.Add(1)
+		return record, nil
+	case "dead_letter":
+		p.count.Add(1)
+		if err := p.deadLetter.Write(p.name, record, err); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (p *errorPolicy) report() {
+	count := p.count.Load()
+	if count == 0 {
+		return
+	}
+	switch p.mode {
+	case "skip":
+		log.Printf("%s skipped %d records", p.name, count)
+	case "null":
+		log.Printf("%s nulled fields of %d records", p.name, count)
+	case "dead_letter":
+		log.Printf("%s wrote %d records to %s", p.name, count, p.deadLetter.Path())
+	}
+}
@@ -0,0 +1,131 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/aniketwaliyan/etl-framework/internal/extract"
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/schema"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// schemaCheck validates every record against the configured schema and
+// coerces its fields to their declared types. It runs before the
+// transformations, so they see typed values. A record that does not fit
+// is handled by schema.on_error; fields the schema does not name are passed
+// on unchanged.
+type schemaCheck struct {
+	// fields by source table; "" holds the declared fields, used for
+	// records of tables without an inferred schema
+	fields map[string][]schema.Field
+	policy *errorPolicy
+	stats  *schemaStats
+}
+
+// schemaStats are reported in the run metadata.
+type schemaStats struct {
+	Fields     map[string][]schema.Field
+	Records    atomic.Int64
+	Violations atomic.Int64
+}
+
+func (s *schemaStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"fields":     s.Fields,
+		"records":    s.Records.Load(),
+		"violations": s.Violations.Load(),
+	})
+}
+
+func (c *Chain) newSchemaCheck(ctx context.Context, cfg *config.PipelineConfig) (*schemaCheck, error) {
+	declared, err := schema.FromConfig(cfg.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	s := &schemaCheck{fields: map[string][]schema.Field{"": declared}}
+
+	if cfg.Schema.Infer {
+		inferred, err := extract.SourceSchema(ctx, cfg.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to infer schema: %w", err)
+		}
+		for table, fields := range inferred {
+			s.fields[table] = mergeFields(fields, declared)
+		}
+	}
+	if cfg.Source.Type == "sqlserver" {
+		for _, fields := range s.fields {
+			for i := range fields {
+				fields[i].MixedEndian = true
+			}
+		}
+	}
+
+	if s.policy, err = c.newErrorPolicy(cfg, "schema", cfg.Schema.OnError, false); err != nil {
+		return nil, err
+	}
+	s.stats = &schemaStats{Fields: s.fields}
+	pipeline.RunFromContext(ctx).Set("schema", s.stats)
+	return s, nil
+}
+
+// mergeFields returns the inferred fields with the declared ones in place
+// of those of the same name, followed by the other declared fields.
+func mergeFields(inferred, declared []schema.Field) []schema.Field {
+	byName := make(map[string]schema.Field, len(declared))
+	for _, f := range declared {
+		byName[f.Name] = f
+	}
+	merged := make([]schema.Field, 0, len(inferred)+len(declared))
+	for _, f := range inferred {
+		if d, ok := byName[f.Name]; ok {
+			f = d
+			delete(byName, f.Name)
+		}
+		merged = append(merged, f)
+	}
+	for _, f := range declared {
+		if _, ok := byName[f.Name]; ok {
+			merged = append(merged, f)
+		}
+	}
+	return merged
+}
+
+func (s *schemaCheck) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	s.stats.Records.Add(1)
+	table, _ := record[pipeline.FieldSourceTable].(string)
+	fields, ok := s.fields[table]
+	if !ok {
+		fields = s.fields[""]
+	}
+
+	// The record is only changed once every field fits, so a rejected
+	// record is dead-lettered as it was read.
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		v, err := f.Coerce(record[f.Name])
+		if err != nil {
+			s.stats.Violations.Add(1)
+			return s.policy.handle(record, fmt.Errorf("schema: field %s: %w", f.Name, err))
+		}
+		values[i] = v
+	}
+	for i, f := range fields {
+		if _, present := record[f.Name]; present {
+			record[f.Name] = values[i]
+		}
+	}
+	return record, nil
+}
+
+func (s *schemaCheck) parallel() bool { return true }
+
+func (s *schemaCheck) report() {
+	log.Printf("Schema: checked %d records, %d did not fit", s.stats.Records.Load(), s.stats.Violations.Load())
+	s.policy.report()
+}
@@ -0,0 +1,345 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/state"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+const (
+	defaultSessionTable   = "sessions"
+	defaultSessionTimeout = 24 * time.Hour
+
+	sessionClosed       = "closed"
+	sessionTimedOut     = "timed_out"
+	sessionNoLogoff     = "no_logoff"
+	sessionOrphanLogoff = "orphan_logoff"
+)
+
+// sessionize pairs logon and logoff events into session records. Events
+// arrive in any order and from several shards, so they are collected and
+// paired per key in time order once the input ends. Sessions still open at
+// the end of a run are kept in the state store and paired with the logoff
+// in a later run, unless they are older than the timeout.
+type sessionize struct {
+	key       []string
+	timeField string
+	flagField string
+	unit      time.Duration
+	logon     map[string]bool
+	logoff    map[string]bool
+	timeout   time.Duration
+	carry     []string
+	table     string
+	keep      bool
+	policy    *errorPolicy
+
+	state    *state.Store
+	stateKey string
+	open     map[string]openSession
+	events   map[string][]sessionEvent
+}
+
+// openSession is what the state store keeps about a session without a
+// logoff yet.
+type openSession struct {
+	Key   []interface{}          `json:"key"`
+	Start time.Time              `json:"start"`
+	Carry map[string]interface{} `json:"carry,omitempty"`
+}
+
+type sessionEvent struct {
+	keyValues []interface{}
+	at        time.Time
+	logon     bool
+	carry     map[string]interface{}
+}
+
+func (c *Chain) newSessionize(ctx context.Context, cfg *config.PipelineConfig, tc config.TransformationConfig) (*sessionize, error) {
+	if len(tc.Key) == 0 || tc.TimeField == "" || tc.FlagField == "" {
+		return nil, fmt.Errorf("sessionize requires key, time_field and flag_field")
+	}
+	if len(tc.LogonValues) == 0 || len(tc.LogoffValues) == 0 {
+		return nil, fmt.Errorf("sessionize requires logon_values and logoff_values")
+	}
+
+	s := &sessionize{
+		key:       tc.Key,
+		timeField: tc.TimeField,
+		flagField: tc.FlagField,
+		unit:      time.Second,
+		logon:     make(map[string]bool),
+		logoff:    make(map[string]bool),
+		timeout:   tc.Timeout,
+		carry:     tc.Carry,
+		table:     tc.Table,
+		stateKey:  tc.StateKey,
+		events:    make(map[string][]sessionEvent),
+	}
+	if tc.From != "" {
+		unit, ok := epochUnits[tc.From]
+		if !ok {
+			return nil, fmt.Errorf("sessionize from must be epoch_seconds, epoch_millis or epoch_micros, got %q", tc.From)
+		}
+		s.unit = unit
+	}
+	for _, v := range tc.LogonValues {
+		s.logon[strings.TrimSpace(v)] = true
+	}
+	for _, v := range tc.LogoffValues {
+		v = strings.TrimSpace(v)
+		if s.logon[v] {
+			return nil, fmt.Errorf("sessionize flag value %q is both logon and logoff", v)
+		}
+		s.logoff[v] = true
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultSessionTimeout
+	}
+	if s.table == "" {
+		s.table = defaultSessionTable
+	}
+	switch tc.Events {
+	case "", "keep":
+		s.keep = true
+	case "drop":
+	default:
+		return nil, fmt.Errorf("sessionize events must be keep or drop, got %q", tc.Events)
+	}
+	if s.stateKey == "" {
+		s.stateKey = "sessions"
+	}
+	s.stateKey = "sessionize:" + s.stateKey
+
+	var err error
+	if s.policy, err = c.newErrorPolicy(cfg, "sessionize", tc.OnError, false); err != nil {
+		return nil, err
+	}
+	if s.state, err = state.Open(ctx, cfg); err != nil {
+		return nil, err
+	}
+	s.open = make(map[string]openSession)
+	if _, err := s.state.Get(s.stateKey, &s.open); err != nil {
+		return nil, err
+	}
+	for _, o := range s.open {
+		for i, v := range o.Key {
+			o.Key[i] = fromState(v)
+		}
+		for k, v := range o.Carry {
+			o.Carry[k] = fromState(v)
+		}
+	}
+	return s, nil
+}
+
+// fromState turns whole numbers, which JSON decodes as float64, back into
+// integers.
+func fromState(v interface{}) interface{} {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return v
+}
+
+func (s *sessionize) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	e, key, err := s.event(record)
+	if err != nil {
+		return s.policy.handle(record, fmt.Errorf("sessionize: %w", err))
+	}
+	s.events[key] = append(s.events[key], e)
+	if !s.keep {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (s *sessionize) event(record pipeline.DataRecord) (sessionEvent, string, error) {
+	var e sessionEvent
+
+	flag := record[s.flagField]
+	if flag == nil {
+		return e, "", fmt.Errorf("%s is null", s.flagField)
+	}
+	switch f := strings.TrimSpace(canonical(flag)); {
+	case s.logon[f]:
+		e.logon = true
+	case s.logoff[f]:
+	default:
+		return e, "", fmt.Errorf("%s %q is neither a logon nor a logoff", s.flagField, f)
+	}
+
+	v := record[s.timeField]
+	if v == nil {
+		return e, "", fmt.Errorf("%s is null", s.timeField)
+	}
+	at, err := fieldTime(v, s.unit)
+	if err != nil {
+		return e, "", fmt.Errorf("%s: %w", s.timeField, err)
+	}
+	e.at = at
+
+	e.keyValues = make([]interface{}, len(s.key))
+	for i, field := range s.key {
+		v := record[field]
+		if v == nil {
+			return e, "", fmt.Errorf("key field %s is null", field)
+		}
+		e.keyValues[i] = v
+	}
+
+	if len(s.carry) > 0 {
+		e.carry = make(map[string]interface{}, len(s.carry))
+		for _, field := range s.carry {
+			e.carry[field] = record[field]
+		}
+	}
+	return e, joinKey(e.keyValues), nil
+}
+
+// flush pairs the events of the run with the sessions left open by earlier
+// runs and emits the session records. The latest event time is the clock
+// for timeouts, so reprocessing old data gives the same sessions.
+func (s *sessionize) flush(emitRecord func(pipeline.DataRecord) bool) error {
+	var (
+		sessions []pipeline.DataRecord
+		latest   time.Time
+	)
+	for _, events := range s.events {
+		for _, e := range events {
+			if e.at.After(latest) {
+				latest = e.at
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(s.events))
+	for key := range s.events {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	counts := make(map[string]int)
+	emit := func(o openSession, end *time.Time, status string) {
+		counts[status]++
+		sessions = append(sessions, s.record(o, end, status))
+	}
+
+	for _, key := range keys {
+		events := s.events[key]
+		// a session left open by an earlier run takes part as its logon,
+		// so that replayed older events pair up before it
+		if open, ok := s.open[key]; ok {
+			events = append(events, sessionEvent{keyValues: open.Key, at: open.Start, logon: true, carry: open.Carry})
+		}
+		// logons sort before logoffs at the same time, so that a session
+		// of zero length is paired rather than orphaned
+		sort.SliceStable(events, func(i, j int) bool {
+			if !events[i].at.Equal(events[j].at) {
+				return events[i].at.Before(events[j].at)
+			}
+			return events[i].logon && !events[j].logon
+		})
+
+		var (
+			open   openSession
+			isOpen bool
+		)
+		for i, e := range events {
+			// the same event read from both history and log tables
+			if i > 0 && e.at.Equal(events[i-1].at) && e.logon == events[i-1].logon {
+				continue
+			}
+			if e.logon {
+				if isOpen {
+					emit(open, nil, sessionNoLogoff)
+				}
+				open, isOpen = openSession{Key: e.keyValues, Start: e.at, Carry: e.carry}, true
+				continue
+			}
+			at := e.at
+			if isOpen {
+				isOpen = false
+				// a logoff later than the timeout does not end the
+				// session; it has timed out, and the logoff has no logon
+				if at.Sub(open.Start) <= s.timeout {
+					emit(open, &at, sessionClosed)
+					continue
+				}
+				emit(open, nil, sessionTimedOut)
+			}
+			emit(openSession{Key: e.keyValues, Carry: e.carry}, &at, sessionOrphanLogoff)
+		}
+
+		if isOpen {
+			s.open[key] = open
+		} else {
+			delete(s.open, key)
+		}
+	}
+
+	if !latest.IsZero() {
+		for key, open := range s.open {
+			if latest.Sub(open.Start) > s.timeout {
+				emit(open, nil, sessionTimedOut)
+				delete(s.open, key)
+			}
+		}
+	}
+
+	log.Printf("Sessionized %d sessions (%d closed, %d timed out, %d without logoff, %d orphan logoffs), %d still open",
+		len(sessions), counts[sessionClosed], counts[sessionTimedOut], counts[sessionNoLogoff], counts[sessionOrphanLogoff], len(s.open))
+	s.events = make(map[string][]sessionEvent)
+	for _, r := range sessions {
+		if !emitRecord(r) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *sessionize) record(o openSession, end *time.Time, status string) pipeline.DataRecord {
+	r := make(pipeline.DataRecord, len(s.key)+len(o.Carry)+6)
+	for k, v := range o.Carry {
+		r[k] = v
+	}
+	for i, field := range s.key {
+		r[field] = o.Key[i]
+	}
+
+	r["session_start"] = nil
+	if !o.Start.IsZero() {
+		r["session_start"] = o.Start
+	}
+	r["session_end"] = nil
+	r["duration_seconds"] = nil
+	if end != nil {
+		r["session_end"] = *end
+		if !o.Start.IsZero() {
+			r["duration_seconds"] = int64(end.Sub(o.Start) / time.Second)
+		}
+	}
+	r["session_status"] = status
+	r[pipeline.FieldSourceTable] = s.table
+	return r
+}
+
+// Commit saves the sessions still open once the run has been loaded.
+func (s *sessionize) Commit(ctx context.Context) error {
+	if err := s.state.Put(s.stateKey, s.open); err != nil {
+		return err
+	}
+	return s.state.Save()
+}
+
+func (s *sessionize) report() {
+	s.policy.report()
+}
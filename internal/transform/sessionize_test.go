@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func TestSessionizeTimesOutWithinRun(t *testing.T) {
+	cfg := &config.PipelineConfig{}
+	cfg.Pipeline.StatePath = filepath.Join(t.TempDir(), "state.json")
+
+	c := &Chain{}
+	s, err := c.newSessionize(context.Background(), cfg, config.TransformationConfig{
+		Key:          []string{"dealer"},
+		TimeField:    "at",
+		FlagField:    "flag",
+		LogonValues:  []string{"I"},
+		LogoffValues: []string{"O"},
+		Timeout:      time.Hour,
+		Events:       "drop",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const t0 = 1760659200
+	for _, r := range []pipeline.DataRecord{
+		{"dealer": "D1", "flag": "I", "at": int64(t0)},
+		{"dealer": "D1", "flag": "O", "at": int64(t0 + 1800)},
+		{"dealer": "D2", "flag": "I", "at": int64(t0)},
+		{"dealer": "D2", "flag": "O", "at": int64(t0 + 7200)},
+		{"dealer": "D3", "flag": "I", "at": int64(t0 + 9000)},
+	} {
+		if _, err := s.apply(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses := make(map[string][]string)
+	if err := s.flush(func(r pipeline.DataRecord) bool {
+		dealer := r["dealer"].(string)
+		statuses[dealer] = append(statuses[dealer], r["session_status"].(string))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"D1": {sessionClosed},
+		"D2": {sessionTimedOut, sessionOrphanLogoff},
+	}
+	for dealer, w := range want {
+		got := statuses[dealer]
+		if len(got) != len(w) || got[0] != w[0] || got[len(got)-1] != w[len(w)-1] {
+			t.Errorf("%s: sessions %v, want %v", dealer, got, w)
+		}
+	}
+	if _, ok := statuses["D3"]; ok {
+		t.Errorf("D3 is within the timeout of the latest event and must stay open, got %v", statuses["D3"])
+	}
+	if _, ok := s.open[joinKey([]interface{}{"D3"})]; !ok {
+		t.Error("D3 not kept open")
+	}
+}
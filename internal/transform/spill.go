@@ -0,0 +1,112 @@
+package transform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// digest identifies a key by a hash of its canonical form, so that the
+// memory used per key does not depend on the key columns.
+type digest [16]byte
+
+func digestOf(key string) digest {
+	sum := sha256.Sum256([]byte(key))
+	var d digest
+	copy(d[:], sum[:])
+	return d
+}
+
+// spillFile is a temporary SQLite database under spill_dir for steps that
+// hold more than fits in memory. It is written in a single transaction
+// without a journal, since it is removed when the run ends.
+type spillFile struct {
+	path string
+	db   *sql.DB
+	tx   *sql.Tx
+}
+
+// The value types records hold, beyond the basic ones gob knows: nested
+// objects and arrays come from the JSON sources.
+func init() {
+	gob.Register(time.Time{})
+	gob.Register(json.Number(""))
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// openSpillFile creates a spill file named after prefix in dir and runs the
+// schema statements in it.
+func openSpillFile(dir, prefix string, schema ...string) (*spillFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spill directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, prefix+"-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	f.Close()
+
+	s := &spillFile{path: f.Name()}
+	if s.db, err = sql.Open("sqlite", "file:"+s.path); err != nil {
+		os.Remove(s.path)
+		return nil, fmt.Errorf("failed to open spill file %s: %w", s.path, err)
+	}
+	s.db.SetMaxOpenConns(1)
+
+	for _, stmt := range append([]string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF"}, schema...) {
+		if _, err := s.db.Exec(stmt); err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to prepare spill file %s: %w", s.path, err)
+		}
+	}
+	if s.tx, err = s.db.Begin(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("failed to prepare spill file %s: %w", s.path, err)
+	}
+	return s, nil
+}
+
+// prepare prepares the queries into the statements they are paired with.
+func (s *spillFile) prepare(queries map[**sql.Stmt]string) error {
+	for stmt, query := range queries {
+		var err error
+		if *stmt, err = s.tx.Prepare(query); err != nil {
+			return fmt.Errorf("failed to prepare spill file %s: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+func (s *spillFile) close() error {
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+	err := s.db.Close()
+	if rerr := os.Remove(s.path); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func encodeSpilled(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("cannot spill to disk: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSpilled(data []byte, v interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("cannot read spilled data: %w", err)
+	}
+	return nil
+}
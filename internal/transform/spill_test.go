@@ -0,0 +1,76 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// nestedRecord is shaped like a record from the JSON Lines or HTTP sources.
+func nestedRecord(id string) pipeline.DataRecord {
+	return pipeline.DataRecord{
+		"id":      id,
+		"n":       json.Number("42"),
+		"at":      time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+		"device":  map[string]interface{}{"os": "android", "tags": []interface{}{"a", json.Number("1")}},
+		"history": []interface{}{map[string]interface{}{"ok": true}, nil},
+	}
+}
+
+func TestSpillNestedRecord(t *testing.T) {
+	want := nestedRecord("r1")
+	data, err := encodeSpilled(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got pipeline.DataRecord
+	if err := decodeSpilled(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("spilled record changed:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDedupSpillsNestedRecords(t *testing.T) {
+	c := &Chain{dedups: make(map[string]*dedupStats)}
+	d, err := c.newDedup(config.TransformationConfig{
+		Key:      []string{"id"},
+		Keep:     "latest",
+		Window:   1,
+		SpillDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, id := range []string{"r1", "r2", "r1", "r3"} {
+		if _, err := d.apply(nestedRecord(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out []pipeline.DataRecord
+	if err := d.flush(func(r pipeline.DataRecord) bool {
+		out = append(out, r)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 3 {
+		t.Fatalf("got %d records, want 3", len(out))
+	}
+	if d.stats.Spilled.Load() == 0 {
+		t.Fatal("no records were spilled")
+	}
+	for _, r := range out {
+		if !reflect.DeepEqual(r["device"], nestedRecord("")["device"]) {
+			t.Fatalf("nested object changed: %#v", r["device"])
+		}
+	}
+}
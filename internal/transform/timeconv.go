@@ -0,0 +1,335 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+
+	// Embed the timezone database so that zones such as Asia/Kolkata
+	// resolve on hosts without tzdata.
+	_ "time/tzdata"
+)
+
+var (
+	defaultMinTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	defaultMaxTime = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// epochUnits maps the epoch forms of from onto the length of their unit.
+var epochUnits = map[string]time.Duration{
+	"epoch_seconds": time.Second,
+	"epoch_millis":  time.Millisecond,
+	"epoch_micros":  time.Microsecond,
+}
+
+// datetimeLayouts are the string forms accepted by from: datetime, which
+// covers SQL Server datetime and datetime2 values as well as ISO 8601.
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// timeParts are the fields convert_time can emit from the converted time.
+var timeParts = map[string]func(time.Time) interface{}{
+	"date":    func(t time.Time) interface{} { return t.Format("2006-01-02") },
+	"year":    func(t time.Time) interface{} { return int64(t.Year()) },
+	"month":   func(t time.Time) interface{} { return int64(t.Month()) },
+	"day":     func(t time.Time) interface{} { return int64(t.Day()) },
+	"hour":    func(t time.Time) interface{} { return int64(t.Hour()) },
+	"minute":  func(t time.Time) interface{} { return int64(t.Minute()) },
+	"weekday": func(t time.Time) interface{} { return t.Weekday().String() },
+	"week": func(t time.Time) interface{} {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+	"week_start": func(t time.Time) interface{} {
+		offset := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -offset).Format("2006-01-02")
+	},
+}
+
+// convertTime parses a column holding an epoch count, a datetime or a
+// formatted string into a time.Time in the configured timezone, and
+// optionally emits calendar fields derived from it. Values outside min and
+// max are rejected, which catches epoch values in the wrong unit.
+type convertTime struct {
+	column   string
+	target   string
+	from     string
+	layout   string
+	source   *time.Location
+	location *time.Location
+	min, max time.Time
+	emit     []emitField
+	policy   *errorPolicy
+}
+
+type emitField struct {
+	part  func(time.Time) interface{}
+	field string
+}
+
+func (c *Chain) newConvertTime(cfg *config.PipelineConfig, tc config.TransformationConfig) (*convertTime, error) {
+	if tc.ColumnName == "" {
+		return nil, fmt.Errorf("convert_time requires column_name")
+	}
+	t := &convertTime{
+		column: tc.ColumnName,
+		target: tc.Target,
+		from:   tc.From,
+		layout: tc.Layout,
+		min:    defaultMinTime,
+		max:    defaultMaxTime,
+	}
+	if t.target == "" {
+		t.target = t.column
+	}
+
+	switch {
+	case epochUnits[t.from] != 0, t.from == "datetime":
+		if t.layout != "" {
+			return nil, fmt.Errorf("convert_time layout requires from: layout")
+		}
+	case t.from == "layout":
+		if t.layout == "" {
+			return nil, fmt.Errorf("convert_time from: layout requires layout")
+		}
+	default:
+		return nil, fmt.Errorf("convert_time requires from epoch_seconds, epoch_millis, epoch_micros, datetime or layout, got %q", t.from)
+	}
+
+	var err error
+	if t.source, err = loadLocation(tc.SourceTimezone); err != nil {
+		return nil, fmt.Errorf("convert_time source_timezone: %w", err)
+	}
+	if t.location, err = loadLocation(tc.Timezone); err != nil {
+		return nil, fmt.Errorf("convert_time timezone: %w", err)
+	}
+
+	if tc.Min != "" {
+		if t.min, err = parseBound(tc.Min); err != nil {
+			return nil, fmt.Errorf("convert_time min: %w", err)
+		}
+	}
+	if tc.Max != "" {
+		if t.max, err = parseBound(tc.Max); err != nil {
+			return nil, fmt.Errorf("convert_time max: %w", err)
+		}
+	}
+	if !t.min.Before(t.max) {
+		return nil, fmt.Errorf("convert_time min must be before max")
+	}
+
+	parts := make([]string, 0, len(tc.Emit))
+	for part := range tc.Emit {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	for _, part := range parts {
+		fn, ok := timeParts[part]
+		if !ok {
+			return nil, fmt.Errorf("convert_time cannot emit %q", part)
+		}
+		if tc.Emit[part] == "" {
+			return nil, fmt.Errorf("convert_time emit %s requires a field name", part)
+		}
+		t.emit = append(t.emit, emitField{part: fn, field: tc.Emit[part]})
+	}
+
+	if t.policy, err = c.newErrorPolicy(cfg, "convert_time "+t.column, tc.OnError, true); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// loadLocation resolves an IANA zone name. An empty name is UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+func parseBound(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC 3339", s)
+}
+
+func (t *convertTime) apply(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	v := record[t.column]
+	if v == nil {
+		t.set(record, nil)
+		return record, nil
+	}
+
+	ts, err := t.convert(v)
+	if err == nil && (ts.Before(t.min) || !ts.Before(t.max)) {
+		err = fmt.Errorf("%v is out of range [%s, %s)", v, t.min.Format(time.RFC3339), t.max.Format(time.RFC3339))
+	}
+	if err != nil {
+		err = fmt.Errorf("convert_time %s: %w", t.column, err)
+		if t.policy.nulls() {
+			t.set(record, nil)
+		}
+		return t.policy.handle(record, err)
+	}
+
+	t.set(record, &ts)
+	return record, nil
+}
+
+func (t *convertTime) set(record pipeline.DataRecord, ts *time.Time) {
+	if ts == nil {
+		record[t.target] = nil
+		for _, e := range t.emit {
+			record[e.field] = nil
+		}
+		return
+	}
+	local := ts.In(t.location)
+	record[t.target] = local
+	for _, e := range t.emit {
+		record[e.field] = e.part(local)
+	}
+}
+
+func (t *convertTime) convert(v interface{}) (time.Time, error) {
+	if unit, ok := epochUnits[t.from]; ok {
+		return epochTime(v, unit)
+	}
+
+	switch value := v.(type) {
+	case time.Time:
+		if t.from == "layout" || value.Location() != time.UTC {
+			return value, nil
+		}
+		// SQL Server datetime has no zone; drivers return its wall clock
+		// as UTC, so it is read again in the source timezone. Values with
+		// a zone, such as datetimeoffset, already name their instant.
+		y, m, d := value.Date()
+		return time.Date(y, m, d, value.Hour(), value.Minute(), value.Second(), value.Nanosecond(), t.source), nil
+	case []byte:
+		return t.parse(string(value))
+	case string:
+		return t.parse(value)
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %T to a time", v)
+}
+
+func (t *convertTime) parse(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t.from == "layout" {
+		ts, err := time.ParseInLocation(t.layout, s, t.source)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q with layout %q", s, t.layout)
+		}
+		return ts, nil
+	}
+	for _, layout := range datetimeLayouts {
+		if ts, err := time.ParseInLocation(layout, s, t.source); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a datetime", s)
+}
+
+// fieldTime reads a time field holding either a time or a count of units
+// since the Unix epoch.
+func fieldTime(v interface{}, unit time.Duration) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC(), nil
+	}
+	return epochTime(v, unit)
+}
+
+// epochTime converts a count of units since the Unix epoch. Integers are
+// converted exactly; fractional values are rounded to the nanosecond.
+func epochTime(v interface{}, unit time.Duration) (time.Time, error) {
+	var (
+		i       int64
+		f       float64
+		integer bool
+	)
+	switch n := v.(type) {
+	case int:
+		i, integer = int64(n), true
+	case int8:
+		i, integer = int64(n), true
+	case int16:
+		i, integer = int64(n), true
+	case int32:
+		i, integer = int64(n), true
+	case int64:
+		i, integer = n, true
+	case uint:
+		return epochTime(uint64(n), unit)
+	case uint8:
+		i, integer = int64(n), true
+	case uint16:
+		i, integer = int64(n), true
+	case uint32:
+		i, integer = int64(n), true
+	case uint64:
+		if n > math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("%d is out of range", n)
+		}
+		i, integer = int64(n), true
+	case float32:
+		f = float64(n)
+	case float64:
+		f = n
+	case json.Number:
+		var err error
+		if i, err = n.Int64(); err == nil {
+			integer = true
+		} else if f, err = n.Float64(); err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a number", n)
+		}
+	case string, []byte:
+		s := strings.TrimSpace(fmt.Sprintf("%s", n))
+		var err error
+		if i, err = strconv.ParseInt(s, 10, 64); err == nil {
+			integer = true
+		} else if f, err = strconv.ParseFloat(s, 64); err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a number", s)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("cannot convert %T to an epoch time", v)
+	}
+
+	perSecond := int64(time.Second / unit)
+	if integer {
+		return time.Unix(i/perSecond, (i%perSecond)*int64(unit)).UTC(), nil
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, fmt.Errorf("%v is not a finite number", f)
+	}
+	secs := f / float64(perSecond)
+	// beyond what time.Time can hold in int64 nanoseconds; the range check
+	// rejects these anyway
+	if math.Abs(secs) > 1<<62/1e9 {
+		return time.Time{}, fmt.Errorf("%v is out of range", v)
+	}
+	whole := math.Floor(secs)
+	return time.Unix(int64(whole), int64(math.Round((secs-whole)*1e9))).UTC(), nil
+}
+
+func (t *convertTime) parallel() bool { return true }
+
+func (t *convertTime) report() {
+	t.policy.report()
+}
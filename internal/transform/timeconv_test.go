@@ -0,0 +1,181 @@
+package transform
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+func testConvertTime(t *testing.T, tc config.TransformationConfig) *convertTime {
+	t.Helper()
+	tc.ColumnName = "at"
+	c := &Chain{}
+	step, err := c.newConvertTime(&config.PipelineConfig{}, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return step
+}
+
+func TestEpochTime(t *testing.T) {
+	want := time.Unix(100, 0).UTC()
+	for _, v := range []interface{}{
+		int(100), int8(100), int16(100), int32(100), int64(100),
+		uint(100), uint8(100), uint16(100), uint32(100), uint64(100),
+		float32(100), float64(100), json.Number("100"), "100", []byte(" 100 "),
+	} {
+		got, err := epochTime(v, time.Second)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("epochTime(%T %v) = %v, %v, want %v", v, v, got, err, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		v    interface{}
+		unit time.Duration
+		want time.Time
+	}{
+		{int64(1760693415123), time.Millisecond, time.Unix(1760693415, 123e6)},
+		{int64(-1500), time.Millisecond, time.Unix(-2, 5e8)},
+		{int64(1760693415123456), time.Microsecond, time.Unix(1760693415, 123456e3)},
+		{1.5, time.Second, time.Unix(1, 5e8)},
+		{"2.25", time.Millisecond, time.Unix(0, 2250000)},
+	} {
+		got, err := epochTime(tc.v, tc.unit)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("epochTime(%v, %s) = %v, %v, want %v", tc.v, tc.unit, got, err, tc.want)
+		}
+	}
+
+	for _, v := range []interface{}{uint64(math.MaxUint64), math.NaN(), math.Inf(1), 1e300, "abc", true} {
+		if got, err := epochTime(v, time.Second); err == nil {
+			t.Errorf("epochTime(%v) = %v, want an error", v, got)
+		}
+	}
+}
+
+func TestConvertTimeDatetime(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := testConvertTime(t, config.TransformationConfig{
+		From:           "datetime",
+		SourceTimezone: "Asia/Kolkata",
+		Timezone:       "UTC",
+	})
+	wall := time.Date(2026, 10, 17, 9, 30, 0, 0, kolkata)
+
+	for _, tc := range []struct {
+		name string
+		in   interface{}
+		want time.Time
+	}{
+		// zone-less datetime, as drivers return it
+		{"datetime", time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC), wall},
+		// datetimeoffset keeps its instant
+		{"offset", time.Date(2026, 10, 17, 9, 30, 0, 0, time.FixedZone("", -4*3600)), time.Date(2026, 10, 17, 13, 30, 0, 0, time.UTC)},
+		{"local zone", wall.In(time.FixedZone("IST", 19800)), wall},
+		{"string", "2026-10-17 09:30:00", wall},
+		{"bytes", []byte("2026-10-17T09:30:00"), wall},
+		{"string with zone", "2026-10-17T09:30:00Z", time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)},
+		{"date", "2026-10-17", time.Date(2026, 10, 17, 0, 0, 0, 0, kolkata)},
+	} {
+		record, err := step.apply(pipeline.DataRecord{"at": tc.in})
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		got := record["at"].(time.Time)
+		if !got.Equal(tc.want) || got.Location() != time.UTC {
+			t.Errorf("%s: %v gives %v, want %v in UTC", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestConvertTimeEmitAndRange(t *testing.T) {
+	step := testConvertTime(t, config.TransformationConfig{
+		Target:   "login_ts",
+		From:     "epoch_millis",
+		Timezone: "Asia/Kolkata",
+		Emit: map[string]string{
+			"date": "login_date", "hour": "login_hour", "week": "login_week",
+			"weekday": "login_weekday", "week_start": "login_week_start",
+		},
+		OnError: "null",
+	})
+
+	// 2026-10-17 20:00 UTC is the 18th in Kolkata
+	record, err := step.apply(pipeline.DataRecord{"at": int64(1792267200000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]interface{}{
+		"login_date":       "2026-10-18",
+		"login_hour":       int64(1),
+		"login_week":       "2026-W42",
+		"login_weekday":    "Sunday",
+		"login_week_start": "2026-10-12",
+	} {
+		if record[field] != want {
+			t.Errorf("%s = %#v, want %#v", field, record[field], want)
+		}
+	}
+	if record["at"] != int64(1792267200000) {
+		t.Error("target overwrote column_name")
+	}
+
+	// seconds read as milliseconds fall before min and are nulled
+	record, err = step.apply(pipeline.DataRecord{"at": int64(1792267200)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["login_ts"] != nil || record["login_date"] != nil {
+		t.Errorf("out of range value gave %v", record)
+	}
+
+	record, err = step.apply(pipeline.DataRecord{"at": nil})
+	if err != nil || record["login_ts"] != nil || record["login_hour"] != nil {
+		t.Errorf("null gave %v, %v", record, err)
+	}
+}
+
+func TestConvertTimeLayoutAndErrors(t *testing.T) {
+	step := testConvertTime(t, config.TransformationConfig{
+		From:           "layout",
+		Layout:         "02/01/2006 15:04",
+		SourceTimezone: "Asia/Kolkata",
+	})
+	record, err := step.apply(pipeline.DataRecord{"at": "17/10/2026 09:30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := record["at"].(time.Time); !got.Equal(time.Date(2026, 10, 17, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("layout gave %v", got)
+	}
+	if _, err := step.apply(pipeline.DataRecord{"at": "2026-10-17"}); err == nil || !strings.Contains(err.Error(), "convert_time at: cannot parse") {
+		t.Errorf("got %v, want a parse error", err)
+	}
+
+	for _, tc := range []config.TransformationConfig{
+		{From: "epoch"},
+		{From: "layout"},
+		{From: "datetime", Layout: "2006"},
+		{From: "datetime", Timezone: "Mars/Olympus"},
+		{From: "datetime", Min: "2030-01-01", Max: "2020-01-01"},
+		{From: "datetime", Min: "yesterday"},
+		{From: "datetime", Emit: map[string]string{"fortnight": "f"}},
+		{From: "datetime", Emit: map[string]string{"date": ""}},
+		{From: "datetime", OnError: "ignore"},
+	} {
+		tc.ColumnName = "at"
+		if _, err := (&Chain{}).newConvertTime(&config.PipelineConfig{}, tc); err == nil {
+			t.Errorf("%+v accepted", tc)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	"github.com/aniketwaliyan/etl-framework/internal/utils/config"
+)
+
+// step transforms one record. It returns nil to drop the record.
+type step interface {
+	apply(record pipeline.DataRecord) (pipeline.DataRecord, error)
+}
+
+// flusher is implemented by steps that hold records back until the input
+// is exhausted. The records passed to emit go through the steps after it;
+// emit returns false once the run is stopping, and flush then returns.
+type flusher interface {
+	flush(emit func(pipeline.DataRecord) bool) error
+}
+
+// parallel is implemented by steps that may run on several workers at once:
+// they keep no state between records and are safe for concurrent use.
+type parallel interface {
+	parallel() bool
+}
+
+// reporter is implemented by steps that log a summary when the input is
+// exhausted.
+type reporter interface {
+	report()
+}
+
+// Chain applies the configured transformations to each record in order.
+// Every step is prepared at Init, so configuration errors such as an invalid
+// expression fail the run before any data is read.
+type Chain struct {
+	steps      []step
+	deadLetter *pipeline.DeadLetter
+	masking    []MaskPolicy
+	lookups    map[string]*lookupStats
+	dedups     map[string]*dedupStats
+	checks     map[string]*checkReport
+	batch      config.BatchConfig
+}
+
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+func (c *Chain) Init(ctx context.Context, cfg *config.PipelineConfig) error {
+	c.steps = nil
+	c.masking = nil
+	c.lookups = make(map[string]*lookupStats)
+	c.dedups = make(map[string]*dedupStats)
+	c.checks = make(map[string]*checkReport)
+	c.batch = cfg.Pipeline.Batch
+	if cfg.Schema.Infer || len(cfg.Schema.Fields) > 0 {
+		s, err := c.newSchemaCheck(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("schema: %w", err)
+		}
+		c.steps = append(c.steps, s)
+	}
+	for i, tc := range cfg.Transformations {
+		s, err := c.newStep(ctx, cfg, tc)
+		if err != nil {
+			return fmt.Errorf("transformations[%d]: %w", i, err)
+		}
+		c.steps = append(c.steps, s)
+	}
+	run := pipeline.RunFromContext(ctx)
+	if len(c.masking) > 0 {
+		run.Set("masking", c.masking)
+	}
+	if len(c.lookups) > 0 {
+		run.Set("lookups", c.lookups)
+	}
+	if len(c.dedups) > 0 {
+		run.Set("dedup", c.dedups)
+	}
+	if len(c.checks) > 0 {
+		run.Set("checks", c.checks)
+	}
+	return nil
+}
+
+// joinKey joins the canonical forms of key values, so that "42" and 42 from
+// different sources are the same key.
+func joinKey(values []interface{}) string {
+	return strings.Join(keyParts(values), "\x1f")
+}
+
+func keyParts(values []interface{}) []string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strings.TrimSpace(canonical(v))
+	}
+	return parts
+}
+
+// compareValues orders two non-null values. Times compare as times, numbers
+// and numeric strings as numbers, and anything else by its text.
+func compareValues(a, b interface{}) int {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	sa, sb := strings.TrimSpace(canonical(a)), strings.TrimSpace(canonical(b))
+	if ia, err := strconv.ParseInt(sa, 10, 64); err == nil {
+		if ib, err := strconv.ParseInt(sb, 10, 64); err == nil {
+			switch {
+			case ia < ib:
+				return -1
+			case ia > ib:
+				return 1
+			}
+			return 0
+		}
+	}
+	if fa, err := strconv.ParseFloat(sa, 64); err == nil {
+		if fb, err := strconv.ParseFloat(sb, 64); err == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(sa, sb)
+}
+
+// newStep returns the step registered for the transformation's type.
+func (c *Chain) newStep(ctx context.Context, cfg *config.PipelineConfig, tc config.TransformationConfig) (step, error) {
+	switch tc.Kind() {
+	case "filter":
+		return newFilter(tc)
+	case "derive":
+		return newDerive(tc)
+	case "add_column":
+		return newAddColumn(tc)
+	case "convert_time":
+		return c.newConvertTime(cfg, tc)
+	case "mask":
+		return c.newMask(tc)
+	case "sessionize":
+		return c.newSessionize(ctx, cfg, tc)
+	case "lookup":
+		return c.newLookup(ctx, cfg, tc)
+	case "dedup":
+		return c.newDedup(tc)
+	case "aggregate":
+		return c.newAggregate(ctx, cfg, tc)
+	case "check":
+		return c.newChecks(tc)
+	case "":
+		return nil, fmt.Errorf("transformation type is required")
+	default:
+		return nil, fmt.Errorf("unsupported transformation type: %s", tc.Kind())
+	}
+}
+
+func (c *Chain) Transform(ctx context.Context, input <-chan pipeline.DataRecord) (<-chan pipeline.DataRecord, <-chan error) {
+	if len(c.steps) == 0 {
+		return input, make(chan error)
+	}
+	return c.transform(ctx, input, nil, 0)
+}
+
+// TransformParallel runs the leading steps that keep no state between
+// records on workers goroutines, and the steps from the first one that does
+// on one goroutine, as Transform does.
+func (c *Chain) TransformParallel(ctx context.Context, input <-chan pipeline.DataRecord, workers int, ordered bool) (<-chan pipeline.DataRecord, <-chan error) {
+	n := c.parallelSteps(workers)
+	if n == 0 {
+		return c.Transform(ctx, input)
+	}
+	head := c.steps[:n]
+	records, errs := pipeline.RunWorkers(ctx, input, workers, ordered, func(record pipeline.DataRecord) (pipeline.DataRecord, error) {
+		return applySteps(head, record)
+	})
+	return c.transform(ctx, records, errs, n)
+}
+
+// TransformBatches is TransformParallel for batches. With one worker every
+// step runs on one goroutine.
+func (c *Chain) TransformBatches(ctx context.Context, input <-chan pipeline.Batch, workers int, ordered bool) (<-chan pipeline.Batch, <-chan error) {
+	if len(c.steps) == 0 {
+		return input, make(chan error)
+	}
+	n := c.parallelSteps(workers)
+	if n == 0 {
+		return c.transformBatches(ctx, input, nil, 0)
+	}
+	head := c.steps[:n]
+	batches, errs := pipeline.RunBatchWorkers(ctx, input, workers, ordered, func(batch pipeline.Batch) (pipeline.Batch, error) {
+		return applyBatch(head, batch)
+	})
+	return c.transformBatches(ctx, batches, errs, n)
+}
+
+// parallelSteps returns how many of the leading steps may run on workers
+// goroutines at once, and logs how the chain will run.
+func (c *Chain) parallelSteps(workers int) int {
+	if workers <= 1 {
+		return 0
+	}
+	n := 0
+	for n < len(c.steps) {
+		if p, ok := c.steps[n].(parallel); !ok || !p.parallel() {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		if len(c.steps) > 0 {
+			log.Printf("The first transformation keeps state between records, transforming on one worker")
+		}
+		return 0
+	}
+	log.Printf("Transforming on %d workers (%d of %d transformations)", workers, n, len(c.steps))
+	return n
+}
+
+// applySteps passes record through steps in order, stopping at the first
+// step that drops it.
+func applySteps(steps []step, record pipeline.DataRecord) (pipeline.DataRecord, error) {
+	var err error
+	for _, s := range steps {
+		if record, err = s.apply(record); err != nil || record == nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// applyBatch passes each record of batch through steps, keeping the records
+// that are not dropped. The batch is reused for the result.
+func applyBatch(steps []step, batch pipeline.Batch) (pipeline.Batch, error) {
+	out := batch[:0]
+	for _, record := range batch {
+		record, err := applySteps(steps, record)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			out = append(out, record)
+		}
+	}
+	return out, nil
+}
+
+// transform applies the steps from first on to input, flushes them once the
+// input ends and logs the summary of every step. Records on input have been
+// through the steps before first; an error on upstream, which is read once
+// input is closed, fails the transformation.
+func (c *Chain) transform(ctx context.Context, input <-chan pipeline.DataRecord, upstream <-chan error, first int) (<-chan pipeline.DataRecord, <-chan error) {
+	output := make(chan pipeline.DataRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(output)
+		defer close(errs)
+
+		fail := func(err error) {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+			}
+		}
+		send := func(record pipeline.DataRecord, steps []step) bool {
+			record, err := applySteps(steps, record)
+			if err != nil {
+				fail(err)
+				return false
+			}
+			if record == nil {
+				return true
+			}
+			select {
+			case output <- record:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for record := range input {
+			if !send(record, c.steps[first:]) {
+				return
+			}
+		}
+		c.finish(upstream, first, send, fail)
+	}()
+
+	return output, errs
+}
+
+// transformBatches is transform for batches. Each batch on input leaves as
+// one batch, less the records the steps drop; the records the steps flush
+// at the end are batched again.
+func (c *Chain) transformBatches(ctx context.Context, input <-chan pipeline.Batch, upstream <-chan error, first int) (<-chan pipeline.Batch, <-chan error) {
+	output := make(chan pipeline.Batch)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(output)
+		defer close(errs)
+
+		fail := func(err error) {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+			}
+		}
+
+		for batch := range input {
+			batch, err := applyBatch(c.steps[first:], batch)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if len(batch) == 0 {
+				continue
+			}
+			select {
+			case output <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		sender := pipeline.NewBatchSender(ctx, output, c.batch)
+		defer sender.Stop()
+		send := func(record pipeline.DataRecord, steps []step) bool {
+			record, err := applySteps(steps, record)
+			if err != nil {
+				fail(err)
+				return false
+			}
+			return record == nil || sender.Send(record) == nil
+		}
+		if c.finish(upstream, first, send, fail) {
+			sender.Flush()
+		}
+	}()
+
+	return output, errs
+}
+
+// finish reads the upstream error once the input has ended, flushes the
+// steps from first on through send and logs the summary of every step. It
+// returns false if the transformation failed or is stopping.
+func (c *Chain) finish(upstream <-chan error, first int, send func(pipeline.DataRecord, []step) bool, fail func(error)) bool {
+	if upstream != nil {
+		if err := <-upstream; err != nil {
+			fail(err)
+			return false
+		}
+	}
+
+	for i := first; i < len(c.steps); i++ {
+		f, ok := c.steps[i].(flusher)
+		if !ok {
+			continue
+		}
+		stopped := false
+		err := f.flush(func(record pipeline.DataRecord) bool {
+			stopped = !send(record, c.steps[i+1:])
+			return !stopped
+		})
+		if err != nil {
+			fail(err)
+			return false
+		}
+		if stopped {
+			return false
+		}
+	}
+
+	for _, s := range c.steps {
+		if r, ok := s.(reporter); ok {
+			r.report()
+		}
+	}
+	return true
+}
+
+// Commit lets stateful steps persist their state once the records of the
+// run have been loaded.
+func (c *Chain) Commit(ctx context.Context) error {
+	for _, s := range c.steps {
+		if committer, ok := s.(pipeline.Committer); ok {
+			if err := committer.Commit(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Chain) Close() error {
+	var first error
+	for _, s := range c.steps {
+		if closer, ok := s.(io.Closer); ok {
+			if err := closer.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	if c.deadLetter != nil {
+		if err := c.deadLetter.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
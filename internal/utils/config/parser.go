@@ -47,8 +47,37 @@ func (p *Parser) validate(config *PipelineConfig) error {
 	if config.Source.Type == "" {
 		return fmt.Errorf("source type is required")
 	}
-	if config.Sink.Type == "" {
-		return fmt.Errorf("sink type is required")
+	if len(config.Sinks) == 0 {
+		if config.Sink.Type == "" {
+			return fmt.Errorf("sink type is required")
+		}
+		if config.Reconcile.Enabled() && config.Sink.Type != "postgres" && config.Sink.Type != "sqlite" {
+			return fmt.Errorf("reconcile requires a postgres or sqlite sink")
+		}
+		return nil
+	}
+	if config.Reconcile.Enabled() {
+		return fmt.Errorf("reconcile requires a single sink")
+	}
+
+	if config.Sink.Type != "" {
+		return fmt.Errorf("use either sink or sinks, not both")
+	}
+	names := make(map[string]bool)
+	for i, sink := range config.Sinks {
+		if sink.Type == "" {
+			return fmt.Errorf("sinks[%d]: type is required", i)
+		}
+		name := sink.DisplayName(i)
+		if names[name] {
+			return fmt.Errorf("duplicate sink name: %s", name)
+		}
+		names[name] = true
+		switch sink.Policy {
+		case "", "required", "best_effort":
+		default:
+			return fmt.Errorf("sink %s: unsupported policy %s", name, sink.Policy)
+		}
 	}
 	return nil
 }
@@ -1,35 +1,569 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 type PipelineConfig struct {
 	Pipeline struct {
-		Name        string        `yaml:"name"`
-		Description string        `yaml:"description"`
-		Schedule    string        `yaml:"schedule"`
-		Retries     int           `yaml:"retries"`
-		RetryDelay  time.Duration `yaml:"retry_delay"`
+		Name        string           `yaml:"name"`
+		Description string           `yaml:"description"`
+		Schedule    string           `yaml:"schedule"`
+		Retries     int              `yaml:"retries"`
+		RetryDelay  time.Duration    `yaml:"retry_delay"`
+		DeadLetter  DeadLetterConfig `yaml:"dead_letter,omitempty"`
+		StatePath   string           `yaml:"state_path,omitempty"`
+		RunsDir     string           `yaml:"runs_dir,omitempty"`
+
+		// Workers is the number of goroutines that transform records;
+		// PreserveOrder keeps the records in the order they were read.
+		Workers       int  `yaml:"workers,omitempty"`
+		PreserveOrder bool `yaml:"preserve_order,omitempty"`
+
+		Batch BatchConfig `yaml:"batch,omitempty"`
 	} `yaml:"pipeline"`
 
-	Source struct {
-		Type     string   `yaml:"type"`
-		Servers  []string `yaml:"servers"`
-		Database string   `yaml:"database"`
-		Table    string   `yaml:"table"`
-	} `yaml:"source"`
+	Source SourceConfig `yaml:"source"`
+
+	Sink SinkConfig `yaml:"sink"`
 
-	Sink struct {
-		Type     string `yaml:"type"`
-		Server   string `yaml:"server"`
-		Database string `yaml:"database"`
-		Table    string `yaml:"table"`
-	} `yaml:"sink"`
+	// Sinks replaces Sink when records are written to more than one sink.
+	Sinks []SinkConfig `yaml:"sinks,omitempty"`
+
+	// Schema validates and coerces records before the transformations.
+	Schema SchemaConfig `yaml:"schema,omitempty"`
 
 	Transformations []TransformationConfig `yaml:"transformations"`
+
+	// Reconcile compares what was loaded with the source after each run.
+	Reconcile ReconcileConfig `yaml:"reconcile,omitempty"`
+
+	// Alerts says where problems found during a run are reported.
+	Alerts AlertConfig `yaml:"alerts,omitempty"`
+
+	Storage StorageConfig `yaml:"storage,omitempty"`
+}
+
+// SourceConfig describes where records are read from. OnError decides what
+// happens to a row of a csv, tsv or jsonl file that cannot be parsed: fail,
+// skip or dead_letter.
+type SourceConfig struct {
+	Type        string            `yaml:"type"`
+	Servers     []string          `yaml:"servers"`
+	Database    string            `yaml:"database"`
+	Table       string            `yaml:"table"`
+	Path        string            `yaml:"path,omitempty"`
+	Paths       []string          `yaml:"paths,omitempty"`
+	Tables      []TableConfig     `yaml:"tables,omitempty"`
+	Columns     []ColumnConfig    `yaml:"columns,omitempty"`
+	Compression string            `yaml:"compression,omitempty"`
+	CSV         CSVConfig         `yaml:"csv,omitempty"`
+	Select      []string          `yaml:"select,omitempty"`
+	Where       []PredicateConfig `yaml:"where,omitempty"`
+	HTTP        HTTPConfig        `yaml:"http,omitempty"`
+	Kafka       KafkaConfig       `yaml:"kafka,omitempty"`
+	SFTP        SFTPConfig        `yaml:"sftp,omitempty"`
+	OnError     string            `yaml:"on_error,omitempty"`
+}
+
+// Files returns path followed by paths, for sources that read files.
+func (s SourceConfig) Files() []string {
+	var files []string
+	if s.Path != "" {
+		files = append(files, s.Path)
+	}
+	return append(files, s.Paths...)
+}
+
+// PredicateConfig is a comparison that every extracted record must satisfy.
+// Op is one of =, !=, <, <=, >, >=.
+type PredicateConfig struct {
+	Column string      `yaml:"column"`
+	Op     string      `yaml:"op"`
+	Value  interface{} `yaml:"value"`
+}
+
+// HTTPConfig describes requests made by the HTTP source and sink. URL is a
+// text/template; see the README for the fields available to it.
+type HTTPConfig struct {
+	URL            string            `yaml:"url"`
+	Method         string            `yaml:"method,omitempty"`
+	Headers        map[string]string `yaml:"headers,omitempty"`
+	Auth           AuthConfig        `yaml:"auth,omitempty"`
+	Timeout        time.Duration     `yaml:"timeout,omitempty"`
+	Retries        int               `yaml:"retries,omitempty"`
+	RetryDelay     time.Duration     `yaml:"retry_delay,omitempty"`
+	RateLimit      float64           `yaml:"rate_limit,omitempty"`
+	RecordsPath    string            `yaml:"records_path,omitempty"`
+	IdempotencyKey string            `yaml:"idempotency_key,omitempty"`
+	Pagination     PaginationConfig  `yaml:"pagination,omitempty"`
+}
+
+// AuthConfig holds credentials for HTTP requests. Secret values are resolved
+// with the secrets package, so they may be env: or file: references.
+type AuthConfig struct {
+	Type         string   `yaml:"type,omitempty"`
+	Token        string   `yaml:"token,omitempty"`
+	Username     string   `yaml:"username,omitempty"`
+	Password     string   `yaml:"password,omitempty"`
+	TokenURL     string   `yaml:"token_url,omitempty"`
+	ClientID     string   `yaml:"client_id,omitempty"`
+	ClientSecret string   `yaml:"client_secret,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+}
+
+// PaginationConfig selects how the HTTP source walks through pages: "page",
+// "offset", "cursor", "link", or "" for a single request.
+type PaginationConfig struct {
+	Type        string `yaml:"type,omitempty"`
+	PageParam   string `yaml:"page_param,omitempty"`
+	StartPage   int    `yaml:"start_page,omitempty"`
+	SizeParam   string `yaml:"size_param,omitempty"`
+	PageSize    int    `yaml:"page_size,omitempty"`
+	OffsetParam string `yaml:"offset_param,omitempty"`
+	CursorParam string `yaml:"cursor_param,omitempty"`
+	CursorPath  string `yaml:"cursor_path,omitempty"`
+	MaxPages    int    `yaml:"max_pages,omitempty"`
+}
+
+// CSVConfig controls how delimited text files are parsed. Header defaults to
+// true and InferRows to 100; a negative InferRows disables type inference.
+type CSVConfig struct {
+	Delimiter  string   `yaml:"delimiter,omitempty"`
+	Quoting    string   `yaml:"quoting,omitempty"`
+	Header     *bool    `yaml:"header,omitempty"`
+	SkipRows   int      `yaml:"skip_rows,omitempty"`
+	Encoding   string   `yaml:"encoding,omitempty"`
+	InferRows  int      `yaml:"infer_rows,omitempty"`
+	NullValues []string `yaml:"null_values,omitempty"`
+}
+
+// SinkConfig describes where records are loaded. User and Password are the
+// postgres credentials; Password is resolved through the secrets package.
+type SinkConfig struct {
+	Name          string        `yaml:"name,omitempty"`
+	Type          string        `yaml:"type"`
+	Policy        string        `yaml:"policy,omitempty"`
+	Buffer        int           `yaml:"buffer,omitempty"`
+	Server        string        `yaml:"server"`
+	Database      string        `yaml:"database"`
+	User          string        `yaml:"user,omitempty"`
+	Password      string        `yaml:"password,omitempty"`
+	Table         string        `yaml:"table"`
+	Path          string        `yaml:"path,omitempty"`
+	Tables        []TableConfig `yaml:"tables,omitempty"`
+	Routing       RoutingConfig `yaml:"routing,omitempty"`
+	Compression   string        `yaml:"compression,omitempty"`
+	Rotate        RotateConfig  `yaml:"rotate,omitempty"`
+	PartitionBy   []string      `yaml:"partition_by,omitempty"`
+	RowGroupSize  int64         `yaml:"row_group_size,omitempty"`
+	HTTP          HTTPConfig    `yaml:"http,omitempty"`
+	Kafka         KafkaConfig   `yaml:"kafka,omitempty"`
+	BatchSize     int           `yaml:"batch_size,omitempty"`
+	FlushInterval time.Duration `yaml:"flush_interval,omitempty"`
+	Concurrency   int           `yaml:"concurrency,omitempty"`
+	OnFailure     string        `yaml:"on_failure,omitempty"`
+}
+
+// RoutingConfig sends each record to one of the sink tables. Routes are
+// tried in order; a record matching none goes to Default, or is handled by
+// Unmatched: "fail" (the default), "skip" or "dead_letter".
+type RoutingConfig struct {
+	Field     string        `yaml:"field,omitempty"`
+	Routes    []RouteConfig `yaml:"routes,omitempty"`
+	Default   string        `yaml:"default,omitempty"`
+	Unmatched string        `yaml:"unmatched,omitempty"`
+}
+
+// RouteConfig matches the value of Field, or of the routing field, by exact
+// value, prefix or regular expression, or matches records for which Expr is
+// true. Exactly one of them must be set.
+type RouteConfig struct {
+	Field   string     `yaml:"field,omitempty"`
+	Equals  string     `yaml:"equals,omitempty"`
+	Prefix  string     `yaml:"prefix,omitempty"`
+	Pattern string     `yaml:"pattern,omitempty"`
+	Expr    Expression `yaml:"expr,omitempty"`
+	Table   string     `yaml:"table"`
+}
+
+// DisplayName returns the configured name, or the type and position of the
+// sink in the sinks list.
+func (s SinkConfig) DisplayName(index int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s[%d]", s.Type, index)
+}
+
+// BestEffort reports whether a failure of this sink should be logged rather
+// than fail the run.
+func (s SinkConfig) BestEffort() bool {
+	return s.Policy == "best_effort"
+}
+
+// KafkaConfig describes the brokers and topics used by the Kafka source and
+// sink. The source reads Topic and Topics; the sink writes to Topic.
+type KafkaConfig struct {
+	Brokers     []string      `yaml:"brokers"`
+	Topic       string        `yaml:"topic,omitempty"`
+	Topics      []string      `yaml:"topics,omitempty"`
+	Group       string        `yaml:"group,omitempty"`
+	ClientID    string        `yaml:"client_id,omitempty"`
+	StartOffset string        `yaml:"start_offset,omitempty"`
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"`
+	MaxRecords  int           `yaml:"max_records,omitempty"`
+	Encoding    string        `yaml:"encoding,omitempty"`
+	SchemaFile  string        `yaml:"schema_file,omitempty"`
+	Key         string        `yaml:"key,omitempty"`
+	Linger      time.Duration `yaml:"linger,omitempty"`
+	BatchBytes  int32         `yaml:"batch_bytes,omitempty"`
+	TLS         bool          `yaml:"tls,omitempty"`
+	SASL        SASLConfig    `yaml:"sasl,omitempty"`
+}
+
+// SourceTopics returns Topic followed by Topics.
+func (k KafkaConfig) SourceTopics() []string {
+	var topics []string
+	if k.Topic != "" {
+		topics = append(topics, k.Topic)
+	}
+	return append(topics, k.Topics...)
+}
+
+// SFTPConfig describes the server the SFTP source reads path and paths
+// from. Format selects the parser: csv, tsv or jsonl. Password, PrivateKey
+// and Passphrase are resolved through the secrets package; PrivateKey holds
+// the PEM key itself, typically as a file: reference.
+type SFTPConfig struct {
+	Host                  string        `yaml:"host"`
+	Port                  int           `yaml:"port,omitempty"`
+	User                  string        `yaml:"user"`
+	Password              string        `yaml:"password,omitempty"`
+	PrivateKey            string        `yaml:"private_key,omitempty"`
+	Passphrase            string        `yaml:"passphrase,omitempty"`
+	KnownHosts            string        `yaml:"known_hosts,omitempty"`
+	InsecureIgnoreHostKey bool          `yaml:"insecure_ignore_host_key,omitempty"`
+	Timeout               time.Duration `yaml:"timeout,omitempty"`
+	Format                string        `yaml:"format"`
+	ArchiveDir            string        `yaml:"archive_dir,omitempty"`
+}
+
+// SASLConfig holds Kafka SASL credentials. Password is resolved through the
+// secrets package.
+type SASLConfig struct {
+	Mechanism string `yaml:"mechanism,omitempty"`
+	Username  string `yaml:"username,omitempty"`
+	Password  string `yaml:"password,omitempty"`
+}
+
+// StorageConfig configures the object stores that s3:// paths refer to.
+type StorageConfig struct {
+	S3 S3Config `yaml:"s3,omitempty"`
+}
+
+// S3Config points s3:// paths at AWS S3 or an S3-compatible store such as
+// MinIO. Without an access key, credentials are taken from the standard AWS
+// and MinIO environment variables or the instance role. Keys are resolved
+// through the secrets package.
+type S3Config struct {
+	Endpoint     string `yaml:"endpoint,omitempty"`
+	Region       string `yaml:"region,omitempty"`
+	AccessKey    string `yaml:"access_key,omitempty"`
+	SecretKey    string `yaml:"secret_key,omitempty"`
+	SessionToken string `yaml:"session_token,omitempty"`
+	Insecure     bool   `yaml:"insecure,omitempty"`
+	PathStyle    bool   `yaml:"path_style,omitempty"`
+	PartSize     uint64 `yaml:"part_size,omitempty"`
+}
+
+// DeadLetterConfig names the JSON Lines file that records are appended to
+// when a component gives up on them instead of failing the run.
+type DeadLetterConfig struct {
+	Path string `yaml:"path,omitempty"`
+}
+
+// SchemaConfig describes the fields of the extracted records. With Infer,
+// the fields are read from the column types of the source queries; declared
+// Fields take precedence over inferred ones of the same name. OnError is
+// fail, skip or dead_letter.
+type SchemaConfig struct {
+	Infer   bool          `yaml:"infer,omitempty"`
+	Fields  []FieldConfig `yaml:"fields,omitempty"`
+	OnError string        `yaml:"on_error,omitempty"`
+}
+
+// FieldConfig declares one field of the schema. Fields are nullable unless
+// Nullable is false. Precision and Scale apply to decimals and Length to
+// strings; all three may also be given in the type, as in DECIMAL(18,4) or
+// VARCHAR(50).
+type FieldConfig struct {
+	Name      string `yaml:"name"`
+	Type      string `yaml:"type"`
+	Nullable  *bool  `yaml:"nullable,omitempty"`
+	Precision int    `yaml:"precision,omitempty"`
+	Scale     int    `yaml:"scale,omitempty"`
+	Length    int    `yaml:"length,omitempty"`
+}
+
+// BatchConfig passes records between stages in batches of Size rather than
+// one at a time. A batch that is not full is passed on once FlushInterval
+// has passed, one second by default. A size of one or less turns batching
+// off.
+type BatchConfig struct {
+	Size          int           `yaml:"size,omitempty"`
+	FlushInterval time.Duration `yaml:"flush_interval,omitempty"`
+}
+
+// Enabled reports whether records are passed in batches.
+func (b BatchConfig) Enabled() bool {
+	return b.Size > 1
+}
+
+// ReconcileConfig turns on the reconciliation of a SQL sink with a SQL
+// source. Key names the record fields that identify a row; Checksums names
+// the fields whose values are also compared. Both are source column names,
+// mapped onto sink columns by the sink tables. Strict also fails tables
+// that lost rows between extraction and load, such as to a filter.
+type ReconcileConfig struct {
+	Key       []string `yaml:"key,omitempty"`
+	Checksums []string `yaml:"checksums,omitempty"`
+	Strict    bool     `yaml:"strict,omitempty"`
+}
+
+// Enabled reports whether the run is reconciled.
+func (r ReconcileConfig) Enabled() bool {
+	return len(r.Key) > 0
+}
+
+// AlertConfig configures alerts. They are always logged; with a webhook URL
+// they are also posted to it as JSON.
+type AlertConfig struct {
+	Webhook HTTPConfig `yaml:"webhook,omitempty"`
+}
+
+// RotateConfig limits the size of each file written by file sinks. A zero
+// limit is not enforced. MaxBytes counts bytes before compression.
+type RotateConfig struct {
+	MaxRecords int   `yaml:"max_records,omitempty"`
+	MaxBytes   int64 `yaml:"max_bytes,omitempty"`
+}
+
+// TableConfig describes a source table with its query, or a sink table with
+// the columns to write and the keys used to upsert into it.
+type TableConfig struct {
+	Name         string         `yaml:"name"`
+	Query        string         `yaml:"query,omitempty"`
+	Columns      []ColumnConfig `yaml:"columns,omitempty"`
+	ConflictKeys []string       `yaml:"conflict_keys,omitempty"`
 }
 
+// ColumnConfig maps a record field onto a sink column. Source defaults to
+// Name when the record already uses the sink column names.
+type ColumnConfig struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Source string `yaml:"source,omitempty"`
+}
+
+func (c ColumnConfig) Field() string {
+	if c.Source != "" {
+		return c.Source
+	}
+	return c.Name
+}
+
+// TransformationConfig is one step of the transformation chain. Type may be
+// left out for filter and derive steps.
 type TransformationConfig struct {
 	Type         string      `yaml:"type"`
 	ColumnName   string      `yaml:"column_name,omitempty"`
 	DefaultValue interface{} `yaml:"default_value,omitempty"`
+
+	Filter Expression  `yaml:"filter,omitempty"`
+	Derive Derivations `yaml:"derive,omitempty"`
+
+	// convert_time reads ColumnName and writes Target.
+	Target         string            `yaml:"target,omitempty"`
+	From           string            `yaml:"from,omitempty"`
+	Layout         string            `yaml:"layout,omitempty"`
+	SourceTimezone string            `yaml:"source_timezone,omitempty"`
+	Timezone       string            `yaml:"timezone,omitempty"`
+	Min            string            `yaml:"min,omitempty"`
+	Max            string            `yaml:"max,omitempty"`
+	Emit           map[string]string `yaml:"emit,omitempty"`
+
+	// OnError is fail, skip, dead_letter or, where the step supports it,
+	// null.
+	OnError string `yaml:"on_error,omitempty"`
+
+	// Columns maps column names to their masking rule for mask steps.
+	Columns map[string]MaskConfig `yaml:"columns,omitempty"`
+
+	// sessionize pairs logon and logoff events with the same Key.
+	Key          []string      `yaml:"key,omitempty"`
+	TimeField    string        `yaml:"time_field,omitempty"`
+	FlagField    string        `yaml:"flag_field,omitempty"`
+	LogonValues  []string      `yaml:"logon_values,omitempty"`
+	LogoffValues []string      `yaml:"logoff_values,omitempty"`
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	Carry        []string      `yaml:"carry,omitempty"`
+	Table        string        `yaml:"table,omitempty"`
+	Events       string        `yaml:"events,omitempty"`
+	StateKey     string        `yaml:"state_key,omitempty"`
+
+	// lookup enriches records with Fields of the Source row whose
+	// LookupKey matches Key.
+	Name      string                 `yaml:"name,omitempty"`
+	Source    *SourceConfig          `yaml:"source,omitempty"`
+	LookupKey []string               `yaml:"lookup_key,omitempty"`
+	Fields    map[string]string      `yaml:"fields,omitempty"`
+	Mode      string                 `yaml:"mode,omitempty"`
+	CacheSize int                    `yaml:"cache_size,omitempty"`
+	Query     string                 `yaml:"query,omitempty"`
+	OnMiss    string                 `yaml:"on_miss,omitempty"`
+	Defaults  map[string]interface{} `yaml:"defaults,omitempty"`
+
+	// dedup drops records whose Key was already seen.
+	Keep     string `yaml:"keep,omitempty"`
+	OrderBy  string `yaml:"order_by,omitempty"`
+	Window   int    `yaml:"window,omitempty"`
+	SpillDir string `yaml:"spill_dir,omitempty"`
+
+	// aggregate groups records by GroupBy and, with Interval, by tumbling
+	// windows of TimeField. A window stays open until the latest event is
+	// Lateness past its end.
+	GroupBy    []string      `yaml:"group_by,omitempty"`
+	Interval   time.Duration `yaml:"interval,omitempty"`
+	Lateness   time.Duration `yaml:"lateness,omitempty"`
+	Aggregates Derivations   `yaml:"aggregates,omitempty"`
+	MaxGroups  int           `yaml:"max_groups,omitempty"`
+
+	// check runs data quality Checks on the records passing through it.
+	Checks []CheckConfig `yaml:"checks,omitempty"`
+}
+
+// CheckConfig is one data quality check. not_null, unique,
+// accepted_values, range and regex look at every record; row_count,
+// freshness and null_rate at the run as a whole. Severity is fail, the
+// default, or warn.
+type CheckConfig struct {
+	Check    string        `yaml:"check"`
+	Name     string        `yaml:"name,omitempty"`
+	Severity string        `yaml:"severity,omitempty"`
+	Column   string        `yaml:"column,omitempty"`
+	Columns  []string      `yaml:"columns,omitempty"`
+	Values   []interface{} `yaml:"values,omitempty"`
+	Min      interface{}   `yaml:"min,omitempty"`
+	Max      interface{}   `yaml:"max,omitempty"`
+	Pattern  string        `yaml:"pattern,omitempty"`
+	MaxAge   time.Duration `yaml:"max_age,omitempty"`
+	From     string        `yaml:"from,omitempty"`
+	MaxRate  *float64      `yaml:"max_rate,omitempty"`
+}
+
+// Fields returns column followed by columns.
+func (c CheckConfig) Fields() []string {
+	var fields []string
+	if c.Column != "" {
+		fields = append(fields, c.Column)
+	}
+	return append(fields, c.Columns...)
+}
+
+// MaskConfig is how a mask step protects one column. Strategy is hash,
+// partial, null or redact.
+type MaskConfig struct {
+	Strategy string `yaml:"strategy"`
+
+	// hash: HMAC-SHA256 with Key, resolved through the secrets package.
+	Key string `yaml:"key,omitempty"`
+
+	// partial: characters kept in clear at either end.
+	KeepFirst int    `yaml:"keep_first,omitempty"`
+	KeepLast  int    `yaml:"keep_last,omitempty"`
+	MaskChar  string `yaml:"mask_char,omitempty"`
+
+	// redact: built-in patterns (ip, ipv4, ipv6, email) and regular
+	// expressions replaced by Replacement.
+	Redact      []string `yaml:"redact,omitempty"`
+	Patterns    []string `yaml:"patterns,omitempty"`
+	Replacement string   `yaml:"replacement,omitempty"`
+}
+
+func (t TransformationConfig) Kind() string {
+	switch {
+	case t.Type != "":
+		return t.Type
+	case t.Filter.Source != "":
+		return "filter"
+	case len(t.Derive) > 0:
+		return "derive"
+	}
+	return ""
+}
+
+// Expression is an expression string together with its position in the
+// configuration file, so that errors can point at it.
+type Expression struct {
+	Source string
+	Line   int
+	Column int
+}
+
+func (e *Expression) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expression must be a string", node.Line)
+	}
+	*e = Expression{Source: node.Value, Line: node.Line, Column: node.Column}
+	return nil
+}
+
+func (e Expression) MarshalYAML() (interface{}, error) {
+	return e.Source, nil
+}
+
+// Derivation sets Column to the value of Expr.
+type Derivation struct {
+	Column string
+	Expr   Expression
+}
+
+// Derivations is a mapping of column names to expressions, kept in file
+// order because later expressions may use earlier columns. It is also the
+// form of aggregate measures.
+type Derivations []Derivation
+
+func (d *Derivations) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of column names to expressions", node.Line)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if seen[key.Value] {
+			return fmt.Errorf("line %d: column %s is given twice", key.Line, key.Value)
+		}
+		seen[key.Value] = true
+
+		var e Expression
+		if err := value.Decode(&e); err != nil {
+			return err
+		}
+		*d = append(*d, Derivation{Column: key.Value, Expr: e})
+	}
+	return nil
+}
+
+func (d Derivations) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, dv := range d {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: dv.Column},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: dv.Expr.Source})
+	}
+	return node, nil
 }
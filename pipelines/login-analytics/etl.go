@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aniketwaliyan/etl-framework/internal/extract"
+	"github.com/aniketwaliyan/etl-framework/internal/load"
+	etlpipeline "github.com/aniketwaliyan/etl-framework/internal/pipeline"
+	etlconfig "github.com/aniketwaliyan/etl-framework/internal/utils/config"
 	"github.com/aniketwaliyan/etl-framework/pkg/config"
 	"github.com/aniketwaliyan/etl-framework/pkg/env"
 	"github.com/aniketwaliyan/etl-framework/pkg/pipeline"
@@ -17,24 +22,133 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// UserConnectionData is one row of the connection tables. Every column may
+// be NULL on the shards, and a NULL is written to PostgreSQL as NULL.
 type UserConnectionData struct {
-	DealerID         string
-	GroupID          string
-	DealerCode       string
-	LogonLogoffTime  int64
-	LoginAllowed     int
-	SuccessFailure   int16
-	LogonLogoffFlag  string
-	Details          string
-	ModeOfConnection int
-	ConnectionNumber int
-	EntrySequence    int
-	OMSSequenceNo    int64
-	SessionID        string
+	DealerID         sql.NullString
+	GroupID          sql.NullString
+	DealerCode       sql.NullString
+	LogonLogoffTime  sql.NullInt64
+	LoginAllowed     sql.NullInt32
+	SuccessFailure   sql.NullInt16
+	LogonLogoffFlag  sql.NullString
+	Details          sql.NullString
+	ModeOfConnection sql.NullInt32
+	ConnectionNumber sql.NullInt32
+	EntrySequence    sql.NullInt32
+	OMSSequenceNo    sql.NullInt64
+	SessionID        sql.NullString
 	SourceTable      string
+	Shard            int
 	ProcessedAt      time.Time
 }
 
+// newUserConnectionData reads a row scanned by extract.RowScanner, which
+// returns text as string, integers as int64 and NULL as nil. A value of
+// another type, or an integer too large for its column, is an error rather
+// than a NULL.
+func newUserConnectionData(row map[string]interface{}) (UserConnectionData, error) {
+	r := rowReader{row: row}
+	data := UserConnectionData{
+		DealerID:         r.nullString("sDealerId"),
+		GroupID:          r.nullString("sGroupId"),
+		DealerCode:       r.nullString("sDealerCode"),
+		LogonLogoffTime:  r.nullInt64("nLogonLogoffTime"),
+		LoginAllowed:     r.nullInt32("nLoginAllowed"),
+		SuccessFailure:   r.nullInt16("nSuccessFailure"),
+		LogonLogoffFlag:  r.nullString("cLogonLogoffFlag"),
+		Details:          r.nullString("sDetails"),
+		ModeOfConnection: r.nullInt32("nModeOfConnection"),
+		ConnectionNumber: r.nullInt32("nConnectioNumber"),
+		EntrySequence:    r.nullInt32("nEntrySequence"),
+		OMSSequenceNo:    r.nullInt64("nOMSSequenceNo"),
+		SessionID:        r.nullString("sSessionId"),
+	}
+	return data, r.err
+}
+
+// rowReader converts the columns of a row, keeping the first error.
+type rowReader struct {
+	row map[string]interface{}
+	err error
+}
+
+func (r *rowReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *rowReader) nullString(col string) sql.NullString {
+	switch v := r.row[col].(type) {
+	case nil:
+		return sql.NullString{}
+	case string:
+		return sql.NullString{String: v, Valid: true}
+	default:
+		r.fail(fmt.Errorf("column %s: expected text, got %T", col, v))
+		return sql.NullString{}
+	}
+}
+
+// integer returns the value of col if it fits in bits.
+func (r *rowReader) integer(col string, bits uint) (int64, bool) {
+	switch v := r.row[col].(type) {
+	case nil:
+		return 0, false
+	case int64:
+		if min, max := int64(-1)<<(bits-1), int64(1)<<(bits-1)-1; v < min || v > max {
+			r.fail(fmt.Errorf("column %s: %d does not fit in %d bits", col, v, bits))
+			return 0, false
+		}
+		return v, true
+	default:
+		r.fail(fmt.Errorf("column %s: expected an integer, got %T", col, v))
+		return 0, false
+	}
+}
+
+func (r *rowReader) nullInt64(col string) sql.NullInt64 {
+	n, ok := r.integer(col, 64)
+	return sql.NullInt64{Int64: n, Valid: ok}
+}
+
+func (r *rowReader) nullInt32(col string) sql.NullInt32 {
+	n, ok := r.integer(col, 32)
+	return sql.NullInt32{Int32: int32(n), Valid: ok}
+}
+
+func (r *rowReader) nullInt16(col string) sql.NullInt16 {
+	n, ok := r.integer(col, 16)
+	return sql.NullInt16{Int16: int16(n), Valid: ok}
+}
+
+// fields returns the record as the sink routing sees it: the sink columns,
+// and the source table and shard it was read from.
+func (d UserConnectionData) fields() etlpipeline.DataRecord {
+	value := func(v driver.Valuer) interface{} {
+		x, _ := v.Value()
+		return x
+	}
+	return etlpipeline.DataRecord{
+		"dealer_id":                  value(d.DealerID),
+		"group_id":                   value(d.GroupID),
+		"dealer_code":                value(d.DealerCode),
+		"logon_logoff_time":          value(d.LogonLogoffTime),
+		"login_allowed":              value(d.LoginAllowed),
+		"success_failure":            value(d.SuccessFailure),
+		"logon_logoff_flag":          value(d.LogonLogoffFlag),
+		"details":                    value(d.Details),
+		"mode_of_connection":         value(d.ModeOfConnection),
+		"connection_number":          value(d.ConnectionNumber),
+		"entry_sequence":             value(d.EntrySequence),
+		"oms_sequence_no":            value(d.OMSSequenceNo),
+		"session_id":                 value(d.SessionID),
+		etlpipeline.FieldSourceTable: d.SourceTable,
+		etlpipeline.FieldSourceShard: int64(d.Shard),
+	}
+}
+
 type Extractor struct {
 	dbs []*sql.DB
 	env *env.Config
@@ -159,30 +273,26 @@ func (e *Extractor) extractTable(ctx context.Context, db *sql.DB, shardID int, t
 	}
 	defer rows.Close()
 
+	scanner, err := extract.NewRowScanner(rows, "sqlserver")
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s on shard %d: %v", tableName, shardID, err)
+	}
+
 	recordCount := 0
 	for rows.Next() {
-		var data UserConnectionData
-		err := rows.Scan(
-			&data.DealerID,
-			&data.GroupID,
-			&data.DealerCode,
-			&data.LogonLogoffTime,
-			&data.LoginAllowed,
-			&data.SuccessFailure,
-			&data.LogonLogoffFlag,
-			&data.Details,
-			&data.ModeOfConnection,
-			&data.ConnectionNumber,
-			&data.EntrySequence,
-			&data.OMSSequenceNo,
-			&data.SessionID,
-		)
+		row, err := scanner.Scan(rows)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to scan row from %s on shard %d: %v", tableName, shardID, err)
 			continue
 		}
+		data, err := newUserConnectionData(row)
+		if err != nil {
+			errCh <- fmt.Errorf("invalid row from %s on shard %d: %v", tableName, shardID, err)
+			continue
+		}
 
-		data.SourceTable = fmt.Sprintf("%s_shard%d", tableName, shardID)
+		data.SourceTable = tableName
+		data.Shard = shardID
 		data.ProcessedAt = time.Now()
 
 		recordCount++
@@ -230,6 +340,7 @@ func (t *Transformer) Close() error {
 
 type Loader struct {
 	db     *sql.DB
+	router *load.Router
 	count  int
 	errors int
 	env    *env.Config
@@ -239,8 +350,25 @@ func NewLoader(envConfig *env.Config) *Loader {
 	return &Loader{env: envConfig}
 }
 
+// newRouter builds the router for the tables and routing rules of the sink.
+func newRouter(cfg *config.Config) (*load.Router, error) {
+	sink := &etlconfig.PipelineConfig{Sink: etlconfig.SinkConfig{
+		Type:    cfg.Sink.Type,
+		Tables:  cfg.Sink.Tables,
+		Routing: cfg.Sink.Routing,
+	}}
+	sink.Pipeline.Name = cfg.Pipeline.Name
+	return load.NewRouter(sink)
+}
+
 func (l *Loader) Init(cfg *config.Config) error {
 	log.Println("Initializing Loader...")
+	router, err := newRouter(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid sink routing: %v", err)
+	}
+	l.router = router
+
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		l.env.PostgresHost,
 		l.env.PostgresPort,
@@ -267,9 +395,13 @@ func (l *Loader) Load(ctx context.Context, data interface{}) error {
 		return fmt.Errorf("invalid data type: expected UserConnectionData")
 	}
 
-	targetTable := "user_connection_log"
-	if record.SourceTable == "dbo.tbl_UserConnectionHistory" {
-		targetTable = "user_connection_history"
+	targetTable, err := l.router.Table(record.fields())
+	if err != nil {
+		l.errors++
+		return err
+	}
+	if targetTable == "" {
+		return nil
 	}
 
 	query := fmt.Sprintf(`
@@ -303,7 +435,7 @@ func (l *Loader) Load(ctx context.Context, data interface{}) error {
 			session_id = EXCLUDED.session_id,
 			processed_at = EXCLUDED.processed_at`, targetTable)
 
-	_, err := l.db.ExecContext(ctx, query,
+	_, err = l.db.ExecContext(ctx, query,
 		record.DealerID,
 		record.GroupID,
 		record.DealerCode,
@@ -335,6 +467,11 @@ func (l *Loader) Load(ctx context.Context, data interface{}) error {
 
 func (l *Loader) Close() error {
 	log.Printf("ETL completed. Total records loaded: %d, Total errors: %d", l.count, l.errors)
+	if l.router != nil {
+		if err := l.router.Close(); err != nil {
+			return err
+		}
+	}
 	if l.db != nil {
 		return l.db.Close()
 	}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aniketwaliyan/etl-framework/pkg/config"
+)
+
+func TestNewUserConnectionData(t *testing.T) {
+	row := map[string]interface{}{
+		"sDealerId":        "D001",
+		"nLogonLogoffTime": int64(1760659200),
+		"nSuccessFailure":  int64(1),
+		"nEntrySequence":   nil,
+	}
+	data, err := newUserConnectionData(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.DealerID.String != "D001" || data.LogonLogoffTime.Int64 != 1760659200 || data.SuccessFailure.Int16 != 1 {
+		t.Fatalf("unexpected values: %+v", data)
+	}
+	if data.EntrySequence.Valid || data.SessionID.Valid {
+		t.Fatalf("NULL and missing columns must be NULL: %+v", data)
+	}
+
+	for name, row := range map[string]map[string]interface{}{
+		"text as integer":  {"nEntrySequence": "12"},
+		"integer as text":  {"sDealerId": int64(1)},
+		"float":            {"nOMSSequenceNo": 1.5},
+		"int32 overflow":   {"nConnectioNumber": int64(math.MaxInt32) + 1},
+		"int16 underflow":  {"nSuccessFailure": int64(math.MinInt16) - 1},
+		"bytes for a flag": {"cLogonLogoffFlag": []byte("I")},
+	} {
+		if _, err := newUserConnectionData(row); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoaderRoutesBySourceTable(t *testing.T) {
+	cfg, err := config.NewParser().Parse("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := newRouter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for source, want := range map[string]string{
+		"dbo.tbl_UserConnectionHistory": "user_connection_history",
+		"dbo.tbl_UserConnectionLog":     "user_connection_log",
+	} {
+		for shard := 1; shard <= 2; shard++ {
+			data := UserConnectionData{SourceTable: source, Shard: shard}
+			table, err := router.Table(data.fields())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if table != want {
+				t.Errorf("%s on shard %d: routed to %s, want %s", source, shard, table, want)
+			}
+		}
+	}
+	if _, err := router.Table(UserConnectionData{SourceTable: "dbo.tbl_Other"}.fields()); err == nil {
+		t.Error("record from an unknown table was routed")
+	}
+}
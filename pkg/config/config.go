@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 
+	etlconfig "github.com/aniketwaliyan/etl-framework/internal/utils/config"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,8 +24,10 @@ type Config struct {
 		Table    string `yaml:"table"`
 	} `yaml:"source"`
 	Sink struct {
-		Type  string `yaml:"type"`
-		Table string `yaml:"table"`
+		Type    string                  `yaml:"type"`
+		Table   string                  `yaml:"table"`
+		Tables  []etlconfig.TableConfig `yaml:"tables"`
+		Routing etlconfig.RoutingConfig `yaml:"routing"`
 	} `yaml:"sink"`
 }
 
@@ -0,0 +1,20 @@
+-- Sink fixture for configs/login-analytics-sqlite.yaml, matching the
+-- user_connection_history table of init-sink-db.sql.
+CREATE TABLE user_connection_history (
+    dealer_id          TEXT,
+    group_id           TEXT,
+    dealer_code        TEXT,
+    logon_logoff_time  INTEGER,
+    login_allowed      INTEGER,
+    success_failure    INTEGER,
+    logon_logoff_flag  TEXT,
+    details            TEXT,
+    mode_of_connection INTEGER,
+    connection_number  INTEGER,
+    entry_sequence     INTEGER,
+    oms_sequence_no    INTEGER,
+    session_id         TEXT,
+    source_table       TEXT,
+    processed_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (dealer_id, logon_logoff_time, entry_sequence)
+);
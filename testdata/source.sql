@@ -0,0 +1,24 @@
+-- Source fixture for configs/login-analytics-sqlite.yaml: a SQLite copy of
+-- the SQL Server connection history table, with a few logons and logoffs.
+CREATE TABLE tbl_UserConnectionHistory (
+    sDealerId         TEXT NOT NULL,
+    sGroupId          TEXT,
+    sDealerCode       TEXT,
+    nLogonLogoffTime  INTEGER NOT NULL,
+    nLoginAllowed     INTEGER,
+    nSuccessFailure   INTEGER,
+    cLogonLogoffFlag  TEXT,
+    sDetails          TEXT,
+    nModeOfConnection INTEGER,
+    nConnectioNumber  INTEGER,
+    nEntrySequence    INTEGER NOT NULL,
+    nOMSSequenceNo    INTEGER,
+    sSessionId        TEXT
+);
+
+INSERT INTO tbl_UserConnectionHistory VALUES
+    ('D001', 'G01', 'DLR001', 1760659200, 1, 1, 'I', 'Login successful',  1, 101, 1, 5001, 'S-1001'),
+    ('D001', 'G01', 'DLR001', 1760662800, 1, 1, 'O', 'Logout',            1, 101, 2, 5002, 'S-1001'),
+    ('D002', 'G01', 'DLR002', 1760659500, 1, 0, 'I', 'Invalid password',  2, 102, 1, 5003, NULL),
+    ('D002', 'G01', 'DLR002', 1760659560, 1, 1, 'I', 'Login successful',  2, 103, 2, 5004, 'S-1002'),
+    ('D003', 'G02', 'DLR003', 1760660000, 0, 0, 'I', NULL,                3, 104, 1, 5005, NULL);